@@ -7,6 +7,7 @@ import (
 	"github.com/joho/godotenv"
 	"playplus_platform/internal/handler"
 	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
 )
 
 func main() {
@@ -22,6 +23,17 @@ func main() {
 	}
 	defer repository.CloseDB()
 
+	// Maintenance subcommands
+	if len(os.Args) > 1 && os.Args[1] == "migrate-storage" {
+		code := runMigrateStorage(os.Args[2:])
+		repository.CloseDB()
+		os.Exit(code)
+	}
+
+	service.BootstrapAdmins()
+	service.FailInterruptedImports()
+	service.StartStorageGCJob()
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+
+	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
+)
+
+const migrateStorageUsage = `Usage: server migrate-storage -from SPEC -to SPEC [flags]
+
+Copies every object from one storage backend to another, then rewrites DB records.
+Safe to re-run: completed objects are recorded in the state file and skipped.
+A state file is tied to its -from/-to pair; use another -state for another pair.
+
+SPEC is one of:
+  default                                   backend configured by environment
+  local:/path/to/dir                        local directory
+  s3://[access:secret@]host[:port]/bucket   S3-compatible bucket (?region=..&insecure=1)
+
+Flags:
+`
+
+// runMigrateStorage implements the migrate-storage subcommand and returns the exit code
+func runMigrateStorage(args []string) int {
+	fs := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateStorageUsage)
+		fs.PrintDefaults()
+	}
+	from := fs.String("from", "default", "source backend")
+	to := fs.String("to", "", "destination backend (required)")
+	prefix := fs.String("prefix", "", "only copy keys starting with this prefix")
+	concurrency := fs.Int("concurrency", 8, "objects copied in parallel")
+	verify := fs.Bool("verify", true, "re-read copied objects and compare SHA-256")
+	dryRun := fs.Bool("dry-run", false, "report what would be copied and rewritten without changing anything")
+	statePath := fs.String("state", "storage-migrate.state", "file recording completed keys, for resuming (empty disables)")
+	rewriteDB := fs.Bool("db", true, "rewrite DB records after copying")
+	urlFrom := fs.String("url-from", "", "legacy URL base to replace in DB URL columns, e.g. https://old-cdn.example.com")
+	urlTo := fs.String("url-to", "", "new URL base for -url-from")
+	fs.Parse(args)
+
+	if *to == "" {
+		fs.Usage()
+		return 2
+	}
+	if (*urlFrom == "") != (*urlTo == "") {
+		log.Println("[ERROR] -url-from and -url-to must be given together")
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	source, err := service.OpenStorageBackend(ctx, *from, false)
+	if err != nil {
+		log.Printf("[ERROR] Source: %v", err)
+		return 1
+	}
+	dest, err := service.OpenStorageBackend(ctx, *to, !*dryRun)
+	if err != nil {
+		log.Printf("[ERROR] Destination: %v", err)
+		return 1
+	}
+	if source.Describe() == dest.Describe() {
+		log.Println("[ERROR] Source and destination are the same backend")
+		return 2
+	}
+
+	log.Printf("[INFO] Migrating %s -> %s (prefix %q, concurrency %d, verify %v, dry run %v)",
+		source.Describe(), dest.Describe(), *prefix, *concurrency, *verify, *dryRun)
+
+	migration := &service.StorageMigration{
+		Source:      source,
+		Dest:        dest,
+		Prefix:      *prefix,
+		Concurrency: *concurrency,
+		Verify:      *verify,
+		DryRun:      *dryRun,
+		StatePath:   *statePath,
+	}
+	report, err := migration.Run(ctx)
+	if report != nil {
+		printMigrationReport(report, *dryRun)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Migration stopped: %v", err)
+		return 1
+	}
+	if len(report.Failures) > 0 {
+		log.Printf("[ERROR] %d objects failed; fix the cause and re-run to retry them. DB pass skipped.", len(report.Failures))
+		return 1
+	}
+
+	if !*rewriteDB {
+		return 0
+	}
+	if !repository.IsDBAvailable() {
+		log.Println("[WARN] DATABASE_URL not set, skipping DB pass")
+		return 0
+	}
+
+	affected, err := repository.RewriteStorageReferences(ctx, repository.StorageRewrite{
+		FromBackend: source.BackendID(),
+		ToBackend:   dest.BackendID(),
+		FromURL:     *urlFrom,
+		ToURL:       *urlTo,
+		DryRun:      *dryRun,
+	})
+	if err != nil {
+		log.Printf("[ERROR] DB pass failed: %v", err)
+		return 1
+	}
+
+	names := make([]string, 0, len(affected))
+	for name := range affected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("DB records:")
+	for _, name := range names {
+		fmt.Printf("  %-40s %d rows\n", name, affected[name])
+	}
+	if *dryRun {
+		fmt.Println("  (dry run, rolled back)")
+	}
+	return 0
+}
+
+func printMigrationReport(r *service.MigrationReport, dryRun bool) {
+	copied := "Copied"
+	if dryRun {
+		copied = "To copy"
+	}
+	fmt.Printf("Objects listed:   %d (%s)\n", r.Listed, service.FormatBytes(r.ListedBytes))
+	fmt.Printf("%-17s %d (%s)\n", copied+":", r.Copied, service.FormatBytes(r.CopiedBytes))
+	fmt.Printf("Already present:  %d (%s)\n", r.Skipped, service.FormatBytes(r.SkippedBytes))
+	fmt.Printf("Failed:           %d\n", len(r.Failures))
+	for _, f := range r.Failures {
+		fmt.Printf("  %s: %s\n", f.Key, f.Error)
+	}
+	fmt.Printf("Took %s\n", r.Duration.Round(1e6))
+}
@@ -1,21 +1,27 @@
 package config
 
 import (
-	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 var (
-	cfg        *Config
-	once       sync.Once
-	httpClient *http.Client
+	cfg  *Config
+	once sync.Once
 )
 
 type Config struct {
 	// Server
-	Port string
+	Port          string
+	PublicBaseURL string // e.g. https://platform.playerplus.cn, used for links sent outside the app
+
+	// Client IPs behind a reverse proxy. X-Forwarded-For is only honored from
+	// TrustedProxies; with none, the peer address is the client IP.
+	TrustedProxies  []string // IPs or CIDRs, e.g. 10.0.0.0/8
+	TrustedPlatform string   // Header set by a CDN in front of the app, e.g. CF-Connecting-IP
 
 	// Database
 	DatabaseURL string
@@ -32,6 +38,86 @@ type Config struct {
 	StorageSecretKey string
 	StoragePublicURL  string // Public URL for accessing stored files (CDN)
 	StorageDirectURL  string // Direct URL for external API access (bypassing CDN)
+	StoragePrivate    bool          // Keep the bucket private and hand out presigned URLs only
+	StorageURLExpiry  time.Duration // Lifetime of presigned URLs in private mode
+
+	// Resumable uploads (tus)
+	TusStagingDir string // Where partial uploads are buffered, defaults to the OS temp dir
+	TusMaxSize    int64  // Maximum Upload-Length accepted, in bytes
+
+	// Upload size limits per endpoint, in bytes
+	UploadMaxMediaSize int64 // /media/upload
+	UploadMaxFaceSize  int64 // /media/upload/face
+	UploadMaxFrameSize int64 // /media/upload/frame and /face/detect/upload
+
+	// Video limits checked before face detection (0 disables a limit).
+	// Width/height apply to the long/short edge so portrait videos are treated alike.
+	VideoMaxDuration time.Duration
+	VideoMaxWidth    int64
+	VideoMaxHeight   int64
+
+	// Largest image decoded for normalization and thumbnails, in pixels (0 = unlimited)
+	ImageMaxPixels int64
+
+	// Face image normalization
+	FaceMaxEdge     int // Long edge of the normalized face image, in pixels
+	FaceJPEGQuality int // 1-100
+
+	// Default thumbnail size generated on upload, in pixels (long edge)
+	ThumbnailSize int
+
+	// Result share links
+	ShareDefaultExpiry time.Duration
+	ShareMaxExpiry     time.Duration
+	ShareSigningKey    string // Signs share access cookies; random per process if empty
+	ShareUnlockLimit   int    // Password attempts per link and per IP per ShareUnlockWindow (0 = unlimited)
+	ShareUnlockWindow  time.Duration
+
+	// Storage caps in bytes (0 = unlimited), overridable per user/team in the DB
+	StorageQuotaUser int64
+	StorageQuotaTeam int64
+
+	// Face swap pricing
+	VModelPricing   string  // JSON object of model version -> price, overrides the built-in table
+	CreditQuotaUser float64 // Monthly credits per user (0 = unlimited), overridable per user in the DB
+
+	// Swap tasks estimated above these need an admin's approval (0 disables)
+	ApprovalCreditLimit      float64 // Per task
+	ApprovalBatchCreditLimit float64 // Per batch, pending tasks included
+
+	// Orphaned object GC
+	GCInterval            time.Duration // How often the GC job runs (0 disables the job)
+	GCGracePeriod         time.Duration // Unreferenced objects younger than this are left alone
+	GCMode                string        // quarantine or delete
+	GCQuarantineRetention time.Duration // Quarantined objects are purged after this long
+	GCFrameRetention      time.Duration // Frame uploads are dropped from the library after this long (0 keeps them)
+	GCFaceRetention       time.Duration // Untagged faces never used in a swap are dropped after this long (0 keeps them)
+
+	// Bootstrap admins, promoted to the admin role at startup and on first login
+	AdminEmails []string
+
+	// Accounts
+	DevLogin          bool // Accept the test/test login; never enable in production
+	PasswordMinLength int
+	AllowedDomains    []string      // Email domains that can sign up with a code; others need an invitation
+	InvitationExpiry  time.Duration // Default validity of an invitation
+
+	// Brute-force protection of sign-in
+	CodeMaxAttempts    int           // Wrong guesses before a verification code is locked
+	CodeResendCooldown time.Duration // Minimum time between codes sent to one email
+	AuthSendLimit      int           // Codes one IP can request per AuthRateWindow (0 = unlimited)
+	AuthVerifyLimit    int           // Code, password and reset attempts per IP per AuthRateWindow (0 = unlimited)
+	AuthRateWindow     time.Duration
+
+	// Outbound fetches of URLs from providers and users
+	TransferAllowedHosts []string // Domains (and their subdomains) provider results may be transferred from
+	FetchMaxRedirects    int
+	FetchMaxSize         int64 // Largest response body accepted, in bytes (0 = unlimited)
+	MediaImportTimeout   time.Duration
+	MediaImportMaxActive int // Imports one user can run at a time (0 = unlimited)
+
+	// VModel requests and responses kept for the admin task view (0 keeps them forever)
+	ProviderCallRetention time.Duration
 
 	// Aliyun DirectMail (Email)
 	AliyunAccessKeyID     string
@@ -44,7 +130,11 @@ func Get() *Config {
 	once.Do(func() {
 		cfg = &Config{
 			// Server
-			Port: getEnv("PORT", "8080"),
+			Port:          getEnv("PORT", "8080"),
+			PublicBaseURL: strings.TrimSuffix(getEnv("PUBLIC_BASE_URL", ""), "/"),
+
+			TrustedProxies:  getEnvList("TRUSTED_PROXIES", ""),
+			TrustedPlatform: getEnv("TRUSTED_PLATFORM", ""),
 
 			// Database
 			DatabaseURL: os.Getenv("DATABASE_URL"),
@@ -61,6 +151,81 @@ func Get() *Config {
 			StorageSecretKey: getEnv("MINIO_ROOT_PASSWORD", os.Getenv("AWS_SECRET_ACCESS_KEY")),
 			StoragePublicURL:  getEnv("STORAGE_PUBLIC_URL", ""),
 			StorageDirectURL:  getEnv("STORAGE_DIRECT_URL", ""),
+			StoragePrivate:    getEnvBool("STORAGE_PRIVATE", false),
+			StorageURLExpiry:  getEnvDuration("STORAGE_URL_EXPIRY", time.Hour),
+
+			// Resumable uploads
+			TusStagingDir: getEnv("TUS_STAGING_DIR", ""),
+			TusMaxSize:    getEnvInt64("TUS_MAX_SIZE", 1<<30), // 1GB
+
+			// Upload size limits
+			UploadMaxMediaSize: getEnvInt64("UPLOAD_MAX_MEDIA_SIZE", 500<<20), // 500MB
+			UploadMaxFaceSize:  getEnvInt64("UPLOAD_MAX_FACE_SIZE", 20<<20),   // 20MB
+			UploadMaxFrameSize: getEnvInt64("UPLOAD_MAX_FRAME_SIZE", 20<<20),  // 20MB
+
+			// Video limits
+			VideoMaxDuration: getEnvDuration("VIDEO_MAX_DURATION", 5*time.Minute),
+			VideoMaxWidth:    getEnvInt64("VIDEO_MAX_WIDTH", 3840),
+			VideoMaxHeight:   getEnvInt64("VIDEO_MAX_HEIGHT", 2160),
+
+			ImageMaxPixels: getEnvInt64("IMAGE_MAX_PIXELS", 50000000), // 50MP
+
+			// Face image normalization
+			FaceMaxEdge:     int(getEnvInt64("FACE_MAX_EDGE", 1536)),
+			FaceJPEGQuality: int(getEnvInt64("FACE_JPEG_QUALITY", 90)),
+
+			ThumbnailSize: int(getEnvInt64("THUMBNAIL_SIZE", 320)),
+
+			// Result share links
+			ShareDefaultExpiry: getEnvDuration("SHARE_DEFAULT_EXPIRY", 72*time.Hour),
+			ShareMaxExpiry:     getEnvDuration("SHARE_MAX_EXPIRY", 30*24*time.Hour),
+			ShareSigningKey:    os.Getenv("SHARE_SIGNING_KEY"),
+			ShareUnlockLimit:   int(getEnvInt64("SHARE_UNLOCK_LIMIT", 10)),
+			ShareUnlockWindow:  getEnvDuration("SHARE_UNLOCK_WINDOW", time.Hour),
+
+			// Storage caps
+			StorageQuotaUser: getEnvInt64("STORAGE_QUOTA_USER", 0),
+			StorageQuotaTeam: getEnvInt64("STORAGE_QUOTA_TEAM", 0),
+
+			// Face swap pricing
+			VModelPricing:   getEnv("VMODEL_PRICING", ""),
+			CreditQuotaUser: getEnvFloat("CREDIT_QUOTA_USER", 0),
+
+			// Swap approvals
+			ApprovalCreditLimit:      getEnvFloat("APPROVAL_CREDIT_LIMIT", 0),
+			ApprovalBatchCreditLimit: getEnvFloat("APPROVAL_BATCH_CREDIT_LIMIT", 0),
+
+			// Orphaned object GC
+			GCInterval:            getEnvDuration("GC_INTERVAL", 0), // Opt-in: a first run may remove objects older than the app's records
+			GCGracePeriod:         getEnvDuration("GC_GRACE_PERIOD", 72*time.Hour),
+			GCMode:                getEnv("GC_MODE", "quarantine"),
+			GCQuarantineRetention: getEnvDuration("GC_QUARANTINE_RETENTION", 30*24*time.Hour),
+			GCFrameRetention:      getEnvDuration("GC_FRAME_RETENTION", 7*24*time.Hour),
+			GCFaceRetention:       getEnvDuration("GC_FACE_RETENTION", 0),
+
+			AdminEmails: getEnvList("ADMIN_EMAILS", ""),
+
+			// Accounts
+			DevLogin:          getEnvBool("DEV_LOGIN", false),
+			PasswordMinLength: int(getEnvInt64("PASSWORD_MIN_LENGTH", 8)),
+			AllowedDomains:    getEnvList("ALLOWED_EMAIL_DOMAINS", "playerplus.cn"),
+			InvitationExpiry:  getEnvDuration("INVITATION_EXPIRY", 7*24*time.Hour),
+
+			// Brute-force protection
+			CodeMaxAttempts:    int(getEnvInt64("CODE_MAX_ATTEMPTS", 5)),
+			CodeResendCooldown: getEnvDuration("CODE_RESEND_COOLDOWN", time.Minute),
+			AuthSendLimit:      int(getEnvInt64("AUTH_SEND_LIMIT", 10)),
+			AuthVerifyLimit:    int(getEnvInt64("AUTH_VERIFY_LIMIT", 30)),
+			AuthRateWindow:     getEnvDuration("AUTH_RATE_WINDOW", time.Hour),
+
+			// Outbound fetches
+			TransferAllowedHosts: getEnvList("TRANSFER_ALLOWED_HOSTS", "vmodel.ai"),
+			FetchMaxRedirects:    int(getEnvInt64("FETCH_MAX_REDIRECTS", 3)),
+			FetchMaxSize:         getEnvInt64("FETCH_MAX_SIZE", 1<<30), // 1GB
+			MediaImportTimeout:   getEnvDuration("MEDIA_IMPORT_TIMEOUT", 30*time.Minute),
+			MediaImportMaxActive: int(getEnvInt64("MEDIA_IMPORT_MAX_ACTIVE", 3)),
+
+			ProviderCallRetention: getEnvDuration("PROVIDER_CALL_RETENTION", 30*24*time.Hour),
 
 			// Aliyun DirectMail
 			AliyunAccessKeyID:     os.Getenv("ALIYUN_ACCESS_KEY_ID"),
@@ -79,6 +244,80 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+// getEnvDuration parses values like "30m" or "2h"
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+// getEnvList parses a comma-separated list, dropping empty entries
+func getEnvList(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// IsAdminEmail checks if an email is listed in ADMIN_EMAILS. Admin access
+// itself is decided by the user's role.
+func (c *Config) IsAdminEmail(email string) bool {
+	for _, admin := range c.AdminEmails {
+		if strings.EqualFold(admin, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAllowedEmailDomain checks if an email's domain is listed in ALLOWED_EMAIL_DOMAINS
+func (c *Config) IsAllowedEmailDomain(email string) bool {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := email[at+1:]
+	for _, allowed := range c.AllowedDomains {
+		if strings.EqualFold(strings.TrimPrefix(allowed, "@"), domain) {
+			return true
+		}
+	}
+	return false
+}
+
 // IsStorageConfigured checks if storage is properly configured
 func (c *Config) IsStorageConfigured() bool {
 	return c.StorageAccessKey != "" && c.StorageSecretKey != ""
@@ -93,13 +332,3 @@ func (c *Config) IsVModelConfigured() bool {
 func (c *Config) IsFaceSwapConfigured() bool {
 	return c.IsVModelConfigured()
 }
-
-// HTTPClient returns a shared HTTP client for making requests
-func (c *Config) HTTPClient() *http.Client {
-	if httpClient == nil {
-		httpClient = &http.Client{
-			Timeout: 5 * time.Minute, // 5 min for large file downloads
-		}
-	}
-	return httpClient
-}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
+)
+
+const maxApprovalsLimit = 200
+
+// ReviewApprovalRequest is the reviewer's note; required when rejecting
+type ReviewApprovalRequest struct {
+	Reason string `json:"reason" binding:"max=1000"`
+}
+
+// ApprovalResponse is a swap task awaiting or after review
+type ApprovalResponse struct {
+	ApprovalID     string     `json:"approval_id"`
+	TaskID         string     `json:"task_id"` // The provider's ID once submitted
+	UserID         int64      `json:"user_id"`
+	Status         string     `json:"status"`
+	Credits        float64    `json:"credits"` // Estimate until submitted
+	BatchID        string     `json:"batch_id,omitempty"`
+	TargetVideoKey string     `json:"target_video_key"`
+	Faces          int        `json:"faces"`
+	FaceEnhance    bool       `json:"face_enhance"`
+	Reason         string     `json:"reason,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ReviewedAt     *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// ListPendingApprovals returns the swap tasks awaiting approval, oldest first
+// Query: limit (default 50)
+func ListPendingApprovals(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > maxApprovalsLimit {
+		limit = 50
+	}
+
+	tasks, err := repository.ListPendingApprovals(c.Request.Context(), limit)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list pending approvals: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list pending approvals"})
+		return
+	}
+
+	items := make([]ApprovalResponse, 0, len(tasks))
+	for i := range tasks {
+		items = append(items, toApprovalResponse(&tasks[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// ApproveSwapTask approves a pending task and submits it to VModel
+func ApproveSwapTask(c *gin.Context) {
+	reviewApproval(c, true)
+}
+
+// RejectSwapTask rejects a pending task; a reason is required
+func RejectSwapTask(c *gin.Context) {
+	reviewApproval(c, false)
+}
+
+func reviewApproval(c *gin.Context, approve bool) {
+	var req ReviewApprovalRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength != 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if !approve && req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required when rejecting"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	reviewerID := middleware.GetUserID(c)
+	var task *repository.SwapTask
+	var err error
+	if approve {
+		task, err = service.ApproveSwapTask(ctx, c.Param("id"), reviewerID, req.Reason)
+	} else {
+		task, err = service.RejectSwapTask(ctx, c.Param("id"), reviewerID, req.Reason)
+	}
+
+	switch {
+	case errors.Is(err, service.ErrApprovalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Approval request not found"})
+	case errors.Is(err, service.ErrApprovalNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "Task is not pending approval"})
+	case errors.Is(err, service.ErrCreditQuotaExceeded):
+		// Still pending: reject it, or approve once the quota allows
+		c.JSON(http.StatusConflict, gin.H{"error": "User's credit quota no longer covers this task: " + err.Error()})
+	case err != nil && task != nil:
+		// Approved, but VModel refused the task
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Approved but submission failed", "task": toApprovalResponse(task)})
+	case err != nil:
+		log.Printf("[ERROR] Failed to review swap task %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review task"})
+	default:
+		c.JSON(http.StatusOK, toApprovalResponse(task))
+	}
+}
+
+func toApprovalResponse(t *repository.SwapTask) ApprovalResponse {
+	resp := ApprovalResponse{
+		ApprovalID:     t.ApprovalID.String,
+		TaskID:         t.TaskID,
+		UserID:         t.UserID,
+		Status:         t.Status,
+		Credits:        t.CreditsUsed,
+		BatchID:        t.BatchID.String,
+		TargetVideoKey: t.TargetVideoKey.String,
+		Faces:          len(t.FaceIDs),
+		FaceEnhance:    t.FaceEnhance,
+		Reason:         t.ApprovalReason.String,
+		Error:          t.ErrorMessage.String,
+		CreatedAt:      t.CreatedAt,
+	}
+	if t.ReviewedAt.Valid {
+		reviewedAt := t.ReviewedAt.Time
+		resp.ReviewedAt = &reviewedAt
+	}
+	return resp
+}
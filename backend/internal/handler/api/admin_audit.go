@@ -0,0 +1,56 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/repository"
+)
+
+const maxAuthEvents = 500
+
+type AuthEventResponse struct {
+	ID        int64     `json:"id"`
+	Event     string    `json:"event"`
+	Email     string    `json:"email,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListAuthEvents returns sign-in audit entries: locked codes and throttled IPs
+// Query: event (code_locked or ip_throttled), days (default 7)
+func ListAuthEvents(c *gin.Context) {
+	event := c.Query("event")
+	if event != "" && event != repository.AuthEventCodeLocked && event != repository.AuthEventIPThrottled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "event must be code_locked or ip_throttled"})
+		return
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if days < 1 {
+		days = 7
+	}
+
+	events, err := repository.ListAuthEvents(c.Request.Context(), event, time.Now().AddDate(0, 0, -days), maxAuthEvents)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list auth events: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list auth events"})
+		return
+	}
+
+	items := make([]AuthEventResponse, 0, len(events))
+	for _, e := range events {
+		items = append(items, AuthEventResponse{
+			ID:        e.ID,
+			Event:     e.Event,
+			Email:     e.Email.String,
+			IP:        e.IP.String,
+			Detail:    e.Detail.String,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
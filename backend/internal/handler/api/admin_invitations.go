@@ -0,0 +1,133 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
+)
+
+const maxInvitationsList = 500
+
+// InvitationRequest invites an email. Role defaults to member.
+type InvitationRequest struct {
+	Email     string `json:"email" binding:"required,email"`
+	Role      string `json:"role"`
+	ExpiresIn int64  `json:"expires_in"` // Seconds, defaults to INVITATION_EXPIRY
+}
+
+type InvitationResponse struct {
+	ID             int64      `json:"id"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	Status         string     `json:"status"` // pending, accepted, revoked or expired
+	InvitedBy      int64      `json:"invited_by,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	AcceptedUserID int64      `json:"accepted_user_id,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ListInvitations returns invitations, newest first
+// Query: status (pending, accepted, revoked or expired)
+func ListInvitations(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", "pending", "accepted", "revoked", "expired":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, accepted, revoked or expired"})
+		return
+	}
+
+	invitations, err := repository.ListInvitations(c.Request.Context(), status, maxInvitationsList)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list invitations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list invitations"})
+		return
+	}
+
+	items := make([]InvitationResponse, 0, len(invitations))
+	for _, inv := range invitations {
+		items = append(items, toInvitationResponse(&inv))
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// CreateInvitation invites an email outside the allowed domains and mails the invitee
+func CreateInvitation(c *gin.Context) {
+	var req InvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = repository.RoleMember
+	}
+	if !repository.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be admin, member or viewer"})
+		return
+	}
+
+	inv, err := service.InviteUser(c.Request.Context(), req.Email, req.Role,
+		time.Duration(req.ExpiresIn)*time.Second, middleware.GetUserID(c))
+	switch {
+	case errors.Is(err, service.ErrInvalidExpiry):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "A user with this email already exists"})
+		return
+	case errors.Is(err, service.ErrAccountsUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database not configured"})
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to invite %s: %v", req.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invitation"})
+		return
+	}
+	c.JSON(http.StatusCreated, toInvitationResponse(inv))
+}
+
+// RevokeInvitation withdraws a pending invitation
+func RevokeInvitation(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
+		return
+	}
+
+	revoked, err := repository.RevokeInvitation(c.Request.Context(), id)
+	if err != nil {
+		log.Printf("[ERROR] Failed to revoke invitation %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invitation"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invitation not found or no longer open"})
+		return
+	}
+	log.Printf("[INFO] User %d revoked invitation %d", middleware.GetUserID(c), id)
+	c.JSON(http.StatusOK, gin.H{"status": "revoked"})
+}
+
+func toInvitationResponse(inv *repository.Invitation) InvitationResponse {
+	return InvitationResponse{
+		ID:             inv.ID,
+		Email:          inv.Email,
+		Role:           inv.Role,
+		Status:         inv.Status(),
+		InvitedBy:      inv.InvitedBy.Int64,
+		ExpiresAt:      inv.ExpiresAt,
+		AcceptedAt:     nullTime(inv.AcceptedAt.Time, inv.AcceptedAt.Valid),
+		AcceptedUserID: inv.AcceptedUserID.Int64,
+		RevokedAt:      nullTime(inv.RevokedAt.Time, inv.RevokedAt.Valid),
+		CreatedAt:      inv.CreatedAt,
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"encoding/csv"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/repository"
+)
+
+// maxReportRange keeps day-level reports to a sensible size
+const maxReportRange = 366 * 24 * time.Hour
+
+// UsageReportRowResponse is one group's activity in one period
+type UsageReportRowResponse struct {
+	Period       string  `json:"period"` // YYYY-MM-DD or YYYY-MM
+	GroupID      *int64  `json:"group_id,omitempty"`
+	Group        string  `json:"group"`
+	Tasks        int64   `json:"tasks"`
+	Credits      float64 `json:"credits"`
+	StorageBytes int64   `json:"storage_bytes"`
+}
+
+// UsageReportTotals sums a report
+type UsageReportTotals struct {
+	Tasks        int64   `json:"tasks"`
+	Credits      float64 `json:"credits"`
+	StorageBytes int64   `json:"storage_bytes"`
+}
+
+// GetUsageReport aggregates tasks, credits and added storage over a date range
+// Query: from, to (YYYY-MM-DD, default this month), group (user|team|tool, default user),
+// period (day|month, default month), user_id, team_id, format (json|csv)
+func GetUsageReport(c *gin.Context) {
+	now := time.Now()
+	filter := repository.UsageReportFilter{
+		From:   time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local),
+		To:     now,
+		Group:  c.DefaultQuery("group", "user"),
+		Period: c.DefaultQuery("period", "month"),
+	}
+	if from, ok := parseDateParam(c, "from", false); !ok {
+		return
+	} else if !from.IsZero() {
+		filter.From = from
+	}
+	if to, ok := parseDateParam(c, "to", true); !ok {
+		return
+	} else if !to.IsZero() {
+		filter.To = to
+	}
+	if !filter.To.After(filter.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from"})
+		return
+	}
+	if filter.To.Sub(filter.From) > maxReportRange {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Date range cannot exceed one year"})
+		return
+	}
+	if filter.Group != "user" && filter.Group != "team" && filter.Group != "tool" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group must be user, team or tool"})
+		return
+	}
+	if filter.Period != "day" && filter.Period != "month" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be day or month"})
+		return
+	}
+	filter.UserID, _ = strconv.ParseInt(c.Query("user_id"), 10, 64)
+	filter.TeamID, _ = strconv.ParseInt(c.Query("team_id"), 10, 64)
+
+	report, err := repository.GetUsageReport(c.Request.Context(), filter)
+	if err != nil {
+		log.Printf("[ERROR] Failed to build usage report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build usage report"})
+		return
+	}
+
+	layout := "2006-01"
+	if filter.Period == "day" {
+		layout = "2006-01-02"
+	}
+	rows := make([]UsageReportRowResponse, 0, len(report))
+	var totals UsageReportTotals
+	for _, r := range report {
+		row := UsageReportRowResponse{
+			Period:       r.Period.Format(layout),
+			Group:        r.Group,
+			Tasks:        r.Tasks,
+			Credits:      r.Credits,
+			StorageBytes: r.StorageBytes,
+		}
+		if r.GroupID.Valid {
+			id := r.GroupID.Int64
+			row.GroupID = &id
+		}
+		rows = append(rows, row)
+		totals.Tasks += r.Tasks
+		totals.Credits += r.Credits
+		totals.StorageBytes += r.StorageBytes
+	}
+
+	if c.Query("format") == "csv" {
+		writeUsageReportCSV(c, filter, rows)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"from":   filter.From,
+		"to":     filter.To,
+		"group":  filter.Group,
+		"period": filter.Period,
+		"rows":   rows,
+		"totals": totals,
+	})
+}
+
+func writeUsageReportCSV(c *gin.Context, filter repository.UsageReportFilter, rows []UsageReportRowResponse) {
+	// The range end is exclusive; name the file after the last day covered
+	filename := "usage-" + filter.Group + "-" + filter.From.Format("20060102") + "-" +
+		filter.To.Add(-time.Nanosecond).Format("20060102") + ".csv"
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", contentDisposition("attachment", filename))
+	c.Status(http.StatusOK)
+
+	// BOM so Excel opens the UTF-8 team names correctly
+	c.Writer.WriteString("\ufeff")
+	cw := csv.NewWriter(c.Writer)
+	cw.Write([]string{"period", filter.Group + "_id", filter.Group, "tasks", "credits", "storage_bytes"})
+	for _, r := range rows {
+		id := ""
+		if r.GroupID != nil {
+			id = strconv.FormatInt(*r.GroupID, 10)
+		}
+		cw.Write([]string{
+			r.Period,
+			id,
+			r.Group,
+			strconv.FormatInt(r.Tasks, 10),
+			strconv.FormatFloat(r.Credits, 'f', 2, 64),
+			strconv.FormatInt(r.StorageBytes, 10),
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("[WARN] Failed to write usage report CSV: %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
+)
+
+// GCRunResponse is one storage GC run report
+type GCRunResponse struct {
+	ID                 int64                                `json:"id"`
+	Mode               string                               `json:"mode"`
+	DryRun             bool                                 `json:"dry_run"`
+	ScannedObjects     int64                                `json:"scanned_objects"`
+	ScannedBytes       int64                                `json:"scanned_bytes"`
+	OrphanedObjects    int64                                `json:"orphaned_objects"`
+	OrphanedBytes      int64                                `json:"orphaned_bytes"`
+	QuarantinedObjects int64                                `json:"quarantined_objects"`
+	QuarantinedBytes   int64                                `json:"quarantined_bytes"`
+	DeletedObjects     int64                                `json:"deleted_objects"`
+	ReclaimedBytes     int64                                `json:"reclaimed_bytes"`
+	ExpiredMedia       int                                  `json:"expired_media"`
+	Failures           int                                  `json:"failures"`
+	Prefixes           map[string]*repository.GCPrefixStats `json:"prefixes,omitempty"`
+	Error              string                               `json:"error,omitempty"`
+	StartedAt          time.Time                            `json:"started_at"`
+	FinishedAt         *time.Time                           `json:"finished_at,omitempty"`
+}
+
+type RestoreQuarantinedRequest struct {
+	Key string `json:"key" binding:"required"`
+}
+
+// GetStorageGCReport returns recent GC runs and the space reclaimed over a window
+// Query: limit (default 20), days (totals window, default 30)
+func GetStorageGCReport(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if days < 1 {
+		days = 30
+	}
+
+	ctx := c.Request.Context()
+	runs, err := repository.ListGCRuns(ctx, limit)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list GC runs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load GC report"})
+		return
+	}
+	totals, err := repository.SumGCRuns(ctx, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		log.Printf("[ERROR] Failed to sum GC runs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load GC report"})
+		return
+	}
+
+	items := make([]GCRunResponse, 0, len(runs))
+	for _, r := range runs {
+		items = append(items, toGCRunResponse(&r))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"running": service.IsStorageGCRunning(),
+		"totals": gin.H{
+			"days":                days,
+			"runs":                totals.Runs,
+			"deleted_objects":     totals.DeletedObjects,
+			"reclaimed_bytes":     totals.ReclaimedBytes,
+			"quarantined_objects": totals.QuarantinedObjects,
+			"quarantined_bytes":   totals.QuarantinedBytes,
+		},
+		"runs": items,
+	})
+}
+
+// RunStorageGC starts a GC run in the background
+// Query: dry_run (bool), mode (quarantine|delete, defaults to GC_MODE)
+func RunStorageGC(c *gin.Context) {
+	gc := service.NewStorageGC()
+	gc.DryRun, _ = strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+	if mode := c.Query("mode"); mode != "" {
+		gc.Mode = mode
+	}
+
+	if err := gc.Start(); err != nil {
+		if errors.Is(err, service.ErrGCRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "started",
+		"mode":    gc.Mode,
+		"dry_run": gc.DryRun,
+	})
+}
+
+// RestoreQuarantined moves a quarantined object back to its original key
+func RestoreQuarantined(c *gin.Context) {
+	var req RestoreQuarantinedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if err := service.RestoreQuarantined(c.Request.Context(), req.Key); err != nil {
+		if errors.Is(err, service.ErrObjectNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Quarantined object not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "restored"})
+}
+
+func toGCRunResponse(r *repository.GCRun) GCRunResponse {
+	resp := GCRunResponse{
+		ID:                 r.ID,
+		Mode:               r.Mode,
+		DryRun:             r.DryRun,
+		ScannedObjects:     r.ScannedObjects,
+		ScannedBytes:       r.ScannedBytes,
+		OrphanedObjects:    r.OrphanedObjects,
+		OrphanedBytes:      r.OrphanedBytes,
+		QuarantinedObjects: r.QuarantinedObjects,
+		QuarantinedBytes:   r.QuarantinedBytes,
+		DeletedObjects:     r.DeletedObjects,
+		ReclaimedBytes:     r.ReclaimedBytes,
+		ExpiredMedia:       r.ExpiredMedia,
+		Failures:           r.Failures,
+		Prefixes:           r.Prefixes,
+		Error:              r.ErrorMessage.String,
+		StartedAt:          r.StartedAt,
+	}
+	if r.FinishedAt.Valid {
+		finishedAt := r.FinishedAt.Time
+		resp.FinishedAt = &finishedAt
+	}
+	return resp
+}
@@ -0,0 +1,299 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
+)
+
+const maxAdminTasksPageSize = 200
+
+// FailTaskRequest is the reason shown to the user as the task's error
+type FailTaskRequest struct {
+	Reason string `json:"reason" binding:"required,max=1000"`
+}
+
+// AdminTaskResponse is one row of the admin task list
+type AdminTaskResponse struct {
+	Type       string     `json:"type"` // detect, swap or transfer
+	TaskID     string     `json:"task_id"`
+	UserID     int64      `json:"user_id"`
+	UserEmail  string     `json:"user_email,omitempty"`
+	Provider   string     `json:"provider"`
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ProviderCallResponse is one recorded provider request
+type ProviderCallResponse struct {
+	ID         int64           `json:"id"`
+	Method     string          `json:"method"`
+	Endpoint   string          `json:"endpoint"`
+	Attempt    int             `json:"attempt"`
+	Version    string          `json:"version,omitempty"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	StatusCode int             `json:"status_code,omitempty"`
+	Response   json.RawMessage `json:"response,omitempty"` // A JSON string when the body isn't JSON
+	Error      string          `json:"error,omitempty"`
+	DurationMS int64           `json:"duration_ms"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// TaskTimings are the durations derived from a task's timestamps, in seconds
+type TaskTimings struct {
+	Total    *float64 `json:"total,omitempty"`    // Creation to completion
+	Provider *float64 `json:"provider,omitempty"` // Creation to the provider's result, before transfer
+	Transfer *float64 `json:"transfer,omitempty"`
+}
+
+// ListAdminTasks lists detect, swap and transfer tasks of all users
+// Query: type, user_id, status, provider, q (error text), from, to (YYYY-MM-DD), page, page_size
+func ListAdminTasks(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "50"))
+	if pageSize < 1 || pageSize > maxAdminTasksPageSize {
+		pageSize = 50
+	}
+
+	filter := repository.AdminTaskFilter{
+		Type:     c.Query("type"),
+		Status:   c.Query("status"),
+		Provider: c.Query("provider"),
+		Error:    strings.TrimSpace(c.Query("q")),
+		Page:     page,
+		PageSize: pageSize,
+	}
+	if filter.Type != "" && filter.Type != "detect" && filter.Type != "swap" && filter.Type != "transfer" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be detect, swap or transfer"})
+		return
+	}
+	filter.UserID, _ = strconv.ParseInt(c.Query("user_id"), 10, 64)
+	var ok bool
+	if filter.From, ok = parseDateParam(c, "from", false); !ok {
+		return
+	}
+	if filter.To, ok = parseDateParam(c, "to", true); !ok {
+		return
+	}
+
+	tasks, total, err := repository.ListAdminTasks(c.Request.Context(), filter)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list admin tasks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tasks"})
+		return
+	}
+
+	items := make([]AdminTaskResponse, 0, len(tasks))
+	for _, t := range tasks {
+		item := AdminTaskResponse{
+			Type:      t.Type,
+			TaskID:    t.TaskID,
+			UserID:    t.UserID,
+			UserEmail: t.UserEmail.String,
+			Provider:  t.Provider,
+			Status:    t.Status.String,
+			Error:     t.Error.String,
+			CreatedAt: t.CreatedAt,
+			UpdatedAt: t.UpdatedAt,
+		}
+		if t.FinishedAt.Valid {
+			finishedAt := t.FinishedAt.Time
+			item.FinishedAt = &finishedAt
+		}
+		items = append(items, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items, "total": total, "page": page, "page_size": pageSize})
+}
+
+// GetAdminTask returns any user's task with its provider calls and timings
+func GetAdminTask(c *gin.Context) {
+	task, ok := loadAdminTask(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, adminTaskDetail(task))
+}
+
+// RefreshAdminTask fetches the task's status from VModel now
+func RefreshAdminTask(c *gin.Context) {
+	task, ok := loadAdminTask(c)
+	if !ok {
+		return
+	}
+	if err := service.RefreshAdminTask(c.Request.Context(), task); err != nil {
+		adminTaskActionError(c, task, "refresh", err)
+		return
+	}
+
+	// Reload to include the call just made
+	if reloaded, err := service.GetAdminTask(c.Request.Context(), task.TaskID()); err == nil && reloaded != nil {
+		task = reloaded
+	}
+	c.JSON(http.StatusOK, adminTaskDetail(task))
+}
+
+// RetransferAdminTask copies the task's result from VModel to storage again
+func RetransferAdminTask(c *gin.Context) {
+	task, ok := loadAdminTask(c)
+	if !ok {
+		return
+	}
+	if err := service.RetransferAdminTask(c.Request.Context(), task); err != nil {
+		adminTaskActionError(c, task, "re-transfer", err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"task_id": task.TaskID(), "transfer_status": "pending"})
+}
+
+// FailAdminTask marks a stuck task failed
+func FailAdminTask(c *gin.Context) {
+	var req FailTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason is required"})
+		return
+	}
+	task, ok := loadAdminTask(c)
+	if !ok {
+		return
+	}
+	if err := service.FailAdminTask(c.Request.Context(), task, strings.TrimSpace(req.Reason)); err != nil {
+		adminTaskActionError(c, task, "mark failed", err)
+		return
+	}
+	c.JSON(http.StatusOK, adminTaskDetail(task))
+}
+
+func loadAdminTask(c *gin.Context) (*service.AdminTask, bool) {
+	task, err := service.GetAdminTask(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		log.Printf("[ERROR] Failed to load task %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task"})
+		return nil, false
+	}
+	if task == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return nil, false
+	}
+	return task, true
+}
+
+func adminTaskActionError(c *gin.Context, task *service.AdminTask, action string, err error) {
+	switch {
+	case errors.Is(err, service.ErrTaskNotSubmitted), errors.Is(err, service.ErrNoProviderResult),
+		errors.Is(err, service.ErrTransferInProgress), errors.Is(err, service.ErrTaskFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		log.Printf("[ERROR] Failed to %s task %s: %v", action, task.TaskID(), err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to " + action + " task: " + err.Error()})
+	}
+}
+
+func adminTaskDetail(t *service.AdminTask) gin.H {
+	calls := make([]ProviderCallResponse, 0, len(t.Calls))
+	for _, pc := range t.Calls {
+		call := ProviderCallResponse{
+			ID:         pc.ID,
+			Method:     pc.Method,
+			Endpoint:   pc.Endpoint,
+			Attempt:    pc.Attempt,
+			Version:    pc.Version.String,
+			Request:    pc.Request,
+			StatusCode: int(pc.StatusCode.Int32),
+			Error:      pc.Error.String,
+			DurationMS: pc.DurationMS,
+			CreatedAt:  pc.CreatedAt,
+		}
+		if pc.Response.Valid {
+			if json.Valid([]byte(pc.Response.String)) {
+				call.Response = json.RawMessage(pc.Response.String)
+			} else {
+				call.Response, _ = json.Marshal(pc.Response.String)
+			}
+		}
+		calls = append(calls, call)
+	}
+
+	resp := gin.H{"type": t.Type, "calls": calls}
+	if d := t.Detection; d != nil {
+		resp["task"] = gin.H{
+			"task_id":      d.TaskID,
+			"user_id":      d.UserID,
+			"status":       d.Status,
+			"detect_id":    d.DetectID.String,
+			"media_key":    d.MediaKey.String,
+			"faces":        len(d.Faces),
+			"error":        d.ErrorMessage.String,
+			"created_at":   d.CreatedAt,
+			"updated_at":   d.UpdatedAt,
+			"completed_at": nullTime(d.CompletedAt.Time, d.CompletedAt.Valid),
+		}
+		resp["timings"] = TaskTimings{Total: seconds(d.CreatedAt, d.CompletedAt.Time, d.CompletedAt.Valid)}
+		return resp
+	}
+
+	s := t.Swap
+	resp["task"] = gin.H{
+		"task_id":          s.TaskID,
+		"approval_id":      s.ApprovalID.String,
+		"user_id":          s.UserID,
+		"provider":         s.Model,
+		"status":           s.Status,
+		"error":            s.ErrorMessage.String,
+		"detect_id":        s.DetectID.String,
+		"batch_id":         s.BatchID.String,
+		"target_video_key": s.TargetVideoKey.String,
+		"source_face_keys": s.SourceFaceKeys,
+		"face_ids":         s.FaceIDs,
+		"face_enhance":     s.FaceEnhance,
+		"credits":          s.CreditsUsed,
+		"result_key":       s.ResultKey.String,
+		"result_size":      s.ResultSize.Int64,
+		"created_at":       s.CreatedAt,
+		"updated_at":       s.UpdatedAt,
+		"completed_at":     nullTime(s.CompletedAt.Time, s.CompletedAt.Valid),
+		"transfer": gin.H{
+			"status":      s.TransferStatus.String,
+			"error":       s.TransferError.String,
+			"started_at":  nullTime(s.TransferStart.Time, s.TransferStart.Valid),
+			"finished_at": nullTime(s.TransferEnd.Time, s.TransferEnd.Valid),
+		},
+	}
+	if t.Transfer != nil {
+		resp["transfer_cache"] = gin.H{"status": t.Transfer.Status, "error": t.Transfer.Error}
+	}
+	resp["timings"] = TaskTimings{
+		Total:    seconds(s.CreatedAt, s.CompletedAt.Time, s.CompletedAt.Valid),
+		Provider: seconds(s.CreatedAt, s.TransferStart.Time, s.TransferStart.Valid),
+		Transfer: seconds(s.TransferStart.Time, s.TransferEnd.Time, s.TransferStart.Valid && s.TransferEnd.Valid),
+	}
+	return resp
+}
+
+func nullTime(t time.Time, valid bool) *time.Time {
+	if !valid {
+		return nil
+	}
+	return &t
+}
+
+func seconds(from, to time.Time, valid bool) *float64 {
+	if !valid || to.Before(from) {
+		return nil
+	}
+	d := to.Sub(from).Seconds()
+	return &d
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/repository"
+)
+
+// TeamRequest creates or updates a team. storage_quota is in bytes; -1 resets it to the default.
+type TeamRequest struct {
+	Name         *string `json:"name"`
+	StorageQuota *int64  `json:"storage_quota"`
+}
+
+// UserStorageRequest moves a user between teams (team_id 0 removes them from
+// their team) and sets their quota in bytes (-1 resets it to the default)
+type UserStorageRequest struct {
+	TeamID       *int64 `json:"team_id"`
+	StorageQuota *int64 `json:"storage_quota"`
+}
+
+type TeamResponse struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	StorageQuota *int64    `json:"storage_quota,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// ListTeams returns all teams
+func ListTeams(c *gin.Context) {
+	teams, err := repository.ListTeams(c.Request.Context())
+	if err != nil {
+		log.Printf("[ERROR] Failed to list teams: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list teams"})
+		return
+	}
+
+	items := make([]TeamResponse, 0, len(teams))
+	for _, t := range teams {
+		items = append(items, toTeamResponse(&t))
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// CreateTeam creates a team
+func CreateTeam(c *gin.Context) {
+	var req TeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+
+	var quota sql.NullInt64
+	if q := quotaParam(req.StorageQuota); q != nil {
+		quota = *q
+	}
+	team, err := repository.CreateTeam(c.Request.Context(), strings.TrimSpace(*req.Name), quota)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create team: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create team"})
+		return
+	}
+	if team == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database not configured"})
+		return
+	}
+	c.JSON(http.StatusCreated, toTeamResponse(team))
+}
+
+// UpdateTeam renames a team or changes its storage quota
+func UpdateTeam(c *gin.Context) {
+	teamID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid team ID"})
+		return
+	}
+
+	var req TeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+			return
+		}
+		req.Name = &name
+	}
+
+	ctx := c.Request.Context()
+	team, err := repository.GetTeam(ctx, teamID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load team %d: %v", teamID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team"})
+		return
+	}
+	if team == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Team not found"})
+		return
+	}
+
+	if err := repository.UpdateTeam(ctx, teamID, req.Name, quotaParam(req.StorageQuota)); err != nil {
+		log.Printf("[ERROR] Failed to update team %d: %v", teamID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update team"})
+		return
+	}
+
+	team, _ = repository.GetTeam(ctx, teamID)
+	c.JSON(http.StatusOK, toTeamResponse(team))
+}
+
+// UpdateUserStorage assigns a user's team and storage quota
+func UpdateUserStorage(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req UserStorageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := repository.GetUserByID(ctx, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	var teamID *sql.NullInt64
+	if req.TeamID != nil {
+		teamID = &sql.NullInt64{Int64: *req.TeamID, Valid: *req.TeamID > 0}
+		if teamID.Valid {
+			team, err := repository.GetTeam(ctx, *req.TeamID)
+			if err != nil || team == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Team not found"})
+				return
+			}
+		}
+	}
+
+	if err := repository.SetUserStorage(ctx, userID, teamID, quotaParam(req.StorageQuota)); err != nil {
+		log.Printf("[ERROR] Failed to update user %d storage settings: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "updated"})
+}
+
+// quotaParam maps a request quota to the repository's update semantics: nil keeps
+// the current value, a negative value resets to the configured default
+func quotaParam(q *int64) *sql.NullInt64 {
+	if q == nil {
+		return nil
+	}
+	return &sql.NullInt64{Int64: *q, Valid: *q >= 0}
+}
+
+func toTeamResponse(t *repository.Team) TeamResponse {
+	resp := TeamResponse{ID: t.ID, Name: t.Name, CreatedAt: t.CreatedAt}
+	if t.StorageQuota.Valid {
+		quota := t.StorageQuota.Int64
+		resp.StorageQuota = &quota
+	}
+	return resp
+}
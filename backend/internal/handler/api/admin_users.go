@@ -0,0 +1,149 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
+)
+
+// CreateUserRequest adds a user. Role defaults to member; without a password
+// the user signs in with email codes or sets one through a password reset.
+type CreateUserRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Role     string `json:"role"`
+	Password string `json:"password"`
+}
+
+// UserRoleRequest assigns a role: admin, member or viewer
+type UserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type UserResponse struct {
+	ID          int64      `json:"id"`
+	Email       string     `json:"email"`
+	Role        string     `json:"role"`
+	HasPassword bool       `json:"has_password"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// ListUsers returns all users with their roles
+// Query: role (optional)
+func ListUsers(c *gin.Context) {
+	role := c.Query("role")
+	if role != "" && !repository.ValidRole(role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be admin, member or viewer"})
+		return
+	}
+
+	users, err := repository.ListUsers(c.Request.Context(), role)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list users: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+		return
+	}
+
+	items := make([]UserResponse, 0, len(users))
+	for _, u := range users {
+		items = append(items, toUserResponse(&u))
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// CreateUser adds a user account
+func CreateUser(c *gin.Context) {
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if req.Role == "" {
+		req.Role = repository.RoleMember
+	}
+	if !repository.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be admin, member or viewer"})
+		return
+	}
+
+	adminID := middleware.GetUserID(c)
+	user, err := service.CreateUserAccount(c.Request.Context(), req.Email, req.Role, req.Password, adminID)
+	switch {
+	case errors.Is(err, service.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "A user with this email already exists"})
+		return
+	case errors.Is(err, service.ErrAccountsUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database not configured"})
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to create user %s: %v", req.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
+		return
+	}
+	log.Printf("[INFO] User %d created user %d (%s) as %s", adminID, user.ID, user.Email, user.Role)
+	c.JSON(http.StatusCreated, toUserResponse(user))
+}
+
+// UpdateUserRole assigns a user's role. Admins can't change their own role,
+// so there is always at least one admin left.
+func UpdateUserRole(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req UserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !repository.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be admin, member or viewer"})
+		return
+	}
+	if userID == middleware.GetUserID(c) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot change your own role"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	found, err := repository.SetUserRole(ctx, userID, req.Role)
+	if err != nil {
+		log.Printf("[ERROR] Failed to set role of user %d: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	log.Printf("[INFO] User %d set the role of user %d to %s", middleware.GetUserID(c), userID, req.Role)
+
+	user, err := repository.GetUserByID(ctx, userID)
+	if err != nil || user == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "updated"})
+		return
+	}
+	c.JSON(http.StatusOK, toUserResponse(user))
+}
+
+func toUserResponse(u *repository.User) UserResponse {
+	resp := UserResponse{
+		ID:          u.ID,
+		Email:       u.Email,
+		Role:        u.Role,
+		HasPassword: u.PasswordHash.Valid,
+		CreatedAt:   u.CreatedAt,
+	}
+	if u.LastLoginAt.Valid {
+		resp.LastLoginAt = &u.LastLoginAt.Time
+	}
+	return resp
+}
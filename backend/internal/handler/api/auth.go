@@ -1,15 +1,13 @@
 package api
 
 import (
-	"context"
-	"crypto/rand"
-	"encoding/hex"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"playplus_platform/internal/repository"
 	"playplus_platform/internal/service"
 )
 
@@ -22,58 +20,99 @@ type VerifyCodeRequest struct {
 	Code  string `json:"code" binding:"required,len=6"`
 }
 
+// LoginRequest signs in with an email and password. username is accepted as
+// an alias of email.
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
+	Email    string `json:"email"`
+	Username string `json:"username"`
 	Password string `json:"password" binding:"required"`
 }
 
-// Fixed credentials for development
-const (
-	fixedUsername = "test"
-	fixedPassword = "test"
-)
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Code     string `json:"code" binding:"required,len=6"`
+	Password string `json:"password" binding:"required"`
+}
 
-// Login handles username/password login
+// Login handles email/password login
 func Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
 		return
 	}
+	login := strings.TrimSpace(firstNonEmpty(req.Email, req.Username))
+	if login == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
 
-	// Check fixed credentials
-	if req.Username != fixedUsername || req.Password != fixedPassword {
+	token, user, err := service.LoginWithPassword(c.Request.Context(), login, req.Password)
+	if errors.Is(err, service.ErrInvalidCredentials) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
 		return
 	}
+	if err != nil {
+		log.Printf("[ERROR] Password login failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
 
-	// Generate a simple token
-	tokenBytes := make([]byte, 32)
-	rand.Read(tokenBytes)
-	token := hex.EncodeToString(tokenBytes)
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"user":  user,
+	})
+}
 
-	// Save session to database if available
-	if repository.IsDBAvailable() {
-		ctx := context.Background()
-		// Create or get user (use username as email for test user)
-		userID, err := repository.CreateOrGetUser(ctx, req.Username+"@test.local")
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
-			return
-		}
+// ForgotPassword mails a password reset code. The response is the same whether
+// or not the email has an account.
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
 
-		// Create session with 7-day expiry
-		expiresAt := time.Now().Add(7 * 24 * time.Hour)
-		if err := repository.CreateSession(ctx, userID, token, expiresAt); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+	if err := service.SendPasswordResetCode(c.Request.Context(), req.Email); err != nil {
+		if errors.Is(err, service.ErrAccountsUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database not configured"})
 			return
 		}
+		log.Printf("[ERROR] Failed to send password reset code: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send reset code"})
+		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "If the email has an account, a reset code was sent"})
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user":  req.Username,
-	})
+// ResetPassword sets a new password with the emailed code
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	err := service.ResetPassword(c.Request.Context(), req.Email, req.Code, req.Password, c.ClientIP())
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Password updated, please sign in again"})
+	case errors.Is(err, service.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrCodeLocked):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many wrong codes, please request a new one"})
+	case errors.Is(err, service.ErrInvalidCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired code"})
+	case errors.Is(err, service.ErrAccountsUnavailable):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Database not configured"})
+	default:
+		log.Printf("[ERROR] Failed to reset password: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+	}
 }
 
 // SendVerificationCode sends a verification code to the email
@@ -84,13 +123,27 @@ func SendVerificationCode(c *gin.Context) {
 		return
 	}
 
-	// Validate email domain
-	if !strings.HasSuffix(strings.ToLower(req.Email), "@playerplus.cn") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Only @playerplus.cn emails are allowed"})
+	// Allowed domains, existing accounts and invited emails only
+	allowed, err := service.CanRequestCode(c.Request.Context(), req.Email)
+	if err != nil {
+		log.Printf("[ERROR] Failed to check sign-in permission for %s: %v", req.Email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This email is not allowed to sign in, please ask an admin for an invitation"})
 		return
 	}
 
 	if err := service.SendVerificationCode(req.Email); err != nil {
+		var cooldown *service.CooldownError
+		if errors.As(err, &cooldown) {
+			retryAfter := int(cooldown.RetryAfter.Seconds()) + 1
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "A code was sent recently, please wait before requesting another", "retry_after": retryAfter})
+			return
+		}
+		log.Printf("[ERROR] Failed to send verification code to %s: %v", req.Email, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification code"})
 		return
 	}
@@ -106,7 +159,15 @@ func VerifyCode(c *gin.Context) {
 		return
 	}
 
-	token, err := service.VerifyCode(req.Email, req.Code)
+	token, err := service.VerifyCode(req.Email, req.Code, c.ClientIP())
+	if errors.Is(err, service.ErrCodeLocked) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many wrong codes, please request a new one"})
+		return
+	}
+	if errors.Is(err, service.ErrEmailNotAllowed) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This email is not allowed to sign in, please ask an admin for an invitation"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired code"})
 		return
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/service"
+)
+
+// DownloadTaskResult streams a task's result from storage with Range support,
+// named after the original upload. Query: inline=1 to play instead of download.
+func DownloadTaskResult(c *gin.Context) {
+	task, ok := loadOwnedTask(c)
+	if !ok {
+		return
+	}
+	if !task.ResultKey.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Result is not available yet"})
+		return
+	}
+
+	filename := service.ResultFilename(c.Request.Context(), task)
+	serveStoredObject(c, task.ResultKey.String, filename, service.ResultTime(task), c.Query("inline") != "1")
+}
+
+// serveStoredObject streams an object with Range and conditional request support
+func serveStoredObject(c *gin.Context, key, filename string, modTime time.Time, attachment bool) {
+	obj, _, err := service.GetStorageService().Open(c.Request.Context(), key)
+	if errors.Is(err, service.ErrObjectNotFound) {
+		c.String(http.StatusNotFound, "File not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to open %s: %v", key, err)
+		c.String(http.StatusInternalServerError, "Failed to open file")
+		return
+	}
+	defer obj.Close()
+
+	disposition := "inline"
+	if attachment {
+		disposition = "attachment"
+	}
+	// Objects are never rewritten under the same key, so the key identifies the content
+	sum := sha256.Sum256([]byte(key))
+	c.Header("ETag", `"`+hex.EncodeToString(sum[:8])+`"`)
+	c.Header("Content-Disposition", contentDisposition(disposition, filename))
+	http.ServeContent(c.Writer, c.Request, filename, modTime, obj)
+}
+
+// contentDisposition builds the header value with an ASCII fallback name for
+// old clients and the RFC 5987 encoded UTF-8 name for everyone else
+func contentDisposition(disposition, filename string) string {
+	encoded := mime.FormatMediaType(disposition, map[string]string{"filename": filename})
+	ascii := true
+	for _, r := range filename {
+		if r >= 0x80 {
+			ascii = false
+			break
+		}
+	}
+	if ascii || encoded == "" {
+		return encoded
+	}
+
+	fallback := strings.Map(func(r rune) rune {
+		if r >= 0x80 {
+			return '_'
+		}
+		return r
+	}, filename)
+	return disposition + `; filename="` + fallback + `"; ` + strings.TrimPrefix(encoded, disposition+"; ")
+}
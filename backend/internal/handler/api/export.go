@@ -0,0 +1,81 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
+)
+
+// ExportResultsRequest selects the tasks to export: either task_ids or batch_id
+type ExportResultsRequest struct {
+	TaskIDs []string `json:"task_ids"`
+	BatchID string   `json:"batch_id"`
+}
+
+// ExportTaskResults streams a zip of task results with a manifest.csv.
+// Tasks without a result, or missing ones, are listed in the manifest.
+func ExportTaskResults(c *gin.Context) {
+	var req ExportResultsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if (len(req.TaskIDs) == 0) == (req.BatchID == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Specify either task_ids or batch_id"})
+		return
+	}
+	if len(req.TaskIDs) > service.MaxExportTasks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many tasks, at most " + strconv.Itoa(service.MaxExportTasks) + " per export"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := middleware.GetUserID(c)
+
+	var tasks []repository.SwapTask
+	var err error
+	if req.BatchID != "" {
+		tasks, err = repository.GetSwapTasksByBatch(ctx, userID, req.BatchID, service.MaxExportTasks)
+	} else {
+		tasks, err = repository.GetSwapTasksByIDs(ctx, userID, req.TaskIDs)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to load tasks for export: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tasks"})
+		return
+	}
+	if len(tasks) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No tasks found"})
+		return
+	}
+
+	name := "faceswap_export_" + time.Now().Format("20060102-1504") + ".zip"
+	if req.BatchID != "" {
+		name = "faceswap_" + req.BatchID + ".zip"
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", contentDisposition("attachment", name))
+	c.Header("Cache-Control", "private, no-store")
+	c.Status(http.StatusOK)
+
+	// The response has started, errors from here on can only be logged
+	results, err := service.WriteResultsZip(ctx, c.Writer, tasks, req.TaskIDs)
+	if err != nil {
+		log.Printf("[ERROR] Export for user %d aborted after %d tasks: %v", userID, len(results), err)
+		return
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.File == "" {
+			failed++
+		}
+	}
+	log.Printf("[INFO] User %d exported %d results (%d skipped)", userID, len(results)-failed, failed)
+}
@@ -6,11 +6,14 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"playplus_platform/internal/config"
+	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/repository"
 	"playplus_platform/internal/service"
 )
 
 type DetectFacesRequest struct {
-	ImageURL string `json:"image_url" binding:"required"`
+	ImageKey string `json:"image_key"` // Storage key from an upload response (preferred)
+	ImageURL string `json:"image_url"` // Legacy: URL from an upload response
 }
 
 type DetectedFaceResponse struct {
@@ -48,10 +51,36 @@ func DetectFaces(c *gin.Context) {
 		return
 	}
 
+	if req.ImageKey == "" && req.ImageURL == "" {
+		c.JSON(http.StatusBadRequest, DetectFacesResponse{
+			Code: 400,
+			Msg:  "Invalid request: image_key or image_url is required",
+		})
+		return
+	}
+
+	// Only files stored here may be sent for detection
+	ref := req.ImageKey
+	if ref == "" {
+		ref = req.ImageURL
+	}
+	key, err := service.UserMediaKey(c.Request.Context(), middleware.GetUserID(c), ref)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, DetectFacesResponse{
+			Code: 400,
+			Msg:  "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if !checkVideoLimits(c, key) {
+		return
+	}
+
 	cfg := config.Get()
 
 	if cfg.IsVModelConfigured() {
-		detectWithVModel(c, req.ImageURL)
+		detectWithVModel(c, key)
 		return
 	}
 
@@ -68,12 +97,48 @@ func DetectFaces(c *gin.Context) {
 	})
 }
 
-// detectWithVModel starts async face detection using VModel API
-func detectWithVModel(c *gin.Context, imageURL string) {
-	// Convert CDN URL to direct storage URL for VModel API
+// checkVideoLimits rejects videos over the configured duration/resolution before
+// any paid detection is started. Videos whose metadata can't be read are rejected
+// too, since their length is unknown.
+func checkVideoLimits(c *gin.Context, key string) bool {
+	meta, err := service.VideoMetaForKey(c.Request.Context(), key)
+	if err != nil {
+		log.Printf("[WARN] Failed to read video metadata for %s: %v", key, err)
+		c.JSON(http.StatusUnprocessableEntity, DetectFacesResponse{
+			Code: 422,
+			Msg:  "Failed to read video metadata: " + err.Error(),
+		})
+		return false
+	}
+	if meta == nil {
+		return true
+	}
+
+	if err := service.CheckVideoLimits(meta); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, DetectFacesResponse{
+			Code: 422,
+			Msg:  err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// detectWithVModel starts async face detection of a stored file using VModel API
+func detectWithVModel(c *gin.Context, key string) {
 	storage := service.GetStorageService()
-	directURL := storage.ConvertToDirectURL(imageURL)
-	log.Printf("[DEBUG] detectWithVModel: CDN=%s, Direct=%s", imageURL, directURL)
+	directURL, err := storage.GetExternalURL(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, DetectFacesResponse{
+			Code: 500,
+			Msg:  "Failed to resolve media URL: " + err.Error(),
+		})
+		return
+	}
+	imageURL, err := storage.GetAccessURL(c.Request.Context(), key)
+	if err != nil {
+		imageURL = directURL
+	}
 
 	vmodel := service.GetVModelClient()
 	result, err := vmodel.CreateDetectTask(c.Request.Context(), directURL)
@@ -87,6 +152,8 @@ func detectWithVModel(c *gin.Context, imageURL string) {
 	}
 
 	log.Printf("[INFO] Face detection task created: %s", result.TaskID)
+	service.RecordDetection(c.Request.Context(), middleware.GetUserID(c), result.TaskID, result.Status, key)
+	service.RecordDetectCredits(c.Request.Context(), middleware.GetUserID(c), result.TaskID, float64(result.TaskCost))
 	c.JSON(http.StatusOK, DetectFacesResponse{
 		Code: 0,
 		Data: &DetectFacesResponseData{
@@ -126,6 +193,29 @@ func GetFaceDetectStatus(c *gin.Context) {
 		return
 	}
 
+	detection, err := repository.GetFaceDetection(c.Request.Context(), taskID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load face detection %s: %v", taskID, err)
+		c.JSON(http.StatusInternalServerError, DetectFacesResponse{
+			Code: 500,
+			Msg:  "Failed to get detection status",
+		})
+		return
+	}
+	if repository.IsDBAvailable() && (detection == nil || detection.UserID != middleware.GetUserID(c)) {
+		c.JSON(http.StatusNotFound, DetectFacesResponse{
+			Code: 404,
+			Msg:  "Detection task not found",
+		})
+		return
+	}
+
+	// Finished detections are served from the record without asking VModel again
+	if detection != nil && service.DetectionFinished(detection) {
+		c.JSON(http.StatusOK, detectionResponse(detection))
+		return
+	}
+
 	vmodel := service.GetVModelClient()
 	result, err := vmodel.GetDetectTaskStatus(c.Request.Context(), taskID)
 
@@ -138,6 +228,9 @@ func GetFaceDetectStatus(c *gin.Context) {
 		})
 		return
 	}
+	if detection != nil {
+		service.UpdateDetection(c.Request.Context(), detection, result)
+	}
 
 	// Queuing/processing - client should continue polling
 	if result.Status == "queuing" || result.Status == "processing" {
@@ -189,33 +282,56 @@ func GetFaceDetectStatus(c *gin.Context) {
 	})
 }
 
+// detectionResponse builds the status response of a finished detection record
+func detectionResponse(d *repository.FaceDetection) DetectFacesResponse {
+	faces := make([]DetectedFaceResponse, len(d.Faces))
+	for i, f := range d.Faces {
+		faces[i] = DetectedFaceResponse{
+			Index:     i,
+			FaceID:    f.FaceID,
+			Thumbnail: f.Thumbnail,
+		}
+	}
+	return DetectFacesResponse{
+		Code: 0,
+		Data: &DetectFacesResponseData{
+			TaskID:   d.TaskID,
+			Status:   d.Status,
+			Faces:    faces,
+			DetectID: d.DetectID.String,
+		},
+		Msg: d.ErrorMessage.String,
+	}
+}
+
 // DetectFacesFromUpload handles face detection from uploaded image (form-data)
 func DetectFacesFromUpload(c *gin.Context) {
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, DetectFacesResponse{
-			Code: 400,
-			Msg:  "No file uploaded",
+	file, status, msg := receiveFile(c, service.MediaCategoryFrame)
+	if file == nil {
+		c.JSON(status, DetectFacesResponse{
+			Code: status,
+			Msg:  msg,
 		})
 		return
 	}
 
 	// Upload the frame first
-	storage := service.GetStorageService()
-	key := storage.GenerateKey("frames", file.Filename)
-	url, err := storage.Upload(c.Request.Context(), key, file)
+	media, err := service.StoreUpload(c.Request.Context(), middleware.GetUserID(c), service.MediaCategoryFrame, file)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, DetectFacesResponse{
-			Code: 500,
-			Msg:  "Failed to upload frame: " + err.Error(),
+		status, msg := uploadError(err, service.MediaCategoryFrame)
+		c.JSON(status, DetectFacesResponse{
+			Code: status,
+			Msg:  msg,
 		})
 		return
 	}
+	key := media.Key
+	url, _ := service.GetStorageService().GetAccessURL(c.Request.Context(), key)
 
 	cfg := config.Get()
 
 	if cfg.IsVModelConfigured() {
-		detectWithVModel(c, url)
+		detectWithVModel(c, key)
 		return
 	}
 
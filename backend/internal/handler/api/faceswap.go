@@ -1,25 +1,35 @@
 package api
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"playplus_platform/internal/config"
+	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/repository"
 	"playplus_platform/internal/service"
 )
 
 // --- Request/Response Types ---
 
 type FaceSwapPairRequest struct {
-	SourceImageURL string `json:"source_image_url" binding:"required"` // New face image URL
-	FaceID         int    `json:"face_id"`                             // VModel: target face ID
+	SourceImageKey string `json:"source_image_key"` // New face storage key (preferred)
+	SourceImageURL string `json:"source_image_url"` // New face image URL
+	FaceID         int    `json:"face_id"`          // VModel: target face ID
 }
 
 type CreateFaceSwapRequest struct {
-	TargetVideoURL string                `json:"target_video_url" binding:"required"`
+	TargetVideoKey string                `json:"target_video_key"`
+	TargetVideoURL string                `json:"target_video_url"`
 	DetectID       string                `json:"detect_id" binding:"required"`
 	FaceSwaps      []FaceSwapPairRequest `json:"face_swaps" binding:"required,min=1"`
 	FaceEnhance    bool                  `json:"face_enhance"`
+	BatchID        string                `json:"batch_id" binding:"max=64"` // Optional, groups tasks for export
 }
 
 type CreateFaceSwapResponse struct {
@@ -31,6 +41,24 @@ type CreateFaceSwapResponse struct {
 	Msg string `json:"msg,omitempty"`
 }
 
+type EstimateFaceSwapResponse struct {
+	Code int                   `json:"code"`
+	Data *FaceSwapEstimateData `json:"data,omitempty"`
+	Msg  string                `json:"msg,omitempty"`
+}
+
+type FaceSwapEstimateData struct {
+	Version          string   `json:"version"`
+	Duration         float64  `json:"duration"` // Video duration, seconds
+	Faces            int      `json:"faces"`
+	Credits          float64  `json:"credits"`
+	ProcessingTime   int      `json:"processing_time"` // Estimated seconds
+	QuotaAllowed     bool     `json:"quota_allowed"`
+	CreditsUsed      float64  `json:"credits_used"`                // This month
+	CreditsRemaining *float64 `json:"credits_remaining,omitempty"` // Omitted when unlimited
+	DurationUnknown  bool     `json:"duration_unknown,omitempty"`  // Priced at the VIDEO_MAX_DURATION worst case
+}
+
 type GetTaskStatusResponse struct {
 	Code int    `json:"code"`
 	Data *struct {
@@ -57,6 +85,14 @@ func CreateFaceSwapTask(c *gin.Context) {
 		return
 	}
 
+	if msg := resolveSwapKeys(c.Request.Context(), middleware.GetUserID(c), &req); msg != "" {
+		c.JSON(http.StatusBadRequest, CreateFaceSwapResponse{
+			Code: 400,
+			Msg:  "Invalid request: " + msg,
+		})
+		return
+	}
+
 	cfg := config.Get()
 
 	if cfg.IsVModelConfigured() {
@@ -78,31 +114,200 @@ func CreateFaceSwapTask(c *gin.Context) {
 	})
 }
 
+// resolveSwapKeys resolves inputs to storage keys, returns an error message if
+// inputs are missing or aren't the user's files stored here
+func resolveSwapKeys(ctx context.Context, userID int64, req *CreateFaceSwapRequest) string {
+	var err error
+	if req.TargetVideoKey == "" && req.TargetVideoURL == "" {
+		return "target_video_key or target_video_url is required"
+	}
+	if req.TargetVideoKey, err = service.UserMediaKey(ctx, userID, firstNonEmpty(req.TargetVideoKey, req.TargetVideoURL)); err != nil {
+		return "target video: " + err.Error()
+	}
+
+	for i := range req.FaceSwaps {
+		swap := &req.FaceSwaps[i]
+		if swap.SourceImageKey == "" && swap.SourceImageURL == "" {
+			return "source_image_key or source_image_url is required"
+		}
+		if swap.SourceImageKey, err = service.UserMediaKey(ctx, userID, firstNonEmpty(swap.SourceImageKey, swap.SourceImageURL)); err != nil {
+			return "source image: " + err.Error()
+		}
+		// Original face uploads are swapped using their normalized variant
+		swap.SourceImageKey = service.SwapFaceKey(ctx, swap.SourceImageKey)
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// EstimateFaceSwap prices a face swap request without submitting it
+func EstimateFaceSwap(c *gin.Context) {
+	var req CreateFaceSwapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, EstimateFaceSwapResponse{
+			Code: 400,
+			Msg:  "Invalid request: " + err.Error(),
+		})
+		return
+	}
+	if msg := resolveSwapKeys(c.Request.Context(), middleware.GetUserID(c), &req); msg != "" {
+		c.JSON(http.StatusBadRequest, EstimateFaceSwapResponse{
+			Code: 400,
+			Msg:  "Invalid request: " + msg,
+		})
+		return
+	}
+
+	// Priced like createSwapWithVModel prices it: the worst case when the duration is unknown
+	est, err := service.EstimateSwap(c.Request.Context(), req.TargetVideoKey, len(req.FaceSwaps), req.FaceEnhance)
+	if errors.Is(err, service.ErrDurationUnknown) {
+		est, err = service.WorstCaseSwapEstimate(len(req.FaceSwaps), req.FaceEnhance)
+	}
+	if errors.Is(err, service.ErrDurationUnknown) {
+		c.JSON(http.StatusUnprocessableEntity, EstimateFaceSwapResponse{
+			Code: 422,
+			Msg:  "Cannot estimate: target video duration is unknown",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to estimate swap of %s: %v", req.TargetVideoKey, err)
+		c.JSON(http.StatusInternalServerError, EstimateFaceSwapResponse{
+			Code: 500,
+			Msg:  "Failed to estimate task",
+		})
+		return
+	}
+
+	usage, err := service.GetCreditUsage(c.Request.Context(), middleware.GetUserID(c))
+	if err != nil {
+		log.Printf("[ERROR] Failed to load credit usage: %v", err)
+		c.JSON(http.StatusInternalServerError, EstimateFaceSwapResponse{
+			Code: 500,
+			Msg:  "Failed to load credit usage",
+		})
+		return
+	}
+
+	data := &FaceSwapEstimateData{
+		Version:         est.Version,
+		Duration:        est.Duration,
+		Faces:           est.Faces,
+		Credits:         est.Credits,
+		ProcessingTime:  int(est.ProcessingTime.Seconds()),
+		QuotaAllowed:    usage.Allows(est.Credits),
+		CreditsUsed:     usage.Used,
+		DurationUnknown: est.DurationUnknown,
+	}
+	if usage.Quota > 0 {
+		remaining := usage.Remaining()
+		data.CreditsRemaining = &remaining
+	}
+	c.JSON(http.StatusOK, EstimateFaceSwapResponse{Code: 0, Data: data})
+}
+
 // createSwapWithVModel creates swap task using VModel API
 func createSwapWithVModel(c *gin.Context, req *CreateFaceSwapRequest) {
-	// Get storage service for URL conversion
-	storage := service.GetStorageService()
+	if !validateSwapFaces(c, req) {
+		return
+	}
 
-	// Build face swap pairs with direct URLs for VModel access
-	faceSwaps := make([]service.VModelFaceSwapPair, len(req.FaceSwaps))
+	// Every task is checked against the quota. Videos without a known duration
+	// are priced at the worst case, and refused when there is none.
+	userID := middleware.GetUserID(c)
+	est, err := service.EstimateSwap(c.Request.Context(), req.TargetVideoKey, len(req.FaceSwaps), req.FaceEnhance)
+	if errors.Is(err, service.ErrDurationUnknown) {
+		est, err = service.WorstCaseSwapEstimate(len(req.FaceSwaps), req.FaceEnhance)
+	}
+	if errors.Is(err, service.ErrDurationUnknown) {
+		c.JSON(http.StatusUnprocessableEntity, CreateFaceSwapResponse{
+			Code: 422,
+			Msg:  "Cannot create task: target video duration is unknown",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to estimate swap of %s: %v", req.TargetVideoKey, err)
+		c.JSON(http.StatusInternalServerError, CreateFaceSwapResponse{
+			Code: 500,
+			Msg:  "Failed to estimate task",
+		})
+		return
+	}
+	estimated := est.Credits
+	if err := service.CheckCreditQuota(c.Request.Context(), userID, estimated); err != nil {
+		c.JSON(http.StatusForbidden, CreateFaceSwapResponse{
+			Code: 403,
+			Msg:  err.Error(),
+		})
+		return
+	}
+
+	faceIDs := make([]string, len(req.FaceSwaps))
+	sourceKeys := make([]string, len(req.FaceSwaps))
 	for i, swap := range req.FaceSwaps {
-		// Convert CDN URL to direct R2 URL for VModel API access
-		directURL := storage.ConvertToDirectURL(swap.SourceImageURL)
-		faceSwaps[i] = service.VModelFaceSwapPair{
-			FaceID: swap.FaceID,
-			Target: directURL,
+		faceIDs[i] = strconv.Itoa(swap.FaceID)
+		sourceKeys[i] = swap.SourceImageKey
+	}
+
+	task := &repository.SwapTask{
+		UserID:         userID,
+		FaceIDs:        faceIDs,
+		Model:          "vmodel",
+		DetectID:       sql.NullString{String: req.DetectID, Valid: true},
+		BatchID:        sql.NullString{String: req.BatchID, Valid: req.BatchID != ""},
+		TargetVideoKey: sql.NullString{String: req.TargetVideoKey, Valid: true},
+		SourceFaceKeys: sourceKeys,
+		StorageBackend: sql.NullString{String: service.GetStorageService().BackendID(), Valid: true},
+		CreditsUsed:    estimated,
+		FaceEnhance:    req.FaceEnhance,
+	}
+	if media, err := repository.GetMediaFileByKey(c.Request.Context(), req.TargetVideoKey); err == nil && media != nil {
+		task.MediaID = media.MediaID
+	}
+
+	// Expensive tasks wait for an admin instead of going to VModel
+	if required, reason := service.ApprovalRequired(c.Request.Context(), userID, req.BatchID, est); required {
+		if err := service.RequestSwapApproval(c.Request.Context(), task, reason); err != nil {
+			log.Printf("[ERROR] Failed to request approval for swap task: %v", err)
+			c.JSON(http.StatusInternalServerError, CreateFaceSwapResponse{
+				Code: 500,
+				Msg:  "Failed to create task",
+			})
+			return
 		}
+		c.JSON(http.StatusAccepted, CreateFaceSwapResponse{
+			Code: 0,
+			Data: &struct {
+				TaskID string `json:"task_id"`
+				Status string `json:"status"`
+			}{
+				TaskID: task.TaskID,
+				Status: task.Status,
+			},
+			Msg: reason + "; the task will start once an admin approves it",
+		})
+		return
 	}
 
-	vmodel := service.GetVModelClient()
-	result, err := vmodel.CreateSwapTask(c.Request.Context(), req.DetectID, faceSwaps, req.FaceEnhance)
-	if err != nil {
+	if err := service.SubmitSwap(c.Request.Context(), task); err != nil {
 		c.JSON(http.StatusInternalServerError, CreateFaceSwapResponse{
 			Code: 500,
 			Msg:  "Failed to create task: " + err.Error(),
 		})
 		return
 	}
+	if err := repository.SaveSwapTask(c.Request.Context(), task); err != nil {
+		log.Printf("[ERROR] Failed to save swap task %s: %v", task.TaskID, err)
+	}
 
 	c.JSON(http.StatusOK, CreateFaceSwapResponse{
 		Code: 0,
@@ -110,12 +315,44 @@ func createSwapWithVModel(c *gin.Context, req *CreateFaceSwapRequest) {
 			TaskID string `json:"task_id"`
 			Status string `json:"status"`
 		}{
-			TaskID: result.TaskID,
-			Status: result.Status,
+			TaskID: task.TaskID,
+			Status: task.Status,
 		},
 	})
 }
 
+// validateSwapFaces rejects detections the user doesn't own and unknown face IDs
+// before the paid swap is submitted
+func validateSwapFaces(c *gin.Context, req *CreateFaceSwapRequest) bool {
+	faceIDs := make([]int, len(req.FaceSwaps))
+	for i, swap := range req.FaceSwaps {
+		faceIDs[i] = swap.FaceID
+	}
+
+	err := service.ValidateSwapFaces(c.Request.Context(), middleware.GetUserID(c), req.DetectID, faceIDs)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, service.ErrDetectionNotFound):
+		c.JSON(http.StatusNotFound, CreateFaceSwapResponse{
+			Code: 404,
+			Msg:  "Detection not found or not completed: " + req.DetectID,
+		})
+	case errors.Is(err, service.ErrUnknownFace):
+		c.JSON(http.StatusBadRequest, CreateFaceSwapResponse{
+			Code: 400,
+			Msg:  "Invalid request: " + err.Error(),
+		})
+	default:
+		log.Printf("[ERROR] Failed to validate detection %s: %v", req.DetectID, err)
+		c.JSON(http.StatusInternalServerError, CreateFaceSwapResponse{
+			Code: 500,
+			Msg:  "Failed to validate detection",
+		})
+	}
+	return false
+}
+
 // GetFaceSwapTaskStatus returns the status of a face swap task
 func GetFaceSwapTaskStatus(c *gin.Context) {
 	taskID := c.Param("id")
@@ -155,6 +392,71 @@ func GetFaceSwapTaskStatus(c *gin.Context) {
 
 // getStatusFromVModel gets task status from VModel API
 func getStatusFromVModel(c *gin.Context, taskID string) {
+	task, err := repository.GetSwapTask(c.Request.Context(), taskID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load swap task %s: %v", taskID, err)
+		c.JSON(http.StatusInternalServerError, GetTaskStatusResponse{
+			Code: 500,
+			Msg:  "Failed to load task",
+		})
+		return
+	}
+	// Same rule as loadOwnedTask. Without a DB tasks aren't recorded, so there is no owner to check.
+	if repository.IsDBAvailable() && (task == nil || task.UserID != middleware.GetUserID(c)) {
+		c.JSON(http.StatusNotFound, GetTaskStatusResponse{
+			Code: 404,
+			Msg:  "Task not found",
+		})
+		return
+	}
+	if task != nil && !service.SwapSubmitted(task) {
+		// Awaiting approval, rejected, or failed before submission: nothing to ask VModel
+		c.JSON(http.StatusOK, GetTaskStatusResponse{
+			Code: 0,
+			Data: &struct {
+				TaskID         string `json:"task_id"`
+				Status         string `json:"status"`
+				ResultURL      string `json:"result_url,omitempty"`
+				Error          string `json:"error,omitempty"`
+				TransferStatus string `json:"transfer_status,omitempty"`
+				OriginalURL    string `json:"original_url,omitempty"`
+			}{
+				TaskID: task.TaskID,
+				Status: task.Status,
+				Error:  firstNonEmpty(task.ErrorMessage.String, task.ApprovalReason.String),
+			},
+		})
+		return
+	}
+	if task != nil {
+		// The ID used while awaiting approval keeps working after submission
+		taskID = task.TaskID
+	}
+	// Result already transferred: resolve a fresh URL from the stored key
+	if task != nil && task.ResultKey.Valid {
+		resultURL, err := service.GetStorageService().GetAccessURL(c.Request.Context(), task.ResultKey.String)
+		if err == nil {
+			c.JSON(http.StatusOK, GetTaskStatusResponse{
+				Code: 0,
+				Data: &struct {
+					TaskID         string `json:"task_id"`
+					Status         string `json:"status"`
+					ResultURL      string `json:"result_url,omitempty"`
+					Error          string `json:"error,omitempty"`
+					TransferStatus string `json:"transfer_status,omitempty"`
+					OriginalURL    string `json:"original_url,omitempty"`
+				}{
+					TaskID:         taskID,
+					Status:         "completed",
+					ResultURL:      resultURL,
+					TransferStatus: "completed",
+				},
+			})
+			return
+		}
+		log.Printf("[WARN] Failed to resolve result URL for task %s: %v", taskID, err)
+	}
+
 	vmodel := service.GetVModelClient()
 	result, err := vmodel.GetTaskStatus(c.Request.Context(), taskID)
 	if err != nil {
@@ -165,6 +467,10 @@ func getStatusFromVModel(c *gin.Context, taskID string) {
 		return
 	}
 
+	if task != nil && result.Status == "failed" && task.Status != "failed" {
+		repository.UpdateSwapTaskStatus(c.Request.Context(), taskID, "failed", nil, &result.Error)
+	}
+
 	// Handle video transfer logic
 	resultURL := result.ResultURL
 	transferStatus := ""
@@ -1,6 +1,10 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"mime/multipart"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -10,103 +14,184 @@ import (
 
 // UploadMediaFile handles video/image file upload
 func UploadMediaFile(c *gin.Context) {
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+	file, status, msg := receiveFile(c, service.MediaCategoryMedia)
+	if file == nil {
+		c.JSON(status, gin.H{"error": msg})
 		return
 	}
 
-	// Validate file type
-	contentType := file.Header.Get("Content-Type")
-	if !isValidMediaType(contentType) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Only images and videos are allowed"})
+	// Upload to storage and record in the media library
+	media, url, ok := storeUpload(c, service.MediaCategoryMedia, file)
+	if !ok {
 		return
 	}
 
-	// Generate storage key
-	storage := service.GetStorageService()
-	prefix := "videos"
-	if isImageType(contentType) {
-		prefix = "images"
+	c.JSON(http.StatusOK, mediaUploadResponse(c.Request.Context(), url, media))
+}
+
+// mediaUploadResponse builds the payload shared by UploadMediaFile and resumable uploads
+func mediaUploadResponse(ctx context.Context, url string, media *service.MediaInfo) gin.H {
+	resp := gin.H{
+		"media_id":     media.MediaID,
+		"url":          url,
+		"key":          media.Key,
+		"filename":     media.Filename,
+		"content_type": media.ContentType,
+		"size":         media.Size,
+		"hash":         media.Hash,
+		"user_id":      media.UserID,
+	}
+	if media.Width > 0 {
+		resp["width"] = media.Width
+		resp["height"] = media.Height
 	}
-	key := storage.GenerateKey(prefix, file.Filename)
+	if media.ThumbnailKey != "" {
+		resp["thumbnail_url"], _ = service.GetStorageService().GetAccessURL(ctx, media.ThumbnailKey)
+	}
+	if media.Codec != "" {
+		resp["duration"] = media.Duration
+		resp["frame_rate"] = media.FrameRate
+		resp["codec"] = media.Codec
+		resp["rotation"] = media.Rotation
+
+		// Let the client warn before the user pays for detection
+		err := service.CheckVideoLimits(&service.VideoMeta{
+			Duration: media.Duration,
+			Width:    media.Width,
+			Height:   media.Height,
+		})
+		if err != nil {
+			resp["limit_error"] = err.Error()
+		}
+	}
+	return resp
+}
 
-	// Upload to storage
-	url, err := storage.Upload(c.Request.Context(), key, file)
+// storeUpload stores the file, records it and resolves its access URL.
+// On failure it writes the error response and returns ok=false.
+func storeUpload(c *gin.Context, category string, file *multipart.FileHeader) (*service.MediaInfo, string, bool) {
+	media, err := service.StoreUpload(c.Request.Context(), middleware.GetUserID(c), category, file)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file: " + err.Error()})
-		return
+		status, msg := uploadError(err, category)
+		c.JSON(status, gin.H{"error": msg})
+		return nil, "", false
+	}
+
+	url, err := service.GetStorageService().GetAccessURL(c.Request.Context(), media.Key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve URL: " + err.Error()})
+		return nil, "", false
 	}
+	return media, url, true
+}
 
-	// Get user ID if authenticated
-	userID := middleware.GetUserID(c)
+// multipartOverhead allows for boundaries and part headers on top of the file itself
+const multipartOverhead = 1 << 20
 
-	c.JSON(http.StatusOK, gin.H{
-		"url":          url,
-		"key":          key,
-		"filename":     file.Filename,
-		"content_type": contentType,
-		"size":         file.Size,
-		"user_id":      userID,
-	})
+// receiveFile reads the "file" form field. The request body is capped at the
+// category's size limit before gin parses (and buffers) the multipart form.
+// On failure it returns a nil file with the HTTP status and message to send.
+func receiveFile(c *gin.Context, category string) (*multipart.FileHeader, int, string) {
+	limit := service.MaxUploadSize(category)
+	tooLarge := fileTooLargeMessage(limit)
+
+	if c.Request.ContentLength > limit+multipartOverhead {
+		return nil, http.StatusRequestEntityTooLarge, tooLarge
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+multipartOverhead)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, http.StatusRequestEntityTooLarge, tooLarge
+		}
+		return nil, http.StatusBadRequest, "No file uploaded"
+	}
+	if file.Size > limit {
+		return nil, http.StatusRequestEntityTooLarge, tooLarge
+	}
+	return file, 0, ""
+}
+
+func fileTooLargeMessage(limit int64) string {
+	if limit >= 1<<20 {
+		return fmt.Sprintf("File too large. Maximum size is %d MB", limit>>20)
+	}
+	return fmt.Sprintf("File too large. Maximum size is %d bytes", limit)
+}
+
+// uploadError maps service.StoreUpload errors to an HTTP status and message
+func uploadError(err error, category string) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge, fileTooLargeMessage(service.MaxUploadSize(category))
+	case errors.Is(err, service.ErrUnsupportedMediaType):
+		if category == service.MediaCategoryMedia {
+			return http.StatusUnsupportedMediaType, "Invalid file type. Only images and videos are allowed"
+		}
+		return http.StatusUnsupportedMediaType, "Invalid file type. Only JPEG, PNG, WebP and GIF images are allowed"
+	case errors.Is(err, service.ErrMediaTypeMismatch):
+		return http.StatusUnsupportedMediaType, "File content does not match its declared type"
+	case errors.Is(err, service.ErrStorageQuotaExceeded):
+		return http.StatusForbidden, "Upload rejected: " + err.Error()
+	}
+	return http.StatusInternalServerError, "Failed to upload file: " + err.Error()
 }
 
 // UploadFaceImage handles face image upload (for replacement)
 func UploadFaceImage(c *gin.Context) {
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+	// Only images are accepted for faces; the content is checked by StoreUpload
+	file, status, msg := receiveFile(c, service.MediaCategoryFace)
+	if file == nil {
+		c.JSON(status, gin.H{"error": msg})
 		return
 	}
 
-	// Validate file type - only images for faces
-	contentType := file.Header.Get("Content-Type")
-	if !isImageType(contentType) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Only images are allowed for face upload"})
+	media, url, ok := storeUpload(c, service.MediaCategoryFace, file)
+	if !ok {
 		return
 	}
 
-	// Generate storage key
-	storage := service.GetStorageService()
-	key := storage.GenerateKey("faces", file.Filename)
+	resp := gin.H{
+		"media_id":     media.MediaID,
+		"url":          url,
+		"key":          media.Key,
+		"filename":     media.Filename,
+		"content_type": media.ContentType,
+	}
 
-	// Upload to storage
-	url, err := storage.Upload(c.Request.Context(), key, file)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file: " + err.Error()})
-		return
+	// Clients send key/url back for swaps, so they point at the normalized variant
+	if media.NormalizedKey != "" {
+		if normalizedURL, err := service.GetStorageService().GetAccessURL(c.Request.Context(), media.NormalizedKey); err == nil {
+			resp["key"] = media.NormalizedKey
+			resp["url"] = normalizedURL
+			resp["content_type"] = "image/jpeg"
+			resp["original_key"] = media.Key
+			resp["original_url"] = url
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"url":          url,
-		"key":          key,
-		"filename":     file.Filename,
-		"content_type": contentType,
-	})
+	c.JSON(http.StatusOK, resp)
 }
 
 // UploadFrame handles video frame image upload (for face detection)
 func UploadFrame(c *gin.Context) {
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+	file, status, msg := receiveFile(c, service.MediaCategoryFrame)
+	if file == nil {
+		c.JSON(status, gin.H{"error": msg})
 		return
 	}
 
-	// Generate storage key
-	storage := service.GetStorageService()
-	key := storage.GenerateKey("frames", file.Filename)
-
-	// Upload to storage
-	url, err := storage.Upload(c.Request.Context(), key, file)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file: " + err.Error()})
+	media, url, ok := storeUpload(c, service.MediaCategoryFrame, file)
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"url": url,
-		"key": key,
+		"media_id": media.MediaID,
+		"url":      url,
+		"key":      media.Key,
 	})
 }
 
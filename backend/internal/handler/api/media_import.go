@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
+)
+
+type ImportMediaRequest struct {
+	URL      string `json:"url" binding:"required"`
+	Filename string `json:"filename" binding:"max=255"` // Defaults to the last URL path segment
+}
+
+// MediaImportResponse reports an import like a task: poll until completed or failed
+type MediaImportResponse struct {
+	TaskID   string             `json:"task_id"`
+	MediaID  string             `json:"media_id"`
+	Filename string             `json:"filename"`
+	Status   string             `json:"status"` // processing, completed, failed
+	Received int64              `json:"received"`
+	Total    int64              `json:"total,omitempty"`    // Bytes, when the server sent a length
+	Progress int                `json:"progress,omitempty"` // Percent, when the total is known
+	Error    string             `json:"error,omitempty"`
+	Media    *MediaFileResponse `json:"media,omitempty"` // Once completed
+}
+
+// ImportMedia starts downloading a remote file into the media library
+func ImportMedia(c *gin.Context) {
+	var req ImportMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	imp, err := service.StartMediaImport(c.Request.Context(), middleware.GetUserID(c), req.URL, req.Filename)
+	switch {
+	case errors.Is(err, service.ErrURLRejected):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrStorageQuotaExceeded):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Import rejected: " + err.Error()})
+		return
+	case errors.Is(err, service.ErrTooManyImports):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Import rejected: " + err.Error()})
+		return
+	case err != nil:
+		log.Printf("[ERROR] Failed to start media import: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start import"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, toMediaImportResponse(imp))
+}
+
+// GetMediaImportStatus returns the progress of an import, with the media once completed
+func GetMediaImportStatus(c *gin.Context) {
+	imp, err := service.GetMediaImport(c.Request.Context(), middleware.GetUserID(c), c.Param("id"))
+	if err != nil {
+		log.Printf("[ERROR] Failed to load media import: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load import"})
+		return
+	}
+	if imp == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+
+	resp := toMediaImportResponse(imp)
+	if imp.Status == "completed" {
+		if media, err := repository.GetMediaFile(c.Request.Context(), imp.MediaID); err == nil && media != nil {
+			m := toMediaFileResponse(c.Request.Context(), media)
+			resp.Media = &m
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func toMediaImportResponse(imp *service.MediaImport) MediaImportResponse {
+	resp := MediaImportResponse{
+		TaskID:   imp.MediaID,
+		MediaID:  imp.MediaID,
+		Filename: imp.Filename,
+		Status:   imp.Status,
+		Received: imp.Received,
+		Error:    imp.Error,
+	}
+	if imp.Total > 0 {
+		resp.Total = imp.Total
+		resp.Progress = int(imp.Received * 100 / imp.Total)
+	}
+	return resp
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
+)
+
+const maxMediaPageSize = 100
+
+type UpdateMediaRequest struct {
+	Filename *string  `json:"filename"`
+	Tags     []string `json:"tags"`
+}
+
+// MediaFileResponse is a media library entry with freshly resolved URLs
+type MediaFileResponse struct {
+	MediaID       string    `json:"media_id"`
+	Filename      string    `json:"filename"`
+	FileType      string    `json:"file_type"`
+	Category      string    `json:"category"`
+	ContentType   string    `json:"content_type,omitempty"`
+	Size          int64     `json:"size"`
+	Hash          string    `json:"hash,omitempty"`
+	Width         int32     `json:"width,omitempty"`
+	Height        int32     `json:"height,omitempty"`
+	Duration      float64   `json:"duration,omitempty"`
+	FrameRate     float64   `json:"frame_rate,omitempty"`
+	Codec         string    `json:"codec,omitempty"`
+	Rotation      int32     `json:"rotation,omitempty"`
+	Tags          []string  `json:"tags"`
+	Key           string    `json:"key,omitempty"`
+	URL           string    `json:"url,omitempty"`
+	ThumbnailURL  string    `json:"thumbnail_url,omitempty"`
+	NormalizedURL string    `json:"normalized_url,omitempty"`
+	Status        string    `json:"status"` // importing, ready, failed
+	ImportError   string    `json:"import_error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ListMedia returns the user's media library (paginated)
+// Query: page, page_size, type (video|image), category (media|face|frame), tag, from, to (YYYY-MM-DD or RFC3339)
+func ListMedia(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if pageSize < 1 || pageSize > maxMediaPageSize {
+		pageSize = 20
+	}
+
+	filter := repository.MediaFilter{
+		FileType: c.Query("type"),
+		Category: c.Query("category"),
+		Tag:      c.Query("tag"),
+		Limit:    pageSize,
+		Offset:   (page - 1) * pageSize,
+	}
+
+	var ok bool
+	if filter.From, ok = parseDateParam(c, "from", false); !ok {
+		return
+	}
+	if filter.To, ok = parseDateParam(c, "to", true); !ok {
+		return
+	}
+
+	files, total, err := repository.ListMediaFiles(c.Request.Context(), middleware.GetUserID(c), filter)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list media: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list media"})
+		return
+	}
+
+	items := make([]MediaFileResponse, len(files))
+	for i := range files {
+		items[i] = toMediaFileResponse(c.Request.Context(), &files[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":     items,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
+
+// GetMedia returns a single media library entry
+func GetMedia(c *gin.Context) {
+	media, ok := loadOwnedMedia(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toMediaFileResponse(c.Request.Context(), media))
+}
+
+// UpdateMedia renames a media file and/or replaces its tags
+func UpdateMedia(c *gin.Context) {
+	var req UpdateMediaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Filename != nil {
+		name := strings.TrimSpace(*req.Filename)
+		if name == "" || len(name) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Filename must be 1-255 characters"})
+			return
+		}
+		req.Filename = &name
+	}
+	if req.Tags != nil {
+		req.Tags = normalizeTags(req.Tags)
+	}
+
+	media, ok := loadOwnedMedia(c)
+	if !ok {
+		return
+	}
+
+	if err := repository.UpdateMediaFile(c.Request.Context(), media.MediaID, req.Filename, req.Tags); err != nil {
+		log.Printf("[ERROR] Failed to update media %s: %v", media.MediaID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update media"})
+		return
+	}
+
+	media, err := repository.GetMediaFile(c.Request.Context(), media.MediaID)
+	if err != nil || media == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load media"})
+		return
+	}
+	c.JSON(http.StatusOK, toMediaFileResponse(c.Request.Context(), media))
+}
+
+// DeleteMedia removes a media file from storage along with its dependent records
+func DeleteMedia(c *gin.Context) {
+	media, ok := loadOwnedMedia(c)
+	if !ok {
+		return
+	}
+
+	if err := service.DeleteMedia(c.Request.Context(), media.MediaID); err != nil {
+		log.Printf("[ERROR] Failed to delete media %s: %v", media.MediaID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete media"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Media deleted"})
+}
+
+// GetMediaThumbnail serves a JPEG preview of an image, rendered on first request
+// Query: w (width in pixels, snapped up to a supported size; defaults to the upload thumbnail)
+func GetMediaThumbnail(c *gin.Context) {
+	width, _ := strconv.Atoi(c.Query("w"))
+
+	media, ok := loadOwnedMedia(c)
+	if !ok {
+		return
+	}
+
+	obj, _, err := service.OpenThumbnail(c.Request.Context(), media, width)
+	if errors.Is(err, service.ErrNoThumbnail) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No thumbnail available for this media"})
+		return
+	}
+	if errors.Is(err, service.ErrImageTooLarge) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Image is too large to render a thumbnail"})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to render thumbnail for %s: %v", media.MediaID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render thumbnail"})
+		return
+	}
+	defer obj.Close()
+
+	// Thumbnails never change for a given media and width
+	c.Header("Cache-Control", "private, max-age=604800, immutable")
+	c.Header("ETag", fmt.Sprintf(`"%s-w%d"`, media.MediaID, service.SnapThumbnailWidth(width)))
+	c.Header("Content-Type", "image/jpeg")
+	http.ServeContent(c.Writer, c.Request, "", media.CreatedAt, obj)
+}
+
+// loadOwnedMedia fetches the media from the :id param, responding 404 unless the user owns it
+func loadOwnedMedia(c *gin.Context) (*repository.MediaFile, bool) {
+	media, err := repository.GetMediaFile(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		log.Printf("[ERROR] Failed to load media: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load media"})
+		return nil, false
+	}
+	if media == nil || media.UserID != middleware.GetUserID(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Media not found"})
+		return nil, false
+	}
+	return media, true
+}
+
+func toMediaFileResponse(ctx context.Context, f *repository.MediaFile) MediaFileResponse {
+	storage := service.GetStorageService()
+	resp := MediaFileResponse{
+		MediaID:     f.MediaID,
+		Filename:    f.Filename,
+		FileType:    f.FileType,
+		Category:    f.Category,
+		ContentType: f.ContentType.String,
+		Size:        f.FileSize,
+		Hash:        f.FileHash.String,
+		Width:       f.Width.Int32,
+		Height:      f.Height.Int32,
+		Duration:    f.Duration.Float64,
+		FrameRate:   f.FrameRate.Float64,
+		Codec:       f.VideoCodec.String,
+		Rotation:    f.Rotation.Int32,
+		Tags:        f.Tags,
+		Key:         f.StorageKey.String,
+		Status:      f.Status,
+		ImportError: f.ErrorMessage.String,
+		CreatedAt:   f.CreatedAt,
+		UpdatedAt:   f.UpdatedAt,
+	}
+	if resp.Tags == nil {
+		resp.Tags = []string{}
+	}
+
+	if f.StorageKey.Valid {
+		resp.URL, _ = storage.GetAccessURL(ctx, f.StorageKey.String)
+	} else {
+		resp.URL = f.StorageURL.String
+	}
+	if f.NormalizedKey.Valid {
+		resp.NormalizedURL, _ = storage.GetAccessURL(ctx, f.NormalizedKey.String)
+	}
+	if f.ThumbnailKey.Valid {
+		resp.ThumbnailURL, _ = storage.GetAccessURL(ctx, f.ThumbnailKey.String)
+	} else {
+		resp.ThumbnailURL = f.ThumbnailURL.String
+	}
+	return resp
+}
+
+// normalizeTags trims, lowercases and de-duplicates tags
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	result := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > 50 || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
+// parseDateParam parses a YYYY-MM-DD or RFC3339 query param. With endOfDay, a bare
+// date is moved to the next midnight so it can be used as an exclusive upper bound.
+// On failure it writes a 400 response and returns ok=false.
+func parseDateParam(c *gin.Context, name string, endOfDay bool) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " date, expected YYYY-MM-DD"})
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	"errors"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
+)
+
+const (
+	shareCookieName       = "share_auth"
+	shareAccessCookieName = "share_access"
+)
+
+type CreateShareLinkRequest struct {
+	ExpiresIn    int64  `json:"expires_in"`    // Seconds, defaults to SHARE_DEFAULT_EXPIRY
+	Password     string `json:"password"`      // Optional
+	MaxDownloads int    `json:"max_downloads"` // Views and downloads combined, 0 = unlimited
+}
+
+// ShareLinkResponse describes a share link; URL and token are only returned on creation
+type ShareLinkResponse struct {
+	ID             int64      `json:"id"`
+	TaskID         string     `json:"task_id"`
+	URL            string     `json:"url,omitempty"`
+	Token          string     `json:"token,omitempty"`
+	HasPassword    bool       `json:"has_password"`
+	MaxDownloads   int32      `json:"max_downloads,omitempty"`
+	ViewCount      int        `json:"view_count"`
+	DownloadCount  int        `json:"download_count"`
+	Active         bool       `json:"active"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// CreateShareLink creates a public link to a task's result
+func CreateShareLink(c *gin.Context) {
+	// All options are optional, so is the body
+	var req CreateShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	task, ok := loadOwnedTask(c)
+	if !ok {
+		return
+	}
+	if !task.ResultKey.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "Task has no stored result to share yet"})
+		return
+	}
+
+	link, token, err := service.CreateShareLink(c.Request.Context(), task, service.ShareOptions{
+		ExpiresIn:    time.Duration(req.ExpiresIn) * time.Second,
+		Password:     req.Password,
+		MaxDownloads: req.MaxDownloads,
+	})
+	if errors.Is(err, service.ErrInvalidShare) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to create share link for task %s: %v", task.TaskID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create share link"})
+		return
+	}
+
+	resp := toShareLinkResponse(link)
+	resp.Token = token
+	resp.URL = publicBaseURL(c) + "/s/" + token
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListShareLinks returns a task's share links with their counters
+func ListShareLinks(c *gin.Context) {
+	task, ok := loadOwnedTask(c)
+	if !ok {
+		return
+	}
+
+	links, err := repository.ListShareLinks(c.Request.Context(), task.TaskID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list share links for task %s: %v", task.TaskID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list share links"})
+		return
+	}
+
+	items := make([]ShareLinkResponse, 0, len(links))
+	for i := range links {
+		items = append(items, toShareLinkResponse(&links[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// RevokeShareLink disables a share link immediately
+func RevokeShareLink(c *gin.Context) {
+	shareID, err := strconv.ParseInt(c.Param("share_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid share link ID"})
+		return
+	}
+
+	task, ok := loadOwnedTask(c)
+	if !ok {
+		return
+	}
+
+	revoked, err := repository.RevokeShareLink(c.Request.Context(), task.TaskID, shareID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to revoke share link %d: %v", shareID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+		return
+	}
+	if !revoked {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share link not found or already revoked"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "revoked"})
+}
+
+// ServeShareLink streams a shared result without login (GET /s/:token).
+// ?download=1 serves it as an attachment. Password-protected links show a
+// password form until the visitor unlocks them.
+func ServeShareLink(c *gin.Context) {
+	token := c.Param("token")
+	link, task, ok := loadShareLink(c, token)
+	if !ok {
+		return
+	}
+
+	if link.PasswordHash.Valid {
+		cookie, _ := c.Cookie(shareCookieName)
+		if !service.CheckShareAuthCookie(token, link, cookie) {
+			renderSharePasswordForm(c, http.StatusUnauthorized, "")
+			return
+		}
+	}
+
+	download := c.Query("download") == "1"
+
+	// Players fetch videos in many range requests. The request that opens the
+	// link is counted and gets a short-lived cookie covering the ranges after it;
+	// without that cookie every request is counted, whatever its Range header.
+	access, _ := c.Cookie(shareAccessCookieName)
+	if !service.CheckShareAccessCookie(link, access) {
+		if c.Request.Method == http.MethodHead {
+			if !service.ShareLinkActive(link) {
+				c.String(http.StatusGone, "This link has expired or reached its download limit")
+				return
+			}
+		} else {
+			claimed, err := repository.ClaimShareAccess(c.Request.Context(), link.ID, download)
+			if err != nil {
+				log.Printf("[ERROR] Failed to count share link %d access: %v", link.ID, err)
+				c.String(http.StatusInternalServerError, "Failed to open shared file")
+				return
+			}
+			if !claimed {
+				c.String(http.StatusGone, "This link has expired or reached its download limit")
+				return
+			}
+			c.SetSameSite(http.SameSiteLaxMode)
+			c.SetCookie(shareAccessCookieName, service.ShareAccessCookie(link), int(service.ShareAccessTTL.Seconds()),
+				"/s/"+token, "", c.Request.TLS != nil, true)
+		}
+	}
+
+	serveStoredObject(c, task.ResultKey.String, service.ResultFilename(c.Request.Context(), task), service.ResultTime(task), download)
+}
+
+// UnlockShareLink checks the password of a protected link (POST /s/:token)
+func UnlockShareLink(c *gin.Context) {
+	token := c.Param("token")
+	link, _, ok := loadShareLink(c, token)
+	if !ok {
+		return
+	}
+
+	if !service.CheckSharePassword(link, c.PostForm("password")) {
+		log.Printf("[WARN] Wrong password for share link %d from %s", link.ID, c.ClientIP())
+		renderSharePasswordForm(c, http.StatusUnauthorized, "Incorrect password")
+		return
+	}
+
+	maxAge := int(time.Until(link.ExpiresAt).Seconds())
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(shareCookieName, service.ShareAuthCookie(token, link), maxAge, "/s/"+token, "", c.Request.TLS != nil, true)
+	c.Redirect(http.StatusSeeOther, c.Request.URL.RequestURI())
+}
+
+// loadShareLink resolves a share token to its link and task, responding with
+// a plain error page for unknown or unusable links
+func loadShareLink(c *gin.Context, token string) (*repository.ShareLink, *repository.SwapTask, bool) {
+	// The token is a credential: keep it out of referrers, caches and search engines
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Robots-Tag", "noindex")
+
+	link, err := service.LookupShareLink(c.Request.Context(), token)
+	switch {
+	case errors.Is(err, service.ErrShareNotFound):
+		c.String(http.StatusNotFound, "Link not found")
+		return nil, nil, false
+	case errors.Is(err, service.ErrShareUnavailable):
+		c.String(http.StatusGone, "This link has expired or reached its download limit")
+		return nil, nil, false
+	case err != nil:
+		log.Printf("[ERROR] Failed to load share link: %v", err)
+		c.String(http.StatusInternalServerError, "Failed to open shared file")
+		return nil, nil, false
+	}
+
+	task, err := repository.GetSwapTask(c.Request.Context(), link.TaskID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load task %s for share link %d: %v", link.TaskID, link.ID, err)
+		c.String(http.StatusInternalServerError, "Failed to open shared file")
+		return nil, nil, false
+	}
+	if task == nil || !task.ResultKey.Valid {
+		c.String(http.StatusNotFound, "Link not found")
+		return nil, nil, false
+	}
+	return link, task, true
+}
+
+var sharePasswordPage = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>PlayerPlus</title></head>
+<body style="font-family: sans-serif; max-width: 360px; margin: 80px auto;">
+<form method="post">
+<p>此链接需要密码 / This link is password protected</p>
+{{if .}}<p style="color: #c00;">{{.}}</p>{{end}}
+<input type="password" name="password" autofocus required style="width: 100%; padding: 8px;">
+<button type="submit" style="margin-top: 12px; padding: 8px 16px;">OK</button>
+</form>
+</body></html>`))
+
+func renderSharePasswordForm(c *gin.Context, status int, message string) {
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := sharePasswordPage.Execute(c.Writer, message); err != nil {
+		log.Printf("[ERROR] Failed to render share password page: %v", err)
+	}
+}
+
+// loadOwnedTask fetches the swap task from the :id param, responding 404 unless the user owns it
+func loadOwnedTask(c *gin.Context) (*repository.SwapTask, bool) {
+	task, err := repository.GetSwapTask(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		log.Printf("[ERROR] Failed to load task: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load task"})
+		return nil, false
+	}
+	if task == nil || task.UserID != middleware.GetUserID(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return nil, false
+	}
+	return task, true
+}
+
+// publicBaseURL returns the externally reachable base URL of the app
+func publicBaseURL(c *gin.Context) string {
+	if base := config.Get().PublicBaseURL; base != "" {
+		return base
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func toShareLinkResponse(s *repository.ShareLink) ShareLinkResponse {
+	resp := ShareLinkResponse{
+		ID:            s.ID,
+		TaskID:        s.TaskID,
+		HasPassword:   s.PasswordHash.Valid,
+		MaxDownloads:  s.MaxDownloads.Int32,
+		ViewCount:     s.ViewCount,
+		DownloadCount: s.DownloadCount,
+		Active:        service.ShareLinkActive(s),
+		ExpiresAt:     s.ExpiresAt,
+		CreatedAt:     s.CreatedAt,
+	}
+	if s.RevokedAt.Valid {
+		revokedAt := s.RevokedAt.Time
+		resp.RevokedAt = &revokedAt
+	}
+	if s.LastAccessedAt.Valid {
+		lastAccessedAt := s.LastAccessedAt.Time
+		resp.LastAccessedAt = &lastAccessedAt
+	}
+	return resp
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/service"
+)
+
+// Resumable upload endpoints implementing the tus 1.0 core protocol plus the
+// creation, termination and expiration extensions. See https://tus.io/protocols/resumable-upload
+
+const tusBasePath = "/api/v2/media/tus/"
+
+// TusOptions advertises server capabilities (no auth required)
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", service.TusVersion)
+	c.Header("Tus-Version", service.TusVersion)
+	c.Header("Tus-Extension", "creation,termination,expiration")
+	c.Header("Tus-Max-Size", strconv.FormatInt(config.Get().TusMaxSize, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreate starts a new resumable upload (POST)
+func TusCreate(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	size, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size <= 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Upload-Length header required"})
+		return
+	}
+	if size > config.Get().TusMaxSize {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Upload exceeds maximum size"})
+		return
+	}
+
+	metadata := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	filename := metadata["filename"]
+	if filename == "" {
+		filename = "upload"
+	}
+
+	// Declared type is checked here and against the file signature on the first PATCH
+	contentType := service.NormalizeContentType(metadata["filetype"])
+	if !isValidMediaType(contentType) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Only images and videos are allowed"})
+		return
+	}
+
+	storage := service.GetStorageService()
+	key := storage.GenerateKey(service.MediaKeyPrefix(service.MediaCategoryMedia, contentType), filename)
+
+	upload, err := service.GetTusService().Create(c.Request.Context(), middleware.GetUserID(c), key, filename, contentType, size)
+	if errors.Is(err, service.ErrStorageQuotaExceeded) {
+		_, msg := uploadError(err, service.MediaCategoryMedia)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to create resumable upload: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	c.Header("Location", tusBasePath+upload.ID)
+	c.Header("Upload-Offset", "0")
+	c.Header("Upload-Expires", upload.ExpiresAt().UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// TusHead returns the current offset of an upload (HEAD)
+func TusHead(c *gin.Context) {
+	upload, ok := loadTusUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	if !upload.Completed {
+		c.Header("Upload-Expires", upload.ExpiresAt().UTC().Format(http.TimeFormat))
+	}
+	c.Status(http.StatusOK)
+}
+
+// TusPatch appends a chunk to an upload (PATCH)
+func TusPatch(c *gin.Context) {
+	if c.GetHeader("Content-Type") != "application/offset+octet-stream" {
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset header required"})
+		return
+	}
+
+	current, ok := loadTusUpload(c)
+	if !ok {
+		return
+	}
+	if !current.Completed && c.Request.ContentLength > current.Size-current.Offset {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": service.ErrTusTooLarge.Error()})
+		return
+	}
+
+	upload, err := service.GetTusService().WriteChunk(c.Request.Context(), c.Param("id"), offset, c.Request.Body)
+	switch {
+	case errors.Is(err, service.ErrTusOffsetMismatch), errors.Is(err, service.ErrTusCompleted):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrTusTooLarge):
+		// Bodies without a Content-Length are only caught while streaming
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrUnsupportedMediaType), errors.Is(err, service.ErrMediaTypeMismatch):
+		_, msg := uploadError(err, service.MediaCategoryMedia)
+		c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{"error": msg})
+		return
+	case errors.Is(err, service.ErrStorageQuotaExceeded):
+		// The upload has been discarded
+		_, msg := uploadError(err, service.MediaCategoryMedia)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	case errors.Is(err, service.ErrTusInterrupted):
+		// Client disconnected mid-chunk; received bytes are kept for resume
+		log.Printf("[WARN] Resumable upload %s interrupted at offset %d: %v", upload.ID, upload.Offset, err)
+	case err != nil:
+		log.Printf("[ERROR] Resumable upload %s failed: %v", c.Param("id"), err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to write chunk"})
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if !upload.Completed {
+		c.Header("Upload-Expires", upload.ExpiresAt().UTC().Format(http.TimeFormat))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// TusDelete terminates an upload (DELETE)
+func TusDelete(c *gin.Context) {
+	if _, ok := loadTusUpload(c); !ok {
+		return
+	}
+
+	if err := service.GetTusService().Terminate(c.Request.Context(), c.Param("id")); err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to terminate upload"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// TusResult returns the same payload as UploadMediaFile once the upload is complete
+func TusResult(c *gin.Context) {
+	upload, ok := loadTusUpload(c)
+	if !ok {
+		return
+	}
+	if !upload.Completed {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Upload not completed",
+			"offset": upload.Offset,
+			"size":   upload.Size,
+		})
+		return
+	}
+
+	url, err := service.GetStorageService().GetAccessURL(c.Request.Context(), upload.Key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve URL: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, mediaUploadResponse(c.Request.Context(), url, &service.MediaInfo{
+		MediaID:     upload.MediaID,
+		UserID:      upload.UserID,
+		Key:         upload.Key,
+		Filename:    upload.Filename,
+		ContentType: upload.ContentType,
+		Size:        upload.Size,
+	}))
+}
+
+// loadTusUpload fetches the upload from the :id param and checks ownership
+func loadTusUpload(c *gin.Context) (*service.TusUpload, bool) {
+	if !checkTusVersion(c) {
+		return nil, false
+	}
+
+	upload, err := service.GetTusService().Get(c.Param("id"))
+	if errors.Is(err, service.ErrTusNotFound) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to load resumable upload: %v", err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load upload"})
+		return nil, false
+	}
+	if upload.UserID != middleware.GetUserID(c) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return nil, false
+	}
+	return upload, true
+}
+
+// checkTusVersion validates the Tus-Resumable header and echoes it on the response
+func checkTusVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", service.TusVersion)
+	if v := c.GetHeader("Tus-Resumable"); v != "" && v != service.TusVersion {
+		c.Header("Tus-Version", service.TusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// parseTusMetadata decodes "key base64value,key2 base64value2"
+func parseTusMetadata(header string) map[string]string {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		if len(parts) == 0 {
+			continue
+		}
+		value := ""
+		if len(parts) > 1 {
+			if decoded, err := base64.StdEncoding.DecodeString(parts[1]); err == nil {
+				value = string(decoded)
+			}
+		}
+		metadata[parts[0]] = value
+	}
+	return metadata
+}
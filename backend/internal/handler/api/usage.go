@@ -0,0 +1,139 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/repository"
+	"playplus_platform/internal/service"
+)
+
+const maxConsumersLimit = 100
+
+// PrefixUsage is the usage under one storage prefix
+type PrefixUsage struct {
+	Bytes   int64 `json:"bytes"`
+	Objects int64 `json:"objects"`
+}
+
+// UsageResponse is storage usage against its quota; quota and remaining are omitted when unlimited
+type UsageResponse struct {
+	Bytes     int64                  `json:"bytes"`
+	Objects   int64                  `json:"objects"`
+	Quota     *int64                 `json:"quota,omitempty"`
+	Remaining *int64                 `json:"remaining,omitempty"`
+	ByPrefix  map[string]PrefixUsage `json:"by_prefix"`
+}
+
+// StorageConsumerResponse is one entry of the admin top consumers list
+type StorageConsumerResponse struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	TeamID  *int64 `json:"team_id,omitempty"`
+	Quota   *int64 `json:"quota,omitempty"` // Override only; nil uses the configured default
+	Bytes   int64  `json:"bytes"`
+	Objects int64  `json:"objects"`
+}
+
+// CreditUsageResponse is this month's credit spend; quota and remaining are omitted when unlimited
+type CreditUsageResponse struct {
+	Used        float64   `json:"used"`
+	Quota       *float64  `json:"quota,omitempty"`
+	Remaining   *float64  `json:"remaining,omitempty"`
+	PeriodStart time.Time `json:"period_start"`
+}
+
+// GetMyUsage returns the current user's storage usage, and their team's, and
+// the credits they spent this month
+func GetMyUsage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	user, team, err := service.GetStorageUsage(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load storage usage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load storage usage"})
+		return
+	}
+	credits, err := service.GetCreditUsage(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load credit usage: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load credit usage"})
+		return
+	}
+
+	resp := gin.H{"user": toUsageResponse(user), "credits": toCreditUsageResponse(credits)}
+	if team != nil {
+		resp["team"] = gin.H{
+			"id":    team.ID,
+			"name":  team.Name,
+			"usage": toUsageResponse(&team.UsageSummary),
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListStorageConsumers returns the users or teams using the most storage
+// Query: group (user|team, default user), limit (default 20)
+func ListStorageConsumers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > maxConsumersLimit {
+		limit = 20
+	}
+	group := c.DefaultQuery("group", "user")
+	if group != "user" && group != "team" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group must be user or team"})
+		return
+	}
+
+	consumers, err := repository.ListTopStorageConsumers(c.Request.Context(), group == "team", limit)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list storage consumers: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list storage consumers"})
+		return
+	}
+
+	items := make([]StorageConsumerResponse, 0, len(consumers))
+	for _, sc := range consumers {
+		item := StorageConsumerResponse{ID: sc.ID, Name: sc.Name, Bytes: sc.Bytes, Objects: sc.Objects}
+		if sc.TeamID.Valid && group == "user" {
+			teamID := sc.TeamID.Int64
+			item.TeamID = &teamID
+		}
+		if sc.Quota.Valid {
+			quota := sc.Quota.Int64
+			item.Quota = &quota
+		}
+		items = append(items, item)
+	}
+	c.JSON(http.StatusOK, gin.H{"group": group, "items": items})
+}
+
+func toUsageResponse(u *service.UsageSummary) UsageResponse {
+	resp := UsageResponse{
+		Bytes:    u.Bytes,
+		Objects:  u.Objects,
+		ByPrefix: make(map[string]PrefixUsage, len(u.ByPrefix)),
+	}
+	for _, p := range u.ByPrefix {
+		resp.ByPrefix[p.Prefix] = PrefixUsage{Bytes: p.Bytes, Objects: p.Objects}
+	}
+	if u.Quota > 0 {
+		quota, remaining := u.Quota, u.Remaining()
+		resp.Quota = &quota
+		resp.Remaining = &remaining
+	}
+	return resp
+}
+
+func toCreditUsageResponse(u *service.CreditUsage) CreditUsageResponse {
+	resp := CreditUsageResponse{Used: u.Used, PeriodStart: u.PeriodStart}
+	if u.Quota > 0 {
+		quota, remaining := u.Quota, u.Remaining()
+		resp.Quota = &quota
+		resp.Remaining = &remaining
+	}
+	return resp
+}
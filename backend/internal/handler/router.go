@@ -2,15 +2,19 @@ package handler
 
 import (
 	"io/fs"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/config"
 	"playplus_platform/internal/handler/api"
 	"playplus_platform/internal/middleware"
+	"playplus_platform/internal/repository"
 )
 
 func SetupRouter() *gin.Engine {
 	r := gin.Default()
+	setupTrustedProxies(r)
 
 	// Serve local uploads in development
 	r.Static("/uploads", "./uploads")
@@ -22,17 +26,25 @@ func SetupRouter() *gin.Engine {
 			c.JSON(http.StatusOK, gin.H{"status": "ok"})
 		})
 
-		// Auth routes
+		// tus capability discovery must not require auth
+		apiGroup.OPTIONS("/v2/media/tus/*any", api.TusOptions)
+
+		// Auth routes, limited per client IP against brute force and mail flooding
+		cfg := config.Get()
+		sendLimit := middleware.RateLimit("send_code", cfg.AuthSendLimit, cfg.AuthRateWindow)
+		verifyLimit := middleware.RateLimit("verify", cfg.AuthVerifyLimit, cfg.AuthRateWindow)
 		auth := apiGroup.Group("/auth")
 		{
-			auth.POST("/login", api.Login)
-			auth.POST("/send-code", api.SendVerificationCode)
-			auth.POST("/verify", api.VerifyCode)
+			auth.POST("/login", verifyLimit, api.Login)
+			auth.POST("/send-code", sendLimit, api.SendVerificationCode)
+			auth.POST("/verify", verifyLimit, api.VerifyCode)
+			auth.POST("/password/forgot", sendLimit, api.ForgotPassword) // Mails a reset code
+			auth.POST("/password/reset", verifyLimit, api.ResetPassword) // {"email", "code", "password"}
 		}
 
 		// Legacy face swap routes (v1 - mock)
 		faceswap := apiGroup.Group("/faceswap")
-		faceswap.Use(middleware.AuthRequired())
+		faceswap.Use(middleware.AuthRequired(), middleware.ReadOnly(repository.RoleViewer))
 		{
 			faceswap.POST("/upload", api.UploadMedia)
 			faceswap.POST("/swap", api.SwapFace)
@@ -41,14 +53,35 @@ func SetupRouter() *gin.Engine {
 
 		// New API v2 routes
 		v2 := apiGroup.Group("/v2")
-		v2.Use(middleware.AuthRequired())
+		v2.Use(middleware.AuthRequired(), middleware.ReadOnly(repository.RoleViewer)) // Viewers can only read
 		{
+			// Current user
+			v2.GET("/me/usage", api.GetMyUsage) // Storage usage and quota
+
 			// Media upload
 			media := v2.Group("/media")
 			{
 				media.POST("/upload", api.UploadMediaFile)        // Upload video/image
 				media.POST("/upload/face", api.UploadFaceImage)   // Upload face image
 				media.POST("/upload/frame", api.UploadFrame)      // Upload video frame
+
+				// Resumable uploads (tus 1.0)
+				media.POST("/tus/", api.TusCreate)
+				media.HEAD("/tus/:id", api.TusHead)
+				media.PATCH("/tus/:id", api.TusPatch)
+				media.DELETE("/tus/:id", api.TusDelete)
+				media.GET("/tus/:id", api.TusResult) // Upload result once complete
+
+				// Background import from a remote URL
+				media.POST("/import", api.ImportMedia)
+				media.GET("/import/:id", api.GetMediaImportStatus)
+
+				// Media library
+				media.GET("", api.ListMedia)
+				media.GET("/:id", api.GetMedia)
+				media.PATCH("/:id", api.UpdateMedia)
+				media.DELETE("/:id", api.DeleteMedia)
+				media.GET("/:id/thumb", api.GetMediaThumbnail)
 			}
 
 			// Face detection
@@ -64,16 +97,85 @@ func SetupRouter() *gin.Engine {
 			{
 				swap.POST("/create", api.CreateFaceSwapTask)      // Create face swap task
 				swap.GET("/task/:id", api.GetFaceSwapTaskStatus)  // Get task status
+				swap.POST("/estimate", api.EstimateFaceSwap)      // Credits, processing time and quota check
+
+				swap.GET("/task/:id/download", api.DownloadTaskResult) // Stream the result (Range supported)
+				swap.POST("/export", api.ExportTaskResults)            // Zip of several results with a manifest
+
+				// Public share links to the result
+				swap.POST("/task/:id/share", api.CreateShareLink)
+				swap.GET("/task/:id/share", api.ListShareLinks)
+				swap.DELETE("/task/:id/share/:share_id", api.RevokeShareLink)
+			}
+
+			// Admin
+			admin := v2.Group("/admin")
+			admin.Use(middleware.RequireRole(repository.RoleAdmin))
+			{
+				admin.GET("/storage/gc", api.GetStorageGCReport)          // Recent GC runs and reclaimed space
+				admin.POST("/storage/gc", api.RunStorageGC)               // Start a GC run
+				admin.POST("/storage/gc/restore", api.RestoreQuarantined) // Undo a quarantine
+				admin.GET("/storage/usage", api.ListStorageConsumers)     // Top storage consumers
+				admin.GET("/reports/usage", api.GetUsageReport)           // Tasks, credits and storage by user/team/tool (format=csv)
+
+				admin.GET("/teams", api.ListTeams)
+				admin.POST("/teams", api.CreateTeam)
+				admin.PATCH("/teams/:id", api.UpdateTeam)
+				admin.PATCH("/users/:id/storage", api.UpdateUserStorage) // Team and storage quota
+
+				admin.GET("/users", api.ListUsers)               // ?role=admin|member|viewer
+				admin.POST("/users", api.CreateUser)             // {"email", "role", "password"}
+				admin.PUT("/users/:id/role", api.UpdateUserRole) // {"role": "viewer"}
+
+				admin.GET("/audit/auth", api.ListAuthEvents) // Code lockouts and throttled IPs, ?event=&days=
+
+				// Sign-ups outside ALLOWED_EMAIL_DOMAINS
+				admin.GET("/invitations", api.ListInvitations)
+				admin.POST("/invitations", api.CreateInvitation)       // {"email", "role", "expires_in"}
+				admin.DELETE("/invitations/:id", api.RevokeInvitation) // Revoke a pending invitation
+
+				// Swap tasks over the credit limits
+				admin.GET("/approvals", api.ListPendingApprovals)
+				admin.POST("/approvals/:id/approve", api.ApproveSwapTask) // Submits the task to VModel
+				admin.POST("/approvals/:id/reject", api.RejectSwapTask)   // {"reason": "..."}
+
+				// Tasks of all users, with their provider calls
+				admin.GET("/tasks", api.ListAdminTasks)                      // ?type=&user_id=&status=&provider=&q=&from=&to=
+				admin.GET("/tasks/:id", api.GetAdminTask)                    // Details, provider calls and timings
+				admin.POST("/tasks/:id/refresh", api.RefreshAdminTask)       // Re-poll VModel now
+				admin.POST("/tasks/:id/retransfer", api.RetransferAdminTask) // Copy the result to storage again
+				admin.POST("/tasks/:id/fail", api.FailAdminTask)             // {"reason": "..."}
 			}
 		}
 	}
 
+	// Public share links (no login). Password attempts are limited per IP and
+	// per link, so guesses spread over many addresses are stopped as well.
+	cfg := config.Get()
+	unlockPerIP := middleware.RateLimit("share_unlock", cfg.ShareUnlockLimit, cfg.ShareUnlockWindow)
+	unlockPerLink := middleware.RateLimitBy("share_unlock_link", cfg.ShareUnlockLimit, cfg.ShareUnlockWindow,
+		func(c *gin.Context) string { return c.Param("token") })
+	r.GET("/s/:token", api.ServeShareLink)
+	r.POST("/s/:token", unlockPerIP, unlockPerLink, api.UnlockShareLink)
+
 	// Serve frontend static files
 	setupStaticFiles(r)
 
 	return r
 }
 
+// setupTrustedProxies decides where ClientIP comes from. By default gin trusts
+// X-Forwarded-For from anyone, which would let clients pick their own IP and
+// escape the per-IP rate limits.
+func setupTrustedProxies(r *gin.Engine) {
+	cfg := config.Get()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("[ERROR] Invalid TRUSTED_PROXIES %v, trusting no proxies: %v", cfg.TrustedProxies, err)
+		r.SetTrustedProxies(nil)
+	}
+	r.TrustedPlatform = cfg.TrustedPlatform
+}
+
 func setupStaticFiles(r *gin.Engine) {
 	// In production, serve embedded frontend files
 	// In development, this won't be used (frontend runs on vite dev server)
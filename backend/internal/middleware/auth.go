@@ -43,11 +43,13 @@ func AuthRequired() gin.HandlerFunc {
 				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 				return
 			}
-			// Set user ID in context
+			// Set user ID and role in context
 			c.Set("user_id", session.UserID)
+			c.Set("role", session.Role)
 		} else {
-			// Development mode - use mock user ID
+			// Development mode - use mock user ID, trusted like an admin
 			c.Set("user_id", int64(1))
+			c.Set("role", repository.RoleAdmin)
 		}
 
 		c.Set("token", token)
@@ -55,6 +57,40 @@ func AuthRequired() gin.HandlerFunc {
 	}
 }
 
+// RequireRole restricts a route group to users with one of the roles.
+// Must run after AuthRequired.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := GetRole(c)
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+	}
+}
+
+// ReadOnly limits users with one of the roles to GET and HEAD requests.
+// Must run after AuthRequired.
+func ReadOnly(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		role := GetRole(c)
+		for _, r := range roles {
+			if r == role {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Read-only access"})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
 // GetUserID extracts user ID from context
 func GetUserID(c *gin.Context) int64 {
 	if userID, exists := c.Get("user_id"); exists {
@@ -64,3 +100,8 @@ func GetUserID(c *gin.Context) int64 {
 	}
 	return 0
 }
+
+// GetRole extracts the user's role from context
+func GetRole(c *gin.Context) string {
+	return c.GetString("role")
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/repository"
+)
+
+// newRoleRouter mirrors the API's route groups, with the role taken from a
+// test header in place of AuthRequired
+func newRoleRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	v2 := r.Group("/v2")
+	v2.Use(func(c *gin.Context) {
+		c.Set("user_id", int64(1))
+		c.Set("role", c.GetHeader("X-Test-Role"))
+	}, ReadOnly(repository.RoleViewer))
+	v2.GET("/media", ok)
+	v2.HEAD("/media", ok)
+	v2.POST("/media", ok)
+
+	admin := v2.Group("/admin")
+	admin.Use(RequireRole(repository.RoleAdmin))
+	admin.GET("/users", ok)
+	admin.POST("/users", ok)
+	return r
+}
+
+func TestRoleAccess(t *testing.T) {
+	r := newRoleRouter()
+	tests := []struct {
+		role   string
+		method string
+		path   string
+		want   int
+	}{
+		{repository.RoleAdmin, http.MethodGet, "/v2/media", http.StatusOK},
+		{repository.RoleAdmin, http.MethodPost, "/v2/media", http.StatusOK},
+		{repository.RoleAdmin, http.MethodGet, "/v2/admin/users", http.StatusOK},
+		{repository.RoleAdmin, http.MethodPost, "/v2/admin/users", http.StatusOK},
+
+		{repository.RoleMember, http.MethodGet, "/v2/media", http.StatusOK},
+		{repository.RoleMember, http.MethodPost, "/v2/media", http.StatusOK},
+		{repository.RoleMember, http.MethodGet, "/v2/admin/users", http.StatusForbidden},
+		{repository.RoleMember, http.MethodPost, "/v2/admin/users", http.StatusForbidden},
+
+		{repository.RoleViewer, http.MethodGet, "/v2/media", http.StatusOK},
+		{repository.RoleViewer, http.MethodHead, "/v2/media", http.StatusOK},
+		{repository.RoleViewer, http.MethodPost, "/v2/media", http.StatusForbidden},
+		{repository.RoleViewer, http.MethodGet, "/v2/admin/users", http.StatusForbidden},
+		{repository.RoleViewer, http.MethodPost, "/v2/admin/users", http.StatusForbidden},
+
+		{"", http.MethodGet, "/v2/admin/users", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("X-Test-Role", tt.role)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s %s as %q: status %d, want %d", tt.method, tt.path, tt.role, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"playplus_platform/internal/repository"
+)
+
+// ipWindow counts one key's requests in the current window
+type ipWindow struct {
+	start     time.Time
+	count     int
+	throttled bool // Already audited in this window
+}
+
+// ipLimiter is a fixed-window request counter per key, usually the client IP
+type ipLimiter struct {
+	name   string
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	windows   map[string]*ipWindow
+	lastSweep time.Time
+}
+
+// allow counts a request for key. It returns how long until the key can retry
+// when over the limit, and whether this is the first rejection in the window.
+func (l *ipLimiter) allow(key string, now time.Time) (retryAfter time.Duration, first bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop finished windows once per window so the map doesn't grow unbounded
+	if now.Sub(l.lastSweep) > l.window {
+		for key, w := range l.windows {
+			if now.Sub(w.start) >= l.window {
+				delete(l.windows, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w := l.windows[key]
+	if w == nil || now.Sub(w.start) >= l.window {
+		w = &ipWindow{start: now}
+		l.windows[key] = w
+	}
+	w.count++
+	if w.count <= l.limit {
+		return 0, false
+	}
+	first = !w.throttled
+	w.throttled = true
+	return w.start.Add(l.window).Sub(now), first
+}
+
+// RateLimit allows each client IP limit requests per window. Routes given the
+// same handler share the count. Throttled requests get 429 with Retry-After,
+// and the first one per window is audited. A limit of 0 disables it.
+func RateLimit(name string, limit int, window time.Duration) gin.HandlerFunc {
+	return RateLimitBy(name, limit, window, func(c *gin.Context) string { return c.ClientIP() })
+}
+
+// RateLimitBy is RateLimit counting requests per key instead of per client IP,
+// e.g. per resource to stop guesses spread over many addresses. The key is
+// kept in memory only; logs and audit events name the client IP.
+func RateLimitBy(name string, limit int, window time.Duration, key func(c *gin.Context) string) gin.HandlerFunc {
+	if limit <= 0 || window <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	l := &ipLimiter{name: name, limit: limit, window: window, windows: make(map[string]*ipWindow)}
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		retryAfter, first := l.allow(key(c), time.Now())
+		if retryAfter <= 0 {
+			c.Next()
+			return
+		}
+
+		if first {
+			log.Printf("[WARN] Throttled %s requests from %s: over %d per %v", name, ip, limit, window)
+			e := &repository.AuthEvent{
+				Event:  repository.AuthEventIPThrottled,
+				IP:     sql.NullString{String: ip, Valid: true},
+				Detail: sql.NullString{String: fmt.Sprintf("%s: over %d requests per %v on %s", name, limit, window, c.FullPath()), Valid: true},
+			}
+			if err := repository.SaveAuthEvent(context.Background(), e); err != nil {
+				log.Printf("[ERROR] Failed to record auth event %s: %v", e.Event, err)
+			}
+		}
+
+		seconds := int(retryAfter.Seconds()) + 1
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please try again later", "retry_after": seconds})
+	}
+}
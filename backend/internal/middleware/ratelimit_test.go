@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newLimitedRouter(t *testing.T, trustedProxies []string, limit int) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		t.Fatalf("SetTrustedProxies() error: %v", err)
+	}
+	r.POST("/login", RateLimit("test", limit, time.Hour), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func postFrom(r *gin.Engine, remoteAddr, forwardedFor string) int {
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.Header.Set("X-Real-IP", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	r := newLimitedRouter(t, nil, 2)
+	spoofed := []string{"", "1.1.1.1", "2.2.2.2", "3.3.3.3"}
+	for i, xff := range spoofed {
+		want := http.StatusOK
+		if i >= 2 {
+			want = http.StatusTooManyRequests
+		}
+		if got := postFrom(r, "203.0.113.7:40000", xff); got != want {
+			t.Errorf("request %d with X-Forwarded-For %q: status %d, want %d", i+1, xff, got, want)
+		}
+	}
+}
+
+func TestRateLimitTrustedProxy(t *testing.T) {
+	r := newLimitedRouter(t, []string{"10.0.0.0/8"}, 1)
+
+	// Clients behind the proxy are counted separately
+	if got := postFrom(r, "10.0.0.2:40000", "198.51.100.1"); got != http.StatusOK {
+		t.Errorf("first client: status %d, want %d", got, http.StatusOK)
+	}
+	if got := postFrom(r, "10.0.0.2:40000", "198.51.100.2"); got != http.StatusOK {
+		t.Errorf("second client: status %d, want %d", got, http.StatusOK)
+	}
+	if got := postFrom(r, "10.0.0.2:40000", "198.51.100.1"); got != http.StatusTooManyRequests {
+		t.Errorf("first client again: status %d, want %d", got, http.StatusTooManyRequests)
+	}
+
+	// Headers from outside the trusted range are still ignored
+	if got := postFrom(r, "203.0.113.7:40000", "198.51.100.3"); got != http.StatusOK {
+		t.Errorf("untrusted peer: status %d, want %d", got, http.StatusOK)
+	}
+	if got := postFrom(r, "203.0.113.7:40000", "198.51.100.4"); got != http.StatusTooManyRequests {
+		t.Errorf("untrusted peer with a new header: status %d, want %d", got, http.StatusTooManyRequests)
+	}
+}
+
+func TestRateLimitByKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/s/:token", RateLimitBy("test_link", 2, time.Hour, func(c *gin.Context) string { return c.Param("token") }),
+		func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	post := func(path, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Guesses from different addresses count against the same link
+	for i, addr := range []string{"203.0.113.1:1", "203.0.113.2:1", "203.0.113.3:1"} {
+		want := http.StatusOK
+		if i >= 2 {
+			want = http.StatusTooManyRequests
+		}
+		if got := post("/s/abc", addr); got != want {
+			t.Errorf("request %d from %s: status %d, want %d", i+1, addr, got, want)
+		}
+	}
+	if got := post("/s/other", "203.0.113.3:1"); got != http.StatusOK {
+		t.Errorf("another link: status %d, want %d", got, http.StatusOK)
+	}
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// adminTasksSQL lists detect, swap and transfer tasks of all users in one
+// shape. A swap task shows up a second time as a transfer once its result
+// transfer has started.
+const adminTasksSQL = `
+	SELECT 'detect' AS type, d.task_id, d.user_id, 'vmodel' AS provider, d.status,
+	       d.error_message AS error, d.created_at, d.updated_at, d.completed_at AS finished_at
+	FROM face_detections d
+	UNION ALL
+	SELECT 'swap', s.task_id, s.user_id, s.model, s.status,
+	       s.error_message, s.created_at, s.updated_at, s.completed_at
+	FROM swap_tasks s
+	UNION ALL
+	SELECT 'transfer', s.task_id, s.user_id, s.model, s.transfer_status,
+	       s.transfer_error, s.transfer_started_at, s.updated_at, s.transfer_finished_at
+	FROM swap_tasks s WHERE s.transfer_status IS NOT NULL`
+
+// AdminTaskFilter narrows the admin task list; zero values don't filter
+type AdminTaskFilter struct {
+	Type     string // detect, swap or transfer
+	UserID   int64
+	Status   string
+	Provider string
+	Error    string // Substring of the error message, case-insensitive
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+}
+
+// AdminTask is one row of the admin task list
+type AdminTask struct {
+	Type       string
+	TaskID     string
+	UserID     int64
+	UserEmail  sql.NullString
+	Provider   string
+	Status     sql.NullString
+	Error      sql.NullString
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt sql.NullTime
+}
+
+// ListAdminTasks returns tasks of all users, newest first, and the total count
+func ListAdminTasks(ctx context.Context, f AdminTaskFilter) ([]AdminTask, int64, error) {
+	if !IsDBAvailable() {
+		return nil, 0, nil
+	}
+
+	var where []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if f.Type != "" {
+		add("t.type = $%d", f.Type)
+	}
+	if f.UserID > 0 {
+		add("t.user_id = $%d", f.UserID)
+	}
+	if f.Status != "" {
+		add("t.status = $%d", f.Status)
+	}
+	if f.Provider != "" {
+		add("t.provider = $%d", f.Provider)
+	}
+	if f.Error != "" {
+		add("t.error ILIKE '%%' || $%d || '%%'", escapeLike(f.Error))
+	}
+	if !f.From.IsZero() {
+		add("t.created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("t.created_at < $%d", f.To)
+	}
+	whereSQL := ""
+	if len(where) > 0 {
+		whereSQL = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int64
+	if err := db.QueryRowContext(ctx, `
+		SELECT count(*) FROM (`+adminTasksSQL+`) t `+whereSQL, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, f.PageSize, (f.Page-1)*f.PageSize)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT t.type, t.task_id, t.user_id, u.email, t.provider, t.status, t.error,
+		       t.created_at, t.updated_at, t.finished_at
+		FROM (`+adminTasksSQL+`) t
+		LEFT JOIN users u ON u.id = t.user_id
+		%s
+		ORDER BY t.created_at DESC
+		LIMIT $%d OFFSET $%d
+	`, whereSQL, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var tasks []AdminTask
+	for rows.Next() {
+		var t AdminTask
+		if err := rows.Scan(&t.Type, &t.TaskID, &t.UserID, &t.UserEmail, &t.Provider, &t.Status, &t.Error,
+			&t.CreatedAt, &t.UpdatedAt, &t.FinishedAt); err != nil {
+			return nil, 0, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, total, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in a user-supplied substring
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+)
+
+// ListPendingApprovals returns tasks awaiting approval, oldest first
+func ListPendingApprovals(ctx context.Context, limit int) ([]SwapTask, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+	return querySwapTasks(ctx, `
+		SELECT `+swapTaskColumns+`
+		FROM swap_tasks
+		WHERE status = 'pending_approval'
+		ORDER BY created_at
+		LIMIT $1
+	`, limit)
+}
+
+// ReviewSwapTask moves a pending task to status (approved or rejected) and
+// records the review. Returns false if the task was no longer pending, so two
+// reviewers can't both act on it.
+func ReviewSwapTask(ctx context.Context, taskID, status string, reviewerID int64, reason string) (bool, error) {
+	if !IsDBAvailable() {
+		return false, nil
+	}
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE swap_tasks
+		SET status = $2, approval_reason = NULLIF($4, ''), reviewed_by = $3, reviewed_at = NOW(),
+		    completed_at = CASE WHEN $2 = 'rejected' THEN NOW() ELSE completed_at END
+		WHERE task_id = $1 AND status = 'pending_approval'
+	`, taskID, status, reviewerID, reason)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetSwapTaskSubmitted switches an approved task to the provider's task ID
+func SetSwapTaskSubmitted(ctx context.Context, approvalID, taskID, status string, credits float64) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE swap_tasks SET task_id = $2, status = $3, credits_used = $4
+		WHERE task_id = $1
+	`, approvalID, taskID, status, credits)
+	return err
+}
+
+// SumBatchCredits totals the credits of a user's batch, counting pending tasks
+// at their estimate and leaving out rejected and failed ones
+func SumBatchCredits(ctx context.Context, userID int64, batchID string) (float64, error) {
+	if !IsDBAvailable() {
+		return 0, nil
+	}
+
+	var total float64
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(credits_used), 0) FROM swap_tasks
+		WHERE user_id = $1 AND batch_id = $2 AND status NOT IN ('rejected', 'failed')
+	`, userID, batchID).Scan(&total)
+	return total, err
+}
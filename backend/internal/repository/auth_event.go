@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Auth audit events
+const (
+	AuthEventCodeLocked  = "code_locked"  // A verification code hit the attempt limit
+	AuthEventIPThrottled = "ip_throttled" // An IP hit the send or verify limit
+)
+
+// AuthEvent is an audit entry for sign-in abuse
+type AuthEvent struct {
+	ID        int64
+	Event     string
+	Email     sql.NullString
+	IP        sql.NullString
+	Detail    sql.NullString
+	CreatedAt time.Time
+}
+
+// SaveAuthEvent records an audit entry
+func SaveAuthEvent(ctx context.Context, e *AuthEvent) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	return db.QueryRowContext(ctx, `
+		INSERT INTO auth_events (event, email, ip, detail)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, e.Event, e.Email, e.IP, e.Detail).Scan(&e.ID, &e.CreatedAt)
+}
+
+// ListAuthEvents returns audit entries since a time, newest first, optionally
+// of one event type
+func ListAuthEvents(ctx context.Context, event string, since time.Time, limit int) ([]AuthEvent, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, event, email, ip, detail, created_at
+		FROM auth_events
+		WHERE ($1 = '' OR event = $1) AND created_at >= $2
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, event, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []AuthEvent
+	for rows.Next() {
+		var e AuthEvent
+		if err := rows.Scan(&e.ID, &e.Event, &e.Email, &e.IP, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
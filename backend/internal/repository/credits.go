@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// CreditEntry is one charge in the credit ledger
+type CreditEntry struct {
+	ID        int64
+	UserID    int64
+	TaskID    sql.NullString
+	Kind      string
+	Credits   float64
+	Estimated bool // Recorded from our estimate because the provider reported no cost
+	CreatedAt time.Time
+}
+
+// AddCreditEntry records credits spent by a user
+func AddCreditEntry(ctx context.Context, e *CreditEntry) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	return db.QueryRowContext(ctx, `
+		INSERT INTO credit_ledger (user_id, task_id, kind, credits, estimated)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, e.UserID, e.TaskID, e.Kind, e.Credits, e.Estimated).Scan(&e.ID, &e.CreatedAt)
+}
+
+// SumUserCredits totals the credits a user has spent since the given time
+func SumUserCredits(ctx context.Context, userID int64, since time.Time) (float64, error) {
+	if !IsDBAvailable() {
+		return 0, nil
+	}
+
+	var total float64
+	err := db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(credits), 0) FROM credit_ledger
+		WHERE user_id = $1 AND created_at >= $2
+	`, userID, since).Scan(&total)
+	return total, err
+}
+
+// GetCreditQuota returns a user's monthly credit quota override, if set
+func GetCreditQuota(ctx context.Context, userID int64) (sql.NullFloat64, error) {
+	var quota sql.NullFloat64
+	if !IsDBAvailable() {
+		return quota, nil
+	}
+
+	err := db.QueryRowContext(ctx, `SELECT credit_quota FROM users WHERE id = $1`, userID).Scan(&quota)
+	if err == sql.ErrNoRows {
+		return quota, nil
+	}
+	return quota, err
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// DetectedFace is one face found by a detection
+type DetectedFace struct {
+	FaceID    int    `json:"face_id"`
+	Thumbnail string `json:"thumbnail,omitempty"`
+}
+
+type FaceDetection struct {
+	ID           int64
+	TaskID       string
+	DetectID     sql.NullString
+	UserID       int64
+	MediaID      sql.NullString
+	MediaKey     sql.NullString
+	Status       string
+	Faces        []DetectedFace
+	ErrorMessage sql.NullString
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	CompletedAt  sql.NullTime
+}
+
+const faceDetectionColumns = `id, task_id, detect_id, user_id, media_id, media_key, status, faces,
+		       error_message, created_at, updated_at, completed_at`
+
+func scanFaceDetection(row rowScanner) (*FaceDetection, error) {
+	var d FaceDetection
+	var faces []byte
+	err := row.Scan(&d.ID, &d.TaskID, &d.DetectID, &d.UserID, &d.MediaID, &d.MediaKey, &d.Status, &faces,
+		&d.ErrorMessage, &d.CreatedAt, &d.UpdatedAt, &d.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(faces) > 0 {
+		if err := json.Unmarshal(faces, &d.Faces); err != nil {
+			return nil, err
+		}
+	}
+	return &d, nil
+}
+
+// CreateFaceDetection records a detection task submitted to the provider
+func CreateFaceDetection(ctx context.Context, d *FaceDetection) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	return db.QueryRowContext(ctx, `
+		INSERT INTO face_detections (task_id, user_id, media_id, media_key, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, d.TaskID, d.UserID, d.MediaID, d.MediaKey, d.Status).Scan(&d.ID, &d.CreatedAt, &d.UpdatedAt)
+}
+
+// GetFaceDetection retrieves a detection by its provider task ID
+func GetFaceDetection(ctx context.Context, taskID string) (*FaceDetection, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	d, err := scanFaceDetection(db.QueryRowContext(ctx, `
+		SELECT `+faceDetectionColumns+` FROM face_detections WHERE task_id = $1
+	`, taskID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// GetFaceDetectionByDetectID retrieves a completed detection by the detect_id used for swapping
+func GetFaceDetectionByDetectID(ctx context.Context, detectID string) (*FaceDetection, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	d, err := scanFaceDetection(db.QueryRowContext(ctx, `
+		SELECT `+faceDetectionColumns+` FROM face_detections
+		WHERE detect_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`, detectID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// UpdateFaceDetection stores the latest status of a detection, and its result once finished
+func UpdateFaceDetection(ctx context.Context, d *FaceDetection) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	var faces []byte
+	if d.Faces != nil {
+		var err error
+		if faces, err = json.Marshal(d.Faces); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE face_detections
+		SET status = $2, detect_id = $3, faces = $4, error_message = $5, completed_at = $6, updated_at = NOW()
+		WHERE task_id = $1
+	`, d.TaskID, d.Status, d.DetectID, faces, d.ErrorMessage, d.CompletedAt)
+
+	return err
+}
@@ -8,114 +8,142 @@ import (
 	"github.com/lib/pq"
 )
 
-type MediaFile struct {
-	ID           int64
-	UserID       int64
-	MediaID      string
-	Filename     string
-	FileType     string
-	FileSize     int64
-	StorageURL   sql.NullString
-	ThumbnailURL sql.NullString
-	CreatedAt    time.Time
+type SwapTask struct {
+	ID             int64
+	UserID         int64
+	TaskID         string
+	MediaID        string
+	FaceIDs        []string
+	Model          string
+	Status         string
+	ResultURL      sql.NullString // Deprecated: use ResultKey
+	ErrorMessage   sql.NullString
+	DetectID       sql.NullString
+	BatchID        sql.NullString
+	TargetVideoKey sql.NullString
+	SourceFaceKeys []string
+	ResultKey      sql.NullString
+	ResultSize     sql.NullInt64
+	StorageBackend sql.NullString
+	CreditsUsed    float64 // Estimate until submitted, then what the provider charged
+	FaceEnhance    bool
+	ApprovalID     sql.NullString // Set when the task needed approval; the ID it had until submitted
+	ApprovalReason sql.NullString
+	ReviewedBy     sql.NullInt64
+	ReviewedAt     sql.NullTime
+	TransferStatus sql.NullString // pending, completed, failed
+	TransferError  sql.NullString
+	TransferStart  sql.NullTime
+	TransferEnd    sql.NullTime
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	CompletedAt    sql.NullTime
 }
 
-type SwapTask struct {
-	ID           int64
-	UserID       int64
-	TaskID       string
-	MediaID      string
-	FaceIDs      []string
-	Model        string
-	Status       string
-	ResultURL    sql.NullString
-	ErrorMessage sql.NullString
-	CreditsUsed  float64
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	CompletedAt  sql.NullTime
+// CreateSwapTask creates a new swap task
+func CreateSwapTask(ctx context.Context, userID int64, taskID, mediaID string, faceIDs []string, model string) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO swap_tasks (user_id, task_id, media_id, face_ids, model, status)
+		VALUES ($1, $2, $3, $4, $5, 'pending')
+	`, userID, taskID, mediaID, pq.Array(faceIDs), model)
+
+	return err
 }
 
-// SaveMediaFile saves a media file record
-func SaveMediaFile(ctx context.Context, userID int64, mediaID, filename, fileType string, fileSize int64) error {
+// SaveSwapTask records a face swap task submitted to the provider
+func SaveSwapTask(ctx context.Context, t *SwapTask) error {
 	if !IsDBAvailable() {
 		return nil
 	}
 
 	_, err := db.ExecContext(ctx, `
-		INSERT INTO media_files (user_id, media_id, filename, file_type, file_size)
-		VALUES ($1, $2, $3, $4, $5)
-	`, userID, mediaID, filename, fileType, fileSize)
+		INSERT INTO swap_tasks (user_id, task_id, media_id, face_ids, model, status,
+		                        detect_id, target_video_key, source_face_keys, storage_backend, batch_id, credits_used,
+		                        face_enhance, approval_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+	`, t.UserID, t.TaskID, t.MediaID, pq.Array(t.FaceIDs), t.Model, t.Status,
+		t.DetectID, t.TargetVideoKey, pq.Array(t.SourceFaceKeys), t.StorageBackend, t.BatchID, t.CreditsUsed,
+		t.FaceEnhance, t.ApprovalID)
 
 	return err
 }
 
-// GetMediaFilesByUser retrieves media files for a user
-func GetMediaFilesByUser(ctx context.Context, userID int64, limit int) ([]MediaFile, error) {
+const swapTaskColumns = `id, user_id, task_id, media_id, face_ids, model, status,
+		       result_url, error_message, credits_used, created_at, updated_at, completed_at,
+		       detect_id, target_video_key, source_face_keys, result_key, result_size, storage_backend, batch_id,
+		       face_enhance, approval_id, approval_reason, reviewed_by, reviewed_at,
+		       transfer_status, transfer_error, transfer_started_at, transfer_finished_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSwapTask(row rowScanner) (*SwapTask, error) {
+	var t SwapTask
+	err := row.Scan(
+		&t.ID, &t.UserID, &t.TaskID, &t.MediaID, pq.Array(&t.FaceIDs), &t.Model, &t.Status,
+		&t.ResultURL, &t.ErrorMessage, &t.CreditsUsed, &t.CreatedAt, &t.UpdatedAt, &t.CompletedAt,
+		&t.DetectID, &t.TargetVideoKey, pq.Array(&t.SourceFaceKeys), &t.ResultKey, &t.ResultSize, &t.StorageBackend, &t.BatchID,
+		&t.FaceEnhance, &t.ApprovalID, &t.ApprovalReason, &t.ReviewedBy, &t.ReviewedAt,
+		&t.TransferStatus, &t.TransferError, &t.TransferStart, &t.TransferEnd,
+	)
+	return &t, err
+}
+
+// GetSwapTask retrieves a swap task by task ID, or by the ID it had while awaiting approval
+func GetSwapTask(ctx context.Context, taskID string) (*SwapTask, error) {
 	if !IsDBAvailable() {
 		return nil, nil
 	}
 
-	rows, err := db.QueryContext(ctx, `
-		SELECT id, user_id, media_id, filename, file_type, file_size, storage_url, thumbnail_url, created_at
-		FROM media_files
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2
-	`, userID, limit)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	t, err := scanSwapTask(db.QueryRowContext(ctx, `
+		SELECT `+swapTaskColumns+`
+		FROM swap_tasks
+		WHERE task_id = $1 OR approval_id = $1
+		LIMIT 1
+	`, taskID))
 
-	var files []MediaFile
-	for rows.Next() {
-		var f MediaFile
-		err := rows.Scan(&f.ID, &f.UserID, &f.MediaID, &f.Filename, &f.FileType, &f.FileSize, &f.StorageURL, &f.ThumbnailURL, &f.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-		files = append(files, f)
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-
-	return files, rows.Err()
+	return t, err
 }
 
-// CreateSwapTask creates a new swap task
-func CreateSwapTask(ctx context.Context, userID int64, taskID, mediaID string, faceIDs []string, model string) error {
+// SetSwapTaskResultKey records where the transferred result is stored and completes the task
+func SetSwapTaskResultKey(ctx context.Context, taskID, resultKey, backend string, size int64) error {
 	if !IsDBAvailable() {
 		return nil
 	}
 
 	_, err := db.ExecContext(ctx, `
-		INSERT INTO swap_tasks (user_id, task_id, media_id, face_ids, model, status)
-		VALUES ($1, $2, $3, $4, $5, 'pending')
-	`, userID, taskID, mediaID, pq.Array(faceIDs), model)
+		UPDATE swap_tasks
+		SET result_key = $2, storage_backend = $3, result_size = $4, status = 'completed', completed_at = COALESCE(completed_at, NOW()),
+		    transfer_status = 'completed', transfer_error = NULL, transfer_finished_at = NOW()
+		WHERE task_id = $1
+	`, taskID, resultKey, backend, size)
 
 	return err
 }
 
-// GetSwapTask retrieves a swap task by task ID
-func GetSwapTask(ctx context.Context, taskID string) (*SwapTask, error) {
+// SetSwapTaskTransfer records that a result transfer started (pending) or failed
+func SetSwapTaskTransfer(ctx context.Context, taskID, status, errorMsg string) error {
 	if !IsDBAvailable() {
-		return nil, nil
+		return nil
 	}
 
-	var t SwapTask
-	err := db.QueryRowContext(ctx, `
-		SELECT id, user_id, task_id, media_id, face_ids, model, status,
-		       result_url, error_message, credits_used, created_at, updated_at, completed_at
-		FROM swap_tasks
+	_, err := db.ExecContext(ctx, `
+		UPDATE swap_tasks
+		SET transfer_status = $2, transfer_error = NULLIF($3, ''),
+		    transfer_started_at = CASE WHEN $2 = 'pending' THEN NOW() ELSE transfer_started_at END,
+		    transfer_finished_at = CASE WHEN $2 = 'pending' THEN NULL ELSE NOW() END
 		WHERE task_id = $1
-	`, taskID).Scan(
-		&t.ID, &t.UserID, &t.TaskID, &t.MediaID, pq.Array(&t.FaceIDs), &t.Model, &t.Status,
-		&t.ResultURL, &t.ErrorMessage, &t.CreditsUsed, &t.CreatedAt, &t.UpdatedAt, &t.CompletedAt,
-	)
+	`, taskID, status, errorMsg)
 
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
-	return &t, err
+	return err
 }
 
 // UpdateSwapTaskStatus updates task status
@@ -155,14 +183,45 @@ func GetSwapTasksByUser(ctx context.Context, userID int64, limit int) ([]SwapTas
 		return nil, nil
 	}
 
-	rows, err := db.QueryContext(ctx, `
-		SELECT id, user_id, task_id, media_id, face_ids, model, status,
-		       result_url, error_message, credits_used, created_at, updated_at, completed_at
+	return querySwapTasks(ctx, `
+		SELECT `+swapTaskColumns+`
 		FROM swap_tasks
 		WHERE user_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2
 	`, userID, limit)
+}
+
+// GetSwapTasksByIDs retrieves a user's tasks by task ID, in creation order.
+// IDs that don't exist or belong to someone else are left out.
+func GetSwapTasksByIDs(ctx context.Context, userID int64, taskIDs []string) ([]SwapTask, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+	return querySwapTasks(ctx, `
+		SELECT `+swapTaskColumns+`
+		FROM swap_tasks
+		WHERE user_id = $1 AND task_id = ANY($2)
+		ORDER BY created_at
+	`, userID, pq.Array(taskIDs))
+}
+
+// GetSwapTasksByBatch retrieves a user's tasks in a batch, in creation order
+func GetSwapTasksByBatch(ctx context.Context, userID int64, batchID string, limit int) ([]SwapTask, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+	return querySwapTasks(ctx, `
+		SELECT `+swapTaskColumns+`
+		FROM swap_tasks
+		WHERE user_id = $1 AND batch_id = $2
+		ORDER BY created_at
+		LIMIT $3
+	`, userID, batchID, limit)
+}
+
+func querySwapTasks(ctx context.Context, query string, args ...interface{}) ([]SwapTask, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -170,16 +229,11 @@ func GetSwapTasksByUser(ctx context.Context, userID int64, limit int) ([]SwapTas
 
 	var tasks []SwapTask
 	for rows.Next() {
-		var t SwapTask
-		err := rows.Scan(
-			&t.ID, &t.UserID, &t.TaskID, &t.MediaID, pq.Array(&t.FaceIDs), &t.Model, &t.Status,
-			&t.ResultURL, &t.ErrorMessage, &t.CreditsUsed, &t.CreatedAt, &t.UpdatedAt, &t.CompletedAt,
-		)
+		t, err := scanSwapTask(rows)
 		if err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, t)
+		tasks = append(tasks, *t)
 	}
-
 	return tasks, rows.Err()
 }
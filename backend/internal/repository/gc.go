@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// GCPrefixStats counts objects under one storage prefix during a GC run
+type GCPrefixStats struct {
+	ScannedObjects  int64 `json:"scanned_objects"`
+	ScannedBytes    int64 `json:"scanned_bytes"`
+	OrphanedObjects int64 `json:"orphaned_objects"`
+	OrphanedBytes   int64 `json:"orphaned_bytes"`
+}
+
+// GCRun is the persisted report of one garbage collection run
+type GCRun struct {
+	ID                 int64
+	Mode               string
+	DryRun             bool
+	ScannedObjects     int64
+	ScannedBytes       int64
+	OrphanedObjects    int64
+	OrphanedBytes      int64
+	QuarantinedObjects int64
+	QuarantinedBytes   int64
+	DeletedObjects     int64
+	ReclaimedBytes     int64
+	ExpiredMedia       int
+	Failures           int
+	Prefixes           map[string]*GCPrefixStats
+	ErrorMessage       sql.NullString
+	StartedAt          time.Time
+	FinishedAt         sql.NullTime
+}
+
+// StorageReferences counts the DB references to every storage key: media
+// library objects and their variants, face detection inputs, and swap task
+// inputs and results.
+// Keys are also extracted from legacy URL columns of rows that predate keys.
+// Media IDs are returned separately since thumbnail variants are keyed by them.
+func StorageReferences(ctx context.Context) (map[string]int, map[string]bool, error) {
+	if !IsDBAvailable() {
+		return nil, nil, fmt.Errorf("database not configured")
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT key, count(*) FROM (
+			SELECT COALESCE(storage_key, substring(storage_url FROM '`+storageKeyPattern+`')) AS key FROM media_files
+			UNION ALL SELECT COALESCE(thumbnail_key, substring(thumbnail_url FROM '`+storageKeyPattern+`')) FROM media_files
+			UNION ALL SELECT normalized_key FROM media_files
+			UNION ALL SELECT media_key FROM face_detections
+			UNION ALL SELECT target_video_key FROM swap_tasks
+			UNION ALL SELECT unnest(source_face_keys) FROM swap_tasks
+			UNION ALL SELECT COALESCE(result_key, substring(result_url FROM '`+storageKeyPattern+`')) FROM swap_tasks
+		) refs
+		WHERE key IS NOT NULL AND key <> ''
+		GROUP BY key
+	`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]int)
+	for rows.Next() {
+		var key string
+		var n int
+		if err := rows.Scan(&key, &n); err != nil {
+			return nil, nil, err
+		}
+		keys[key] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	idRows, err := db.QueryContext(ctx, `SELECT media_id FROM media_files`)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer idRows.Close()
+
+	mediaIDs := make(map[string]bool)
+	for idRows.Next() {
+		var id string
+		if err := idRows.Scan(&id); err != nil {
+			return nil, nil, err
+		}
+		mediaIDs[id] = true
+	}
+	return keys, mediaIDs, idRows.Err()
+}
+
+// ListExpiredMedia returns library entries of a category created before the
+// cutoff that nobody kept: untagged, never used as a swap target or face, and
+// not under a detection still running. Finished detections are removed along
+// with the media by DeleteMediaFile.
+func ListExpiredMedia(ctx context.Context, category string, before time.Time) ([]MediaFile, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+mediaFileColumns+`
+		FROM media_files m
+		WHERE category = $1 AND created_at < $2
+		  AND COALESCE(cardinality(tags), 0) = 0
+		  AND NOT EXISTS (
+			SELECT 1 FROM swap_tasks t
+			WHERE t.media_id = m.media_id
+			   OR t.target_video_key IN (m.storage_key, m.normalized_key)
+			   OR m.storage_key = ANY(t.source_face_keys)
+			   OR m.normalized_key = ANY(t.source_face_keys)
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM face_detections d
+			WHERE (d.media_id = m.media_id OR d.media_key = m.storage_key)
+			  AND d.status NOT IN ('completed', 'failed')
+		  )
+		ORDER BY created_at
+	`, category, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []MediaFile
+	for rows.Next() {
+		f, err := scanMediaFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *f)
+	}
+	return files, rows.Err()
+}
+
+// SaveGCRun records a finished GC run
+func SaveGCRun(ctx context.Context, r *GCRun) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	prefixes, err := json.Marshal(r.Prefixes)
+	if err != nil {
+		return err
+	}
+
+	return db.QueryRowContext(ctx, `
+		INSERT INTO gc_runs (mode, dry_run, scanned_objects, scanned_bytes, orphaned_objects, orphaned_bytes,
+		                     quarantined_objects, quarantined_bytes, deleted_objects, reclaimed_bytes,
+		                     expired_media, failures, prefixes, error_message, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id
+	`, r.Mode, r.DryRun, r.ScannedObjects, r.ScannedBytes, r.OrphanedObjects, r.OrphanedBytes,
+		r.QuarantinedObjects, r.QuarantinedBytes, r.DeletedObjects, r.ReclaimedBytes,
+		r.ExpiredMedia, r.Failures, prefixes, r.ErrorMessage, r.StartedAt, r.FinishedAt).Scan(&r.ID)
+}
+
+// ListGCRuns returns the most recent GC runs, newest first
+func ListGCRuns(ctx context.Context, limit int) ([]GCRun, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, mode, dry_run, scanned_objects, scanned_bytes, orphaned_objects, orphaned_bytes,
+		       quarantined_objects, quarantined_bytes, deleted_objects, reclaimed_bytes,
+		       expired_media, failures, prefixes, error_message, started_at, finished_at
+		FROM gc_runs
+		ORDER BY started_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []GCRun
+	for rows.Next() {
+		var r GCRun
+		var prefixes []byte
+		err := rows.Scan(&r.ID, &r.Mode, &r.DryRun, &r.ScannedObjects, &r.ScannedBytes, &r.OrphanedObjects, &r.OrphanedBytes,
+			&r.QuarantinedObjects, &r.QuarantinedBytes, &r.DeletedObjects, &r.ReclaimedBytes,
+			&r.ExpiredMedia, &r.Failures, &prefixes, &r.ErrorMessage, &r.StartedAt, &r.FinishedAt)
+		if err != nil {
+			return nil, err
+		}
+		if len(prefixes) > 0 {
+			if err := json.Unmarshal(prefixes, &r.Prefixes); err != nil {
+				return nil, err
+			}
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// GCTotals sums what finished, non-dry GC runs have reclaimed
+type GCTotals struct {
+	Runs               int64
+	DeletedObjects     int64
+	ReclaimedBytes     int64
+	QuarantinedObjects int64
+	QuarantinedBytes   int64
+}
+
+// SumGCRuns totals the effect of all recorded GC runs since a point in time
+func SumGCRuns(ctx context.Context, since time.Time) (*GCTotals, error) {
+	if !IsDBAvailable() {
+		return &GCTotals{}, nil
+	}
+
+	var t GCTotals
+	err := db.QueryRowContext(ctx, `
+		SELECT count(*), COALESCE(sum(deleted_objects), 0), COALESCE(sum(reclaimed_bytes), 0),
+		       COALESCE(sum(quarantined_objects), 0), COALESCE(sum(quarantined_bytes), 0)
+		FROM gc_runs
+		WHERE NOT dry_run AND started_at >= $1
+	`, since).Scan(&t.Runs, &t.DeletedObjects, &t.ReclaimedBytes, &t.QuarantinedObjects, &t.QuarantinedBytes)
+	return &t, err
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Invitation lets an email outside the allowed domains sign up with a role
+type Invitation struct {
+	ID             int64
+	Email          string
+	Role           string
+	InvitedBy      sql.NullInt64
+	ExpiresAt      time.Time
+	AcceptedAt     sql.NullTime
+	AcceptedUserID sql.NullInt64
+	RevokedAt      sql.NullTime
+	CreatedAt      time.Time
+}
+
+// Status is pending, accepted, revoked or expired
+func (i *Invitation) Status() string {
+	switch {
+	case i.AcceptedAt.Valid:
+		return "accepted"
+	case i.RevokedAt.Valid:
+		return "revoked"
+	case time.Now().After(i.ExpiresAt):
+		return "expired"
+	default:
+		return "pending"
+	}
+}
+
+const invitationColumns = `id, email, role, invited_by, expires_at, accepted_at, accepted_user_id, revoked_at, created_at`
+
+func scanInvitation(row rowScanner) (*Invitation, error) {
+	var i Invitation
+	err := row.Scan(&i.ID, &i.Email, &i.Role, &i.InvitedBy, &i.ExpiresAt, &i.AcceptedAt, &i.AcceptedUserID,
+		&i.RevokedAt, &i.CreatedAt)
+	return &i, err
+}
+
+// CreateInvitation saves an invitation, revoking any open one for the same email
+func CreateInvitation(ctx context.Context, inv *Invitation) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE invitations SET revoked_at = NOW()
+		WHERE lower(email) = lower($1) AND accepted_at IS NULL AND revoked_at IS NULL
+	`, inv.Email); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO invitations (email, role, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`, inv.Email, inv.Role, inv.InvitedBy, inv.ExpiresAt).Scan(&inv.ID, &inv.CreatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPendingInvitation returns the unexpired open invitation for an email, if any
+func GetPendingInvitation(ctx context.Context, email string) (*Invitation, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	inv, err := scanInvitation(db.QueryRowContext(ctx, `
+		SELECT `+invitationColumns+`
+		FROM invitations
+		WHERE lower(email) = lower($1) AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+	`, email))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return inv, err
+}
+
+// ListInvitations returns invitations, newest first. status narrows them to
+// pending, accepted, revoked or expired.
+func ListInvitations(ctx context.Context, status string, limit int) ([]Invitation, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+invitationColumns+`
+		FROM invitations
+		WHERE CASE $1
+			WHEN 'accepted' THEN accepted_at IS NOT NULL
+			WHEN 'revoked' THEN accepted_at IS NULL AND revoked_at IS NOT NULL
+			WHEN 'expired' THEN accepted_at IS NULL AND revoked_at IS NULL AND expires_at <= NOW()
+			WHEN 'pending' THEN accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()
+			ELSE TRUE
+		END
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invitations []Invitation
+	for rows.Next() {
+		inv, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, *inv)
+	}
+	return invitations, rows.Err()
+}
+
+// RevokeInvitation withdraws an open invitation. Returns false if there is no
+// such invitation or it was already accepted or revoked.
+func RevokeInvitation(ctx context.Context, id int64) (bool, error) {
+	if !IsDBAvailable() {
+		return false, nil
+	}
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE invitations SET revoked_at = NOW()
+		WHERE id = $1 AND accepted_at IS NULL AND revoked_at IS NULL
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// AcceptInvitation marks an invitation used by the user who signed up with it
+func AcceptInvitation(ctx context.Context, id, userID int64) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE invitations SET accepted_at = NOW(), accepted_user_id = $2
+		WHERE id = $1 AND accepted_at IS NULL
+	`, id, userID)
+	return err
+}
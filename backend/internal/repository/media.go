@@ -0,0 +1,370 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type MediaFile struct {
+	ID             int64
+	UserID         int64
+	MediaID        string
+	Filename       string
+	FileType       string // video, image
+	Category       string // media, face, frame
+	ContentType    sql.NullString
+	FileSize       int64
+	FileHash       sql.NullString
+	Width          sql.NullInt32
+	Height         sql.NullInt32
+	Duration       sql.NullFloat64
+	FrameRate      sql.NullFloat64
+	VideoCodec     sql.NullString
+	Rotation       sql.NullInt32
+	Tags           []string
+	StorageURL     sql.NullString // Deprecated: use StorageKey
+	ThumbnailURL   sql.NullString // Deprecated: use ThumbnailKey
+	StorageKey     sql.NullString
+	ThumbnailKey   sql.NullString
+	NormalizedKey  sql.NullString // Processed face image used for swaps
+	NormalizedSize sql.NullInt64
+	ThumbnailSize  sql.NullInt64
+	StorageBackend sql.NullString
+	Status         string         // importing, ready, failed
+	SourceURL      sql.NullString // Imported from, without query
+	ErrorMessage   sql.NullString // Why an import failed
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// MediaFilter narrows ListMediaFiles results
+type MediaFilter struct {
+	FileType string // video, image
+	Category string // media, face, frame
+	Tag      string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
+
+const mediaFileColumns = `id, user_id, media_id, filename, file_type, COALESCE(category, 'media'), content_type,
+		       COALESCE(file_size, 0), file_hash, width, height, duration, frame_rate, video_codec, rotation, tags, storage_url, thumbnail_url,
+		       storage_key, thumbnail_key, normalized_key, normalized_size, thumbnail_size, storage_backend, created_at, COALESCE(updated_at, created_at),
+		       COALESCE(status, 'ready'), source_url, error_message`
+
+func scanMediaFile(row rowScanner) (*MediaFile, error) {
+	var f MediaFile
+	err := row.Scan(
+		&f.ID, &f.UserID, &f.MediaID, &f.Filename, &f.FileType, &f.Category, &f.ContentType,
+		&f.FileSize, &f.FileHash, &f.Width, &f.Height, &f.Duration, &f.FrameRate, &f.VideoCodec, &f.Rotation, pq.Array(&f.Tags), &f.StorageURL, &f.ThumbnailURL,
+		&f.StorageKey, &f.ThumbnailKey, &f.NormalizedKey, &f.NormalizedSize, &f.ThumbnailSize, &f.StorageBackend, &f.CreatedAt, &f.UpdatedAt,
+		&f.Status, &f.SourceURL, &f.ErrorMessage,
+	)
+	return &f, err
+}
+
+// SaveMediaFile saves a media file record
+func SaveMediaFile(ctx context.Context, f *MediaFile) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	if f.Tags == nil {
+		f.Tags = []string{}
+	}
+	if f.Status == "" {
+		f.Status = "ready"
+	}
+
+	return db.QueryRowContext(ctx, `
+		INSERT INTO media_files (user_id, media_id, filename, file_type, category, content_type, file_size,
+		                         file_hash, width, height, duration, frame_rate, video_codec, rotation,
+		                         tags, storage_key, normalized_key, thumbnail_key, normalized_size, thumbnail_size, storage_backend,
+		                         status, source_url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
+		RETURNING id, created_at, updated_at
+	`, f.UserID, f.MediaID, f.Filename, f.FileType, f.Category, f.ContentType, f.FileSize,
+		f.FileHash, f.Width, f.Height, f.Duration, f.FrameRate, f.VideoCodec, f.Rotation,
+		pq.Array(f.Tags), f.StorageKey, f.NormalizedKey, f.ThumbnailKey, f.NormalizedSize, f.ThumbnailSize, f.StorageBackend,
+		f.Status, f.SourceURL,
+	).Scan(&f.ID, &f.CreatedAt, &f.UpdatedAt)
+}
+
+// GetMediaFile retrieves a media file by media ID
+func GetMediaFile(ctx context.Context, mediaID string) (*MediaFile, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	f, err := scanMediaFile(db.QueryRowContext(ctx, `
+		SELECT `+mediaFileColumns+`
+		FROM media_files
+		WHERE media_id = $1
+	`, mediaID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return f, err
+}
+
+// GetMediaFileByKey retrieves a media file by its storage key or normalized variant key
+func GetMediaFileByKey(ctx context.Context, key string) (*MediaFile, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	f, err := scanMediaFile(db.QueryRowContext(ctx, `
+		SELECT `+mediaFileColumns+`
+		FROM media_files
+		WHERE storage_key = $1 OR normalized_key = $1
+		ORDER BY id DESC
+		LIMIT 1
+	`, key))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return f, err
+}
+
+// GetMediaFilesByUser retrieves media files for a user
+func GetMediaFilesByUser(ctx context.Context, userID int64, limit int) ([]MediaFile, error) {
+	files, _, err := ListMediaFiles(ctx, userID, MediaFilter{Limit: limit})
+	return files, err
+}
+
+// ListMediaFiles returns a page of a user's media files and the total match count
+func ListMediaFiles(ctx context.Context, userID int64, filter MediaFilter) ([]MediaFile, int, error) {
+	if !IsDBAvailable() {
+		return nil, 0, nil
+	}
+
+	where := []string{"user_id = $1"}
+	args := []interface{}{userID}
+	addArg := func(cond string, value interface{}) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if filter.FileType != "" {
+		addArg("file_type = $%d", filter.FileType)
+	}
+	if filter.Category != "" {
+		addArg("COALESCE(category, 'media') = $%d", filter.Category)
+	}
+	if filter.Tag != "" {
+		addArg("$%d = ANY(tags)", filter.Tag)
+	}
+	if !filter.From.IsZero() {
+		addArg("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addArg("created_at < $%d", filter.To)
+	}
+	whereSQL := strings.Join(where, " AND ")
+
+	var total int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM media_files WHERE `+whereSQL, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	args = append(args, limit, filter.Offset)
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT `+mediaFileColumns+`
+		FROM media_files
+		WHERE %s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, whereSQL, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var files []MediaFile
+	for rows.Next() {
+		f, err := scanMediaFile(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		files = append(files, *f)
+	}
+
+	return files, total, rows.Err()
+}
+
+// UpdateMediaFile renames a media file and/or replaces its tags (nil leaves a field unchanged)
+func UpdateMediaFile(ctx context.Context, mediaID string, filename *string, tags []string) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE media_files
+		SET filename = COALESCE($2, filename),
+		    tags = CASE WHEN $3::TEXT[] IS NULL THEN tags ELSE $3::TEXT[] END
+		WHERE media_id = $1
+	`, mediaID, filename, pq.Array(tags))
+
+	return err
+}
+
+// SetMediaThumbnailKey records the default thumbnail of a media file
+func SetMediaThumbnailKey(ctx context.Context, mediaID, key string, size int64) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE media_files SET thumbnail_key = $2, thumbnail_size = $3 WHERE media_id = $1
+	`, mediaID, key, size)
+
+	return err
+}
+
+// CompleteMediaImport fills in an imported media record once its object is
+// stored. Returns false when the record is gone or no longer importing.
+func CompleteMediaImport(ctx context.Context, f *MediaFile) (bool, error) {
+	if !IsDBAvailable() {
+		return true, nil
+	}
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE media_files
+		SET status = 'ready', error_message = NULL, file_type = $2, content_type = $3, file_size = $4,
+		    file_hash = $5, width = $6, height = $7, duration = $8, frame_rate = $9, video_codec = $10, rotation = $11,
+		    storage_key = $12, thumbnail_key = $13, thumbnail_size = $14, storage_backend = $15
+		WHERE media_id = $1 AND status = 'importing'
+	`, f.MediaID, f.FileType, f.ContentType, f.FileSize,
+		f.FileHash, f.Width, f.Height, f.Duration, f.FrameRate, f.VideoCodec, f.Rotation,
+		f.StorageKey, f.ThumbnailKey, f.ThumbnailSize, f.StorageBackend)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// FailMediaImport marks an import as failed
+func FailMediaImport(ctx context.Context, mediaID, message string) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE media_files SET status = 'failed', error_message = $2
+		WHERE media_id = $1 AND status = 'importing'
+	`, mediaID, message)
+
+	return err
+}
+
+// FailInterruptedImports marks imports left running by a previous process as failed
+func FailInterruptedImports(ctx context.Context, message string) (int64, error) {
+	if !IsDBAvailable() {
+		return 0, nil
+	}
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE media_files SET status = 'failed', error_message = $1 WHERE status = 'importing'
+	`, message)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteMediaFile removes a media file and the records that depend on it.
+// Swap tasks run on the media and its face detections are deleted, so their
+// detect_id can't be swapped any more; tasks that used it as a face keep
+// running history but drop the key. Returns every storage key that is no
+// longer referenced and should be removed from storage.
+func DeleteMediaFile(ctx context.Context, mediaID string) ([]string, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var storageKey, thumbnailKey, normalizedKey sql.NullString
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM media_files WHERE media_id = $1
+		RETURNING storage_key, thumbnail_key, normalized_key
+	`, mediaID).Scan(&storageKey, &thumbnailKey, &normalizedKey)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, k := range []sql.NullString{storageKey, thumbnailKey, normalizedKey} {
+		if k.Valid && k.String != "" {
+			keys = append(keys, k.String)
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM swap_tasks
+		WHERE media_id = $1 OR (target_video_key IS NOT NULL AND target_video_key = $2)
+		RETURNING result_key
+	`, mediaID, storageKey)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var resultKey sql.NullString
+		if err := rows.Scan(&resultKey); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if resultKey.Valid && resultKey.String != "" {
+			keys = append(keys, resultKey.String)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM face_detections
+		WHERE media_id = $1 OR (media_key IS NOT NULL AND media_key = $2)
+	`, mediaID, storageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, k := range []sql.NullString{storageKey, normalizedKey} {
+		if !k.Valid {
+			continue
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE swap_tasks
+			SET source_face_keys = array_remove(source_face_keys, $1)
+			WHERE $1 = ANY(source_face_keys)
+		`, k.String)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, tx.Commit()
+}
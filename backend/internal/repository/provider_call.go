@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ProviderCall is one HTTP request to a provider API and its outcome
+type ProviderCall struct {
+	ID         int64
+	Provider   string
+	TaskID     sql.NullString
+	Version    sql.NullString
+	Method     string
+	Endpoint   string
+	Attempt    int
+	Request    []byte // JSON, nil for GET
+	StatusCode sql.NullInt32
+	Response   sql.NullString
+	Error      sql.NullString
+	DurationMS int64
+	CreatedAt  time.Time
+}
+
+// SaveProviderCall records a provider request
+func SaveProviderCall(ctx context.Context, pc *ProviderCall) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	return db.QueryRowContext(ctx, `
+		INSERT INTO provider_calls (provider, task_id, version, method, endpoint, attempt, request,
+		                            status_code, response, error, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+	`, pc.Provider, pc.TaskID, pc.Version, pc.Method, pc.Endpoint, pc.Attempt, nullJSON(pc.Request),
+		pc.StatusCode, pc.Response, pc.Error, pc.DurationMS).Scan(&pc.ID, &pc.CreatedAt)
+}
+
+// ListProviderCalls returns the calls made for a task, oldest first
+func ListProviderCalls(ctx context.Context, taskID string, limit int) ([]ProviderCall, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, provider, task_id, version, method, endpoint, attempt, request,
+		       status_code, response, error, duration_ms, created_at
+		FROM provider_calls
+		WHERE task_id = $1
+		ORDER BY created_at, id
+		LIMIT $2
+	`, taskID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calls []ProviderCall
+	for rows.Next() {
+		var pc ProviderCall
+		if err := rows.Scan(&pc.ID, &pc.Provider, &pc.TaskID, &pc.Version, &pc.Method, &pc.Endpoint, &pc.Attempt,
+			&pc.Request, &pc.StatusCode, &pc.Response, &pc.Error, &pc.DurationMS, &pc.CreatedAt); err != nil {
+			return nil, err
+		}
+		calls = append(calls, pc)
+	}
+	return calls, rows.Err()
+}
+
+// DeleteProviderCallsBefore drops calls older than the cutoff
+func DeleteProviderCallsBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	if !IsDBAvailable() {
+		return 0, nil
+	}
+
+	res, err := db.ExecContext(ctx, `DELETE FROM provider_calls WHERE created_at < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// nullJSON stores an empty body as NULL rather than invalid JSON
+func nullJSON(b []byte) interface{} {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// usageEventsSQL lists billable activity as (user, tool, time, tasks, credits,
+// bytes) rows. Credits come from the ledger only, which has the VModel cost of
+// swaps ("swap") and detections ("detect"); detections without a reported cost
+// count as tasks only. Storage is counted when it is added: uploads and imports
+// when created, results when transferred.
+const usageEventsSQL = `
+	SELECT user_id, 'faceswap' AS tool, created_at AS at, 1 AS tasks, 0::float8 AS credits, 0::bigint AS bytes
+	FROM swap_tasks
+	UNION ALL
+	SELECT user_id, 'face_detect', created_at, 1, 0, 0
+	FROM face_detections
+	UNION ALL
+	SELECT user_id, CASE kind WHEN 'swap' THEN 'faceswap' WHEN 'detect' THEN 'face_detect' ELSE kind END,
+	       created_at, 0, credits::float8, 0
+	FROM credit_ledger
+	UNION ALL
+	SELECT user_id, 'media', created_at, 0, 0,
+	       COALESCE(file_size, 0) + COALESCE(normalized_size, 0) + COALESCE(thumbnail_size, 0)
+	FROM media_files WHERE storage_key IS NOT NULL
+	UNION ALL
+	SELECT user_id, 'faceswap', completed_at, 0, 0, COALESCE(result_size, 0)
+	FROM swap_tasks WHERE result_key IS NOT NULL AND completed_at IS NOT NULL`
+
+// Report groupings and periods; the values are used in the query as is
+var (
+	reportGroups = map[string]struct{ id, name string }{
+		"user": {"u.id", "u.email"},
+		"team": {"t.id", "COALESCE(t.name, '')"},
+		"tool": {"NULL::bigint", "e.tool"},
+	}
+	reportPeriods = map[string]bool{"day": true, "month": true}
+)
+
+// UsageReportFilter selects the activity a report covers
+type UsageReportFilter struct {
+	From   time.Time // Inclusive
+	To     time.Time // Exclusive
+	Group  string    // user, team or tool
+	Period string    // day or month
+	UserID int64     // 0 = all users
+	TeamID int64     // 0 = all teams
+}
+
+// UsageReportRow is the activity of one user, team or tool in one period
+type UsageReportRow struct {
+	Period       time.Time
+	GroupID      sql.NullInt64 // User or team ID; NULL for tools and users without a team
+	Group        string        // User email, team name or tool
+	Tasks        int64
+	Credits      float64
+	StorageBytes int64
+}
+
+// GetUsageReport aggregates tasks, credits and added storage by group and period
+func GetUsageReport(ctx context.Context, f UsageReportFilter) ([]UsageReportRow, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+	group, ok := reportGroups[f.Group]
+	if !ok {
+		return nil, fmt.Errorf("unknown report group %q", f.Group)
+	}
+	if !reportPeriods[f.Period] {
+		return nil, fmt.Errorf("unknown report period %q", f.Period)
+	}
+
+	query := `
+		SELECT date_trunc('` + f.Period + `', e.at) AS period, ` + group.id + `, ` + group.name + `,
+		       sum(e.tasks), sum(e.credits), sum(e.bytes)
+		FROM (` + usageEventsSQL + `) e
+		JOIN users u ON u.id = e.user_id
+		LEFT JOIN teams t ON t.id = u.team_id
+		WHERE e.at >= $1 AND e.at < $2`
+	args := []interface{}{f.From, f.To}
+	if f.UserID > 0 {
+		args = append(args, f.UserID)
+		query += fmt.Sprintf(" AND u.id = $%d", len(args))
+	}
+	if f.TeamID > 0 {
+		args = append(args, f.TeamID)
+		query += fmt.Sprintf(" AND u.team_id = $%d", len(args))
+	}
+	query += ` GROUP BY 1, 2, 3 ORDER BY 1, 3`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []UsageReportRow
+	for rows.Next() {
+		var r UsageReportRow
+		if err := rows.Scan(&r.Period, &r.GroupID, &r.Group, &r.Tasks, &r.Credits, &r.StorageBytes); err != nil {
+			return nil, err
+		}
+		report = append(report, r)
+	}
+	return report, rows.Err()
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type ShareLink struct {
+	ID             int64
+	TokenHash      string
+	UserID         int64
+	TaskID         string
+	PasswordHash   sql.NullString
+	MaxDownloads   sql.NullInt32
+	ViewCount      int
+	DownloadCount  int
+	ExpiresAt      time.Time
+	RevokedAt      sql.NullTime
+	LastAccessedAt sql.NullTime
+	CreatedAt      time.Time
+}
+
+const shareLinkColumns = `id, token_hash, user_id, task_id, password_hash, max_downloads,
+		       view_count, download_count, expires_at, revoked_at, last_accessed_at, created_at`
+
+func scanShareLink(row rowScanner) (*ShareLink, error) {
+	var s ShareLink
+	err := row.Scan(&s.ID, &s.TokenHash, &s.UserID, &s.TaskID, &s.PasswordHash, &s.MaxDownloads,
+		&s.ViewCount, &s.DownloadCount, &s.ExpiresAt, &s.RevokedAt, &s.LastAccessedAt, &s.CreatedAt)
+	return &s, err
+}
+
+// CreateShareLink saves a new share link
+func CreateShareLink(ctx context.Context, s *ShareLink) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	return db.QueryRowContext(ctx, `
+		INSERT INTO share_links (token_hash, user_id, task_id, password_hash, max_downloads, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, s.TokenHash, s.UserID, s.TaskID, s.PasswordHash, s.MaxDownloads, s.ExpiresAt).Scan(&s.ID, &s.CreatedAt)
+}
+
+// GetShareLinkByTokenHash retrieves a share link, including expired and revoked ones
+func GetShareLinkByTokenHash(ctx context.Context, tokenHash string) (*ShareLink, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	s, err := scanShareLink(db.QueryRowContext(ctx, `
+		SELECT `+shareLinkColumns+` FROM share_links WHERE token_hash = $1
+	`, tokenHash))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// ListShareLinks returns a task's share links, newest first
+func ListShareLinks(ctx context.Context, taskID string) ([]ShareLink, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+shareLinkColumns+` FROM share_links WHERE task_id = $1 ORDER BY created_at DESC
+	`, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []ShareLink
+	for rows.Next() {
+		s, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, *s)
+	}
+	return links, rows.Err()
+}
+
+// RevokeShareLink revokes a task's share link, returns false if there was none to revoke
+func RevokeShareLink(ctx context.Context, taskID string, shareID int64) (bool, error) {
+	if !IsDBAvailable() {
+		return false, nil
+	}
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE share_links SET revoked_at = NOW()
+		WHERE id = $1 AND task_id = $2 AND revoked_at IS NULL
+	`, shareID, taskID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ClaimShareAccess counts one view or download of a share link if it is still
+// usable: not revoked, not expired and under its limit. Returns false otherwise.
+func ClaimShareAccess(ctx context.Context, shareID int64, download bool) (bool, error) {
+	if !IsDBAvailable() {
+		return false, nil
+	}
+
+	counter := "view_count"
+	if download {
+		counter = "download_count"
+	}
+	result, err := db.ExecContext(ctx, `
+		UPDATE share_links
+		SET `+counter+` = `+counter+` + 1, last_accessed_at = NOW()
+		WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		  AND (max_downloads IS NULL OR view_count + download_count < max_downloads)
+	`, shareID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// storageKeyPattern extracts an object key from a stored URL, matching the key prefixes
+const storageKeyPattern = `((?:videos|images|faces|frames|results|thumbs)/[^?#]+)`
+
+// StorageRewrite describes the DB pass of a storage migration
+type StorageRewrite struct {
+	FromBackend string // Rows on this backend (or with none recorded) are moved...
+	ToBackend   string // ...to this one
+	FromURL     string // Optional: legacy URL columns starting with FromURL...
+	ToURL       string // ...get that base replaced by ToURL
+	DryRun      bool   // Count affected rows, then roll back
+}
+
+// RewriteStorageReferences points DB records at the new storage after objects were
+// copied: keys are backfilled from legacy URL columns, backends are switched and,
+// when asked, legacy URLs are rebased. Returns affected rows per statement.
+func RewriteStorageReferences(ctx context.Context, rw StorageRewrite) (map[string]int64, error) {
+	if !IsDBAvailable() {
+		return nil, fmt.Errorf("database not configured")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	type statement struct {
+		name  string
+		query string
+		args  []interface{}
+	}
+	statements := []statement{
+		{"media_files.storage_key (backfill)", `
+			UPDATE media_files SET storage_key = substring(storage_url FROM '` + storageKeyPattern + `')
+			WHERE storage_key IS NULL AND storage_url IS NOT NULL`, nil},
+		{"media_files.thumbnail_key (backfill)", `
+			UPDATE media_files SET thumbnail_key = substring(thumbnail_url FROM '` + storageKeyPattern + `')
+			WHERE thumbnail_key IS NULL AND thumbnail_url IS NOT NULL`, nil},
+		{"swap_tasks.result_key (backfill)", `
+			UPDATE swap_tasks SET result_key = substring(result_url FROM '` + storageKeyPattern + `')
+			WHERE result_key IS NULL AND result_url IS NOT NULL`, nil},
+	}
+
+	if rw.FromBackend != rw.ToBackend {
+		statements = append(statements,
+			statement{"media_files.storage_backend", `
+				UPDATE media_files SET storage_backend = $2
+				WHERE storage_key IS NOT NULL AND COALESCE(storage_backend, $1) = $1`,
+				[]interface{}{rw.FromBackend, rw.ToBackend}},
+			statement{"swap_tasks.storage_backend", `
+				UPDATE swap_tasks SET storage_backend = $2
+				WHERE result_key IS NOT NULL AND COALESCE(storage_backend, $1) = $1`,
+				[]interface{}{rw.FromBackend, rw.ToBackend}},
+		)
+	}
+
+	if rw.FromURL != "" && rw.ToURL != "" {
+		for _, col := range []struct{ table, column string }{
+			{"media_files", "storage_url"},
+			{"media_files", "thumbnail_url"},
+			{"swap_tasks", "result_url"},
+		} {
+			statements = append(statements, statement{col.table + "." + col.column, fmt.Sprintf(`
+				UPDATE %[1]s SET %[2]s = $2 || substr(%[2]s, length($1) + 1)
+				WHERE left(%[2]s, length($1)) = $1`, col.table, col.column),
+				[]interface{}{rw.FromURL, rw.ToURL}})
+		}
+	}
+
+	affected := make(map[string]int64)
+	for _, st := range statements {
+		res, err := tx.ExecContext(ctx, st.query, st.args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", st.name, err)
+		}
+		affected[st.name], _ = res.RowsAffected()
+	}
+
+	if rw.DryRun {
+		return affected, nil // Deferred rollback discards the changes
+	}
+	return affected, tx.Commit()
+}
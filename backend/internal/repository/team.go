@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Team struct {
+	ID           int64
+	Name         string
+	StorageQuota sql.NullInt64 // Bytes; NULL uses the configured default
+	CreatedAt    time.Time
+}
+
+// CreateTeam creates a team
+func CreateTeam(ctx context.Context, name string, storageQuota sql.NullInt64) (*Team, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	t := Team{Name: name, StorageQuota: storageQuota}
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO teams (name, storage_quota) VALUES ($1, $2)
+		RETURNING id, created_at
+	`, name, storageQuota).Scan(&t.ID, &t.CreatedAt)
+	return &t, err
+}
+
+// GetTeam retrieves a team by ID
+func GetTeam(ctx context.Context, teamID int64) (*Team, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	var t Team
+	err := db.QueryRowContext(ctx, `
+		SELECT id, name, storage_quota, created_at FROM teams WHERE id = $1
+	`, teamID).Scan(&t.ID, &t.Name, &t.StorageQuota, &t.CreatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &t, err
+}
+
+// ListTeams returns all teams by name
+func ListTeams(ctx context.Context) ([]Team, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, name, storage_quota, created_at FROM teams ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teams []Team
+	for rows.Next() {
+		var t Team
+		if err := rows.Scan(&t.ID, &t.Name, &t.StorageQuota, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	return teams, rows.Err()
+}
+
+// UpdateTeam renames a team and/or changes its quota. A nil name keeps the
+// current one; a nil quota keeps it, an invalid NullInt64 resets it to the default.
+func UpdateTeam(ctx context.Context, teamID int64, name *string, storageQuota *sql.NullInt64) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE teams
+		SET name = COALESCE($2, name),
+		    storage_quota = CASE WHEN $3 THEN $4 ELSE storage_quota END
+		WHERE id = $1
+	`, teamID, name, storageQuota != nil, nullableQuota(storageQuota))
+	return err
+}
+
+// SetUserStorage moves a user to a team and/or changes their quota, with the
+// same nil/invalid semantics as UpdateTeam. An invalid team ID removes the user from their team.
+func SetUserStorage(ctx context.Context, userID int64, teamID *sql.NullInt64, storageQuota *sql.NullInt64) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	var team sql.NullInt64
+	if teamID != nil {
+		team = *teamID
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE users
+		SET team_id = CASE WHEN $2 THEN $3 ELSE team_id END,
+		    storage_quota = CASE WHEN $4 THEN $5 ELSE storage_quota END
+		WHERE id = $1
+	`, userID, teamID != nil, team, storageQuota != nil, nullableQuota(storageQuota))
+	return err
+}
+
+func nullableQuota(q *sql.NullInt64) sql.NullInt64 {
+	if q == nil {
+		return sql.NullInt64{}
+	}
+	return *q
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// storageObjectsSQL lists every stored object a user owns with its size: media
+// originals and their recorded variants, and transferred swap results.
+// On-demand thumbnail sizes are cache and not counted.
+const storageObjectsSQL = `
+	SELECT user_id, split_part(storage_key, '/', 1) AS prefix, COALESCE(file_size, 0) AS bytes
+	FROM media_files WHERE storage_key IS NOT NULL
+	UNION ALL
+	SELECT user_id, split_part(normalized_key, '/', 1), COALESCE(normalized_size, 0)
+	FROM media_files WHERE normalized_key IS NOT NULL
+	UNION ALL
+	SELECT user_id, split_part(thumbnail_key, '/', 1), COALESCE(thumbnail_size, 0)
+	FROM media_files WHERE thumbnail_key IS NOT NULL
+	UNION ALL
+	SELECT user_id, 'results', COALESCE(result_size, 0)
+	FROM swap_tasks WHERE result_key IS NOT NULL`
+
+// StorageUsage is the usage under one storage prefix
+type StorageUsage struct {
+	Prefix  string
+	Bytes   int64
+	Objects int64
+}
+
+// StorageQuotas holds a user's quota overrides and team membership
+type StorageQuotas struct {
+	UserQuota sql.NullInt64
+	TeamID    sql.NullInt64
+	TeamName  sql.NullString
+	TeamQuota sql.NullInt64
+}
+
+// StorageConsumer is one row of the top consumers listing, a user or a team
+type StorageConsumer struct {
+	ID      int64
+	Name    string // User email or team name
+	TeamID  sql.NullInt64
+	Quota   sql.NullInt64
+	Bytes   int64
+	Objects int64
+}
+
+// GetUserStorageUsage returns a user's usage by prefix
+func GetUserStorageUsage(ctx context.Context, userID int64) ([]StorageUsage, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+	return queryStorageUsage(ctx, `
+		SELECT prefix, sum(bytes), count(*) FROM (`+storageObjectsSQL+`) o
+		WHERE user_id = $1
+		GROUP BY prefix ORDER BY prefix
+	`, userID)
+}
+
+// GetTeamStorageUsage returns the combined usage of a team's members by prefix
+func GetTeamStorageUsage(ctx context.Context, teamID int64) ([]StorageUsage, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+	return queryStorageUsage(ctx, `
+		SELECT prefix, sum(bytes), count(*) FROM (`+storageObjectsSQL+`) o
+		WHERE user_id IN (SELECT id FROM users WHERE team_id = $1)
+		GROUP BY prefix ORDER BY prefix
+	`, teamID)
+}
+
+func queryStorageUsage(ctx context.Context, query string, args ...interface{}) ([]StorageUsage, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []StorageUsage
+	for rows.Next() {
+		var u StorageUsage
+		if err := rows.Scan(&u.Prefix, &u.Bytes, &u.Objects); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// GetStorageQuotas returns the quota overrides that apply to a user
+func GetStorageQuotas(ctx context.Context, userID int64) (*StorageQuotas, error) {
+	if !IsDBAvailable() {
+		return &StorageQuotas{}, nil
+	}
+
+	var q StorageQuotas
+	err := db.QueryRowContext(ctx, `
+		SELECT u.storage_quota, u.team_id, t.name, t.storage_quota
+		FROM users u LEFT JOIN teams t ON t.id = u.team_id
+		WHERE u.id = $1
+	`, userID).Scan(&q.UserQuota, &q.TeamID, &q.TeamName, &q.TeamQuota)
+
+	if err == sql.ErrNoRows {
+		return &StorageQuotas{}, nil
+	}
+	return &q, err
+}
+
+// ListTopStorageConsumers returns the users (or teams) using the most storage
+func ListTopStorageConsumers(ctx context.Context, byTeam bool, limit int) ([]StorageConsumer, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	query := `
+		SELECT u.id, u.email, u.team_id, u.storage_quota, sum(o.bytes), count(*)
+		FROM (` + storageObjectsSQL + `) o JOIN users u ON u.id = o.user_id
+		GROUP BY u.id
+		ORDER BY sum(o.bytes) DESC
+		LIMIT $1`
+	if byTeam {
+		query = `
+		SELECT t.id, t.name, t.id, t.storage_quota, sum(o.bytes), count(*)
+		FROM (` + storageObjectsSQL + `) o
+		JOIN users u ON u.id = o.user_id
+		JOIN teams t ON t.id = u.team_id
+		GROUP BY t.id
+		ORDER BY sum(o.bytes) DESC
+		LIMIT $1`
+	}
+
+	rows, err := db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var consumers []StorageConsumer
+	for rows.Next() {
+		var c StorageConsumer
+		if err := rows.Scan(&c.ID, &c.Name, &c.TeamID, &c.Quota, &c.Bytes, &c.Objects); err != nil {
+			return nil, err
+		}
+		consumers = append(consumers, c)
+	}
+	return consumers, rows.Err()
+}
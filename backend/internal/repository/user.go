@@ -3,20 +3,44 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+// User roles. Admins can use /api/v2/admin, viewers can only read.
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleViewer = "viewer"
+)
+
+// ValidRole reports whether role is one of the user roles
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleMember || role == RoleViewer
+}
+
+// Verification code purposes
+const (
+	CodeLogin         = "login"
+	CodePasswordReset = "password_reset"
 )
 
 type User struct {
-	ID          int64
-	Email       string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	LastLoginAt sql.NullTime
+	ID           int64
+	Email        string
+	Role         string
+	PasswordHash sql.NullString // bcrypt; unset for users who only sign in with codes
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	LastLoginAt  sql.NullTime
 }
 
 type VerificationCode struct {
 	ID        int64
 	Email     string
+	Purpose   string
 	Code      string
 	ExpiresAt time.Time
 	Used      bool
@@ -26,6 +50,7 @@ type VerificationCode struct {
 type Session struct {
 	ID        int64
 	UserID    int64
+	Role      string // The user's current role
 	Token     string
 	ExpiresAt time.Time
 	CreatedAt time.Time
@@ -47,45 +72,70 @@ func CreateOrGetUser(ctx context.Context, email string) (int64, error) {
 	return userID, err
 }
 
-// SaveVerificationCode saves a verification code
-func SaveVerificationCode(ctx context.Context, email, code string, expiresAt time.Time) error {
+// SaveVerificationCode saves a verification code for a purpose
+func SaveVerificationCode(ctx context.Context, email, purpose, code string, expiresAt time.Time) error {
 	if !IsDBAvailable() {
 		return nil
 	}
 
-	// Invalidate previous codes for this email
+	// Invalidate previous codes for this email and purpose
 	_, err := db.ExecContext(ctx, `
-		UPDATE verification_codes SET used = TRUE WHERE email = $1 AND used = FALSE
-	`, email)
+		UPDATE verification_codes SET used = TRUE WHERE email = $1 AND purpose = $2 AND used = FALSE
+	`, email, purpose)
 	if err != nil {
 		return err
 	}
 
 	// Insert new code
 	_, err = db.ExecContext(ctx, `
-		INSERT INTO verification_codes (email, code, expires_at) VALUES ($1, $2, $3)
-	`, email, code, expiresAt)
+		INSERT INTO verification_codes (email, purpose, code, expires_at) VALUES ($1, $2, $3, $4)
+	`, email, purpose, code, expiresAt)
 
 	return err
 }
 
-// VerifyCode checks and marks code as used
-func VerifyCodeDB(ctx context.Context, email, code string) (bool, error) {
+// VerifyCodeDB checks the email's current code for a purpose. A match marks
+// it used; a mismatch counts an attempt, and the attempt reaching
+// maxAttempts locks the code. locked is true only for the locking attempt.
+func VerifyCodeDB(ctx context.Context, email, purpose, code string, maxAttempts int) (valid, locked bool, err error) {
 	if !IsDBAvailable() {
-		return false, nil
+		return false, false, nil
 	}
 
-	result, err := db.ExecContext(ctx, `
-		UPDATE verification_codes
-		SET used = TRUE
-		WHERE email = $1 AND code = $2 AND expires_at > NOW() AND used = FALSE
-	`, email, code)
-	if err != nil {
-		return false, err
+	err = db.QueryRowContext(ctx, `
+		WITH c AS (
+			SELECT id, code = $3 AS match
+			FROM verification_codes
+			WHERE email = $1 AND purpose = $2 AND used = FALSE AND expires_at > NOW()
+			ORDER BY created_at DESC
+			LIMIT 1
+			FOR UPDATE
+		)
+		UPDATE verification_codes v
+		SET attempts = v.attempts + CASE WHEN c.match THEN 0 ELSE 1 END,
+		    used = c.match OR v.attempts + 1 >= $4,
+		    locked_at = CASE WHEN NOT c.match AND v.attempts + 1 >= $4 THEN NOW() END
+		FROM c
+		WHERE v.id = c.id
+		RETURNING c.match, v.locked_at IS NOT NULL
+	`, email, purpose, code, maxAttempts).Scan(&valid, &locked)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	return valid, locked, err
+}
+
+// LastCodeSentAt returns when the email was last sent a code for a purpose
+func LastCodeSentAt(ctx context.Context, email, purpose string) (sql.NullTime, error) {
+	var sentAt sql.NullTime
+	if !IsDBAvailable() {
+		return sentAt, nil
 	}
 
-	rows, err := result.RowsAffected()
-	return rows > 0, err
+	err := db.QueryRowContext(ctx, `
+		SELECT MAX(created_at) FROM verification_codes WHERE email = $1 AND purpose = $2
+	`, email, purpose).Scan(&sentAt)
+	return sentAt, err
 }
 
 // CreateSession creates a new session
@@ -109,10 +159,11 @@ func GetSessionByToken(ctx context.Context, token string) (*Session, error) {
 
 	var s Session
 	err := db.QueryRowContext(ctx, `
-		SELECT id, user_id, token, expires_at, created_at
-		FROM sessions
-		WHERE token = $1 AND expires_at > NOW()
-	`, token).Scan(&s.ID, &s.UserID, &s.Token, &s.ExpiresAt, &s.CreatedAt)
+		SELECT s.id, s.user_id, u.role, s.token, s.expires_at, s.created_at
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.token = $1 AND s.expires_at > NOW()
+	`, token).Scan(&s.ID, &s.UserID, &s.Role, &s.Token, &s.ExpiresAt, &s.CreatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -156,3 +207,164 @@ func CleanupExpiredSessions(ctx context.Context) error {
 	`)
 	return err
 }
+
+// GetUserByID retrieves a user by ID
+func GetUserByID(ctx context.Context, userID int64) (*User, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	u, err := scanUser(db.QueryRowContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE id = $1
+	`, userID))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return u, err
+}
+
+// GetUserByEmail retrieves a user by email, case-insensitively
+func GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	u, err := scanUser(db.QueryRowContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE lower(email) = lower($1)
+		ORDER BY id
+		LIMIT 1
+	`, email))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return u, err
+}
+
+// CreateUser adds a user created by an admin. Returns nil if the email is taken.
+func CreateUser(ctx context.Context, email, role string, passwordHash sql.NullString, createdBy int64) (*User, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	var changedAt sql.NullTime
+	if passwordHash.Valid {
+		changedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	u, err := scanUser(db.QueryRowContext(ctx, `
+		INSERT INTO users (email, role, password_hash, password_changed_at, created_by)
+		SELECT $1, $2, $3, $4, $5
+		WHERE NOT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($1))
+		ON CONFLICT (email) DO NOTHING
+		RETURNING `+userColumns,
+		email, role, passwordHash, changedAt, sql.NullInt64{Int64: createdBy, Valid: createdBy > 0}))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return u, err
+}
+
+// SetUserPassword replaces a user's password hash
+func SetUserPassword(ctx context.Context, userID int64, passwordHash string) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `
+		UPDATE users SET password_hash = $2, password_changed_at = NOW(), updated_at = NOW() WHERE id = $1
+	`, userID, passwordHash)
+	return err
+}
+
+// DeleteUserSessions signs a user out everywhere
+func DeleteUserSessions(ctx context.Context, userID int64) error {
+	if !IsDBAvailable() {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID)
+	return err
+}
+
+const userColumns = `id, email, role, password_hash, created_at, updated_at, last_login_at`
+
+func scanUser(row rowScanner) (*User, error) {
+	var u User
+	if err := row.Scan(&u.ID, &u.Email, &u.Role, &u.PasswordHash, &u.CreatedAt, &u.UpdatedAt, &u.LastLoginAt); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// ListUsers returns users, optionally only those with a role, ordered by email
+func ListUsers(ctx context.Context, role string) ([]User, error) {
+	if !IsDBAvailable() {
+		return nil, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE $1 = '' OR role = $1
+		ORDER BY email
+	`, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// SetUserRole changes a user's role. Returns false if there is no such user.
+func SetUserRole(ctx context.Context, userID int64, role string) (bool, error) {
+	if !IsDBAvailable() {
+		return false, nil
+	}
+
+	res, err := db.ExecContext(ctx, `
+		UPDATE users SET role = $2, updated_at = NOW() WHERE id = $1
+	`, userID, role)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// PromoteAdmins gives the admin role to the existing users with these emails,
+// but only while there is no admin at all: once one exists, roles are theirs
+// to assign and a demoted user stays demoted.
+func PromoteAdmins(ctx context.Context, emails []string) (int64, error) {
+	if !IsDBAvailable() || len(emails) == 0 {
+		return 0, nil
+	}
+
+	lower := make([]string, len(emails))
+	for i, e := range emails {
+		lower[i] = strings.ToLower(e)
+	}
+	res, err := db.ExecContext(ctx, `
+		UPDATE users SET role = 'admin', updated_at = NOW()
+		WHERE lower(email) = ANY($1) AND role <> 'admin'
+		  AND NOT EXISTS (SELECT 1 FROM users WHERE role = 'admin')
+	`, pq.Array(lower))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashPassword(t *testing.T) {
+	for _, pw := range []string{"", "short", "        ", strings.Repeat("a", 73)} {
+		if _, err := HashPassword(pw); !errors.Is(err, ErrWeakPassword) {
+			t.Errorf("HashPassword(%q) error = %v, want ErrWeakPassword", pw, err)
+		}
+	}
+
+	hash, err := HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("HashPassword failed: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte("correct horse")) != nil {
+		t.Error("hash does not match the password")
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"playplus_platform/internal/repository"
+)
+
+// maxTaskCalls caps the provider calls returned with a task
+const maxTaskCalls = 200
+
+var (
+	// ErrTaskNotSubmitted is returned for swap tasks that never reached the provider
+	ErrTaskNotSubmitted = errors.New("task was not submitted to the provider")
+	// ErrNoProviderResult is returned when re-transferring a task the provider has no result for
+	ErrNoProviderResult = errors.New("provider has no result for the task")
+	// ErrTransferInProgress is returned when a transfer of the task is already running
+	ErrTransferInProgress = errors.New("transfer already in progress")
+	// ErrTaskFinished is returned when marking a completed task failed
+	ErrTaskFinished = errors.New("task already completed")
+)
+
+// AdminTask is a detect or swap task with what we know about its provider calls
+type AdminTask struct {
+	Type      string // detect or swap
+	Swap      *repository.SwapTask
+	Detection *repository.FaceDetection
+	Calls     []repository.ProviderCall
+	Transfer  *TransferStatus // In-memory state of a recent transfer, if any
+}
+
+// TaskID returns the task's current ID
+func (t *AdminTask) TaskID() string {
+	if t.Swap != nil {
+		return t.Swap.TaskID
+	}
+	return t.Detection.TaskID
+}
+
+// GetAdminTask loads any user's task by ID with its recorded provider calls.
+// Returns nil if there is no such task.
+func GetAdminTask(ctx context.Context, taskID string) (*AdminTask, error) {
+	t := &AdminTask{}
+	swap, err := repository.GetSwapTask(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("load swap task: %w", err)
+	}
+	if swap != nil {
+		t.Type, t.Swap = "swap", swap
+		t.Transfer = GetStorageService().GetTransferStatus(swap.TaskID)
+	} else {
+		d, err := repository.GetFaceDetection(ctx, taskID)
+		if err != nil {
+			return nil, fmt.Errorf("load detection: %w", err)
+		}
+		if d == nil {
+			return nil, nil
+		}
+		t.Type, t.Detection = "detect", d
+	}
+
+	if t.Calls, err = repository.ListProviderCalls(ctx, t.TaskID(), maxTaskCalls); err != nil {
+		return nil, fmt.Errorf("load provider calls: %w", err)
+	}
+	return t, nil
+}
+
+// RefreshAdminTask fetches the task's status from VModel and stores it. A
+// completed swap whose result isn't stored yet gets its transfer started.
+func RefreshAdminTask(ctx context.Context, t *AdminTask) error {
+	vmodel := GetVModelClient()
+	if t.Detection != nil {
+		result, err := vmodel.GetDetectTaskStatus(ctx, t.Detection.TaskID)
+		if result == nil {
+			return err
+		}
+		UpdateDetection(ctx, t.Detection, result)
+		return nil
+	}
+
+	task := t.Swap
+	if !SwapSubmitted(task) {
+		return ErrTaskNotSubmitted
+	}
+	result, err := vmodel.GetTaskStatus(ctx, task.TaskID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case result.Status == "completed" && result.ResultURL != "" && !task.ResultKey.Valid:
+		// Marked completed once the transfer is done, as with user polling
+		GetStorageService().TransferFromVModel(task.TaskID, result.ResultURL)
+	case result.Status != task.Status && result.Status != "completed":
+		var errMsg *string
+		if result.Status == "failed" {
+			errMsg = &result.Error
+		}
+		if err := repository.UpdateSwapTaskStatus(ctx, task.TaskID, result.Status, nil, errMsg); err != nil {
+			return fmt.Errorf("update swap task: %w", err)
+		}
+		task.Status = result.Status
+	}
+	log.Printf("[INFO] Admin refreshed swap task %s: %s", task.TaskID, result.Status)
+	return nil
+}
+
+// RetransferAdminTask copies the result from VModel to storage again, e.g.
+// after a failed transfer or a lost object
+func RetransferAdminTask(ctx context.Context, t *AdminTask) error {
+	if t.Swap == nil || !SwapSubmitted(t.Swap) {
+		return ErrTaskNotSubmitted
+	}
+	storage := GetStorageService()
+	if storage.IsTransferring(t.Swap.TaskID) {
+		return ErrTransferInProgress
+	}
+
+	result, err := GetVModelClient().GetTaskStatus(ctx, t.Swap.TaskID)
+	if err != nil {
+		return err
+	}
+	if result.Status != "completed" || result.ResultURL == "" {
+		return ErrNoProviderResult
+	}
+
+	// The cached entry would make a completed transfer a no-op
+	transferCache.Delete(t.Swap.TaskID)
+	storage.TransferFromVModel(t.Swap.TaskID, result.ResultURL)
+	log.Printf("[INFO] Admin restarted the result transfer of swap task %s", t.Swap.TaskID)
+	return nil
+}
+
+// FailAdminTask marks a stuck task failed with the admin's reason
+func FailAdminTask(ctx context.Context, t *AdminTask, reason string) error {
+	if t.Detection != nil {
+		d := t.Detection
+		if d.Status == "completed" {
+			return ErrTaskFinished
+		}
+		d.Status = "failed"
+		d.ErrorMessage = nullString(reason)
+		d.CompletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		if err := repository.UpdateFaceDetection(ctx, d); err != nil {
+			return err
+		}
+		log.Printf("[INFO] Admin marked detection %s failed: %s", d.TaskID, reason)
+		return nil
+	}
+
+	if t.Swap.Status == "completed" {
+		return ErrTaskFinished
+	}
+	if err := repository.UpdateSwapTaskStatus(ctx, t.Swap.TaskID, "failed", nil, &reason); err != nil {
+		return err
+	}
+	t.Swap.Status = "failed"
+	t.Swap.ErrorMessage = nullString(reason)
+	log.Printf("[INFO] Admin marked swap task %s failed: %s", t.Swap.TaskID, reason)
+	return nil
+}
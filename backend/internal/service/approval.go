@@ -0,0 +1,253 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+// Swap task statuses used by the approval workflow
+const (
+	SwapStatusPendingApproval = "pending_approval"
+	SwapStatusApproved        = "approved" // Submitting to the provider
+	SwapStatusRejected        = "rejected"
+)
+
+var (
+	// ErrApprovalNotFound is returned for tasks that don't exist or never needed approval
+	ErrApprovalNotFound = errors.New("approval request not found")
+	// ErrApprovalNotPending is returned when a task was already approved or rejected
+	ErrApprovalNotPending = errors.New("task is not pending approval")
+)
+
+// ApprovalRequired reports whether an estimated task needs an admin's sign-off:
+// its video has no known duration, it is over the limit on its own, or its
+// batch would exceed the batch limit. The reason is shown to the user and the
+// reviewers.
+func ApprovalRequired(ctx context.Context, userID int64, batchID string, est *SwapEstimate) (bool, string) {
+	// Pending tasks live in the DB only
+	if !repository.IsDBAvailable() {
+		return false, ""
+	}
+	credits := est.Credits
+	if est.DurationUnknown {
+		return true, fmt.Sprintf("Video duration is unknown; it may cost up to %.2f credits", credits)
+	}
+	cfg := config.Get()
+	if cfg.ApprovalCreditLimit > 0 && credits > cfg.ApprovalCreditLimit {
+		return true, fmt.Sprintf("Estimated %.2f credits exceeds the approval limit of %.2f", credits, cfg.ApprovalCreditLimit)
+	}
+	if cfg.ApprovalBatchCreditLimit > 0 && batchID != "" {
+		total, err := repository.SumBatchCredits(ctx, userID, batchID)
+		if err != nil {
+			log.Printf("[WARN] Failed to total credits of batch %s: %v", batchID, err)
+			return false, ""
+		}
+		if total+credits > cfg.ApprovalBatchCreditLimit {
+			return true, fmt.Sprintf("Batch %s would reach %.2f credits, over the approval limit of %.2f", batchID, total+credits, cfg.ApprovalBatchCreditLimit)
+		}
+	}
+	return false, ""
+}
+
+// SubmitSwap sends a swap task to VModel and fills in the provider's task ID and
+// status. CreditsUsed is taken as the estimate and replaced by what VModel
+// charged when it reports it; the cost is added to the ledger either way.
+func SubmitSwap(ctx context.Context, t *repository.SwapTask) error {
+	storage := GetStorageService()
+
+	// Build face swap pairs with direct URLs for VModel access
+	faceSwaps := make([]VModelFaceSwapPair, len(t.FaceIDs))
+	for i, id := range t.FaceIDs {
+		faceID, err := strconv.Atoi(id)
+		if err != nil {
+			return fmt.Errorf("invalid face ID %q", id)
+		}
+		directURL, err := storage.GetExternalURL(ctx, t.SourceFaceKeys[i])
+		if err != nil {
+			return fmt.Errorf("resolve face image URL: %w", err)
+		}
+		faceSwaps[i] = VModelFaceSwapPair{FaceID: faceID, Target: directURL}
+	}
+
+	result, err := GetVModelClient().CreateSwapTask(ctx, t.DetectID.String, faceSwaps, t.FaceEnhance)
+	if err != nil {
+		return err
+	}
+
+	t.TaskID = result.TaskID
+	t.Status = result.Status
+	estimated := result.TaskCost <= 0
+	if !estimated {
+		t.CreditsUsed = float64(result.TaskCost)
+	}
+	RecordSwapCredits(ctx, t.UserID, t.TaskID, t.CreditsUsed, estimated)
+	return nil
+}
+
+// RequestSwapApproval saves a task awaiting approval instead of submitting it
+// and lets the admins know. The task gets a local ID until it is submitted.
+func RequestSwapApproval(ctx context.Context, t *repository.SwapTask, reason string) error {
+	b := make([]byte, 8)
+	rand.Read(b)
+	t.TaskID = fmt.Sprintf("apr_%x", b)
+	t.ApprovalID = sql.NullString{String: t.TaskID, Valid: true}
+	t.Status = SwapStatusPendingApproval
+
+	if err := repository.SaveSwapTask(ctx, t); err != nil {
+		return fmt.Errorf("save swap task: %w", err)
+	}
+	log.Printf("[INFO] Swap task %s of user %d awaits approval: %s", t.TaskID, t.UserID, reason)
+
+	go notifyApprovalRequested(t, reason)
+	return nil
+}
+
+// ApproveSwapTask records the approval and submits the task to VModel. A task
+// the user's credit quota no longer covers stays pending with
+// ErrCreditQuotaExceeded. If the submission fails the task is marked failed and
+// the error returned.
+func ApproveSwapTask(ctx context.Context, taskID string, reviewerID int64, reason string) (*repository.SwapTask, error) {
+	t, err := loadApproval(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	// The user may have spent credits while the task waited
+	if t.Status == SwapStatusPendingApproval {
+		if err := CheckCreditQuota(ctx, t.UserID, t.CreditsUsed); err != nil {
+			return nil, err
+		}
+	}
+	if err := reviewSwapTask(ctx, t, SwapStatusApproved, reviewerID, reason); err != nil {
+		return nil, err
+	}
+
+	approvalID := t.TaskID
+	if err := SubmitSwap(ctx, t); err != nil {
+		msg := "Submission after approval failed: " + err.Error()
+		log.Printf("[ERROR] Failed to submit approved swap task %s: %v", approvalID, err)
+		if err := repository.UpdateSwapTaskStatus(ctx, approvalID, "failed", nil, &msg); err != nil {
+			log.Printf("[ERROR] Failed to mark swap task %s failed: %v", approvalID, err)
+		}
+		t.TaskID, t.Status = approvalID, "failed"
+		t.ErrorMessage = sql.NullString{String: msg, Valid: true}
+		go notifyApprovalDecided(t)
+		return t, err
+	}
+
+	if err := repository.SetSwapTaskSubmitted(ctx, approvalID, t.TaskID, t.Status, t.CreditsUsed); err != nil {
+		log.Printf("[ERROR] Failed to record submission of swap task %s as %s: %v", approvalID, t.TaskID, err)
+	}
+	log.Printf("[INFO] Swap task %s approved by user %d, submitted as %s", approvalID, reviewerID, t.TaskID)
+
+	go notifyApprovalDecided(t)
+	return t, nil
+}
+
+// RejectSwapTask records the rejection; the task is never submitted
+func RejectSwapTask(ctx context.Context, taskID string, reviewerID int64, reason string) (*repository.SwapTask, error) {
+	t, err := loadApproval(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := reviewSwapTask(ctx, t, SwapStatusRejected, reviewerID, reason); err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] Swap task %s rejected by user %d: %s", taskID, reviewerID, reason)
+
+	go notifyApprovalDecided(t)
+	return t, nil
+}
+
+// loadApproval fetches a task that went through the approval workflow
+func loadApproval(ctx context.Context, taskID string) (*repository.SwapTask, error) {
+	t, err := repository.GetSwapTask(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("load swap task: %w", err)
+	}
+	if t == nil || !t.ApprovalID.Valid {
+		return nil, ErrApprovalNotFound
+	}
+	return t, nil
+}
+
+func reviewSwapTask(ctx context.Context, t *repository.SwapTask, status string, reviewerID int64, reason string) error {
+	ok, err := repository.ReviewSwapTask(ctx, t.TaskID, status, reviewerID, reason)
+	if err != nil {
+		return fmt.Errorf("review swap task: %w", err)
+	}
+	if !ok {
+		return ErrApprovalNotPending
+	}
+	t.Status = status
+	t.ApprovalReason = sql.NullString{String: reason, Valid: reason != ""}
+	t.ReviewedBy = sql.NullInt64{Int64: reviewerID, Valid: true}
+	t.ReviewedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	return nil
+}
+
+// SwapSubmitted is false for tasks still awaiting approval, rejected, or that
+// failed before reaching the provider
+func SwapSubmitted(t *repository.SwapTask) bool {
+	return !t.ApprovalID.Valid || t.TaskID != t.ApprovalID.String
+}
+
+func notifyApprovalRequested(t *repository.SwapTask, reason string) {
+	users, err := repository.ListUsers(context.Background(), repository.RoleAdmin)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load admins to notify about swap task %s: %v", t.TaskID, err)
+		return
+	}
+	if len(users) == 0 {
+		log.Printf("[WARN] No admins to notify about swap task %s awaiting approval", t.TaskID)
+		return
+	}
+	admins := make([]string, 0, len(users))
+	for _, u := range users {
+		admins = append(admins, u.Email)
+	}
+
+	requester := fmt.Sprintf("User %d", t.UserID)
+	if user, err := repository.GetUserByID(context.Background(), t.UserID); err == nil && user != nil {
+		requester = user.Email
+	}
+	sendNotification(admins, "PlayerPlus 换脸任务待审批",
+		fmt.Sprintf("%s 提交的换脸任务 %s 需要审批。", requester, t.TaskID),
+		reason,
+		"请在管理后台批准或拒绝该任务。")
+}
+
+func notifyApprovalDecided(t *repository.SwapTask) {
+	user, err := repository.GetUserByID(context.Background(), t.UserID)
+	if err != nil || user == nil {
+		log.Printf("[WARN] Can't notify user %d about swap task %s: %v", t.UserID, t.ApprovalID.String, err)
+		return
+	}
+
+	var subject, summary string
+	switch t.Status {
+	case SwapStatusRejected:
+		subject = "PlayerPlus 换脸任务未通过审批"
+		summary = fmt.Sprintf("您的换脸任务 %s 未通过审批。", t.ApprovalID.String)
+	case "failed":
+		subject = "PlayerPlus 换脸任务提交失败"
+		summary = fmt.Sprintf("您的换脸任务 %s 已通过审批，但提交失败，请重新创建。", t.ApprovalID.String)
+	default:
+		subject = "PlayerPlus 换脸任务已通过审批"
+		summary = fmt.Sprintf("您的换脸任务 %s 已通过审批并开始处理，新的任务 ID 为 %s。", t.ApprovalID.String, t.TaskID)
+	}
+
+	paragraphs := []string{summary}
+	if t.ApprovalReason.Valid {
+		paragraphs = append(paragraphs, "审批意见："+t.ApprovalReason.String)
+	}
+	sendNotification([]string{user.Email}, subject, paragraphs...)
+}
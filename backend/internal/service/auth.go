@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -32,130 +33,244 @@ func init() {
 			fmt.Printf("[INFO] Aliyun DirectMail client initialized\n")
 		}
 	}
+	if cfg.DevLogin {
+		fmt.Printf("[WARN] DEV_LOGIN is enabled: anyone can sign in with test/test\n")
+	}
 }
 
 type codeEntry struct {
 	Code      string
 	ExpiresAt time.Time
+	SentAt    time.Time
+	Attempts  int
+}
+
+// sessionTTL is how long a login lasts
+const sessionTTL = 7 * 24 * time.Hour
+
+var (
+	// ErrInvalidCode is returned for wrong, expired or already used codes
+	ErrInvalidCode = errors.New("invalid or expired code")
+	// ErrCodeLocked is returned for the wrong guess that locks a code
+	ErrCodeLocked = errors.New("too many wrong codes, request a new one")
+	// ErrCodeNotSent is returned when the mail with a code could not be sent
+	ErrCodeNotSent = errors.New("failed to send verification code")
+)
+
+// CooldownError is returned when a code was sent to the email too recently
+type CooldownError struct {
+	RetryAfter time.Duration
 }
 
-// SendVerificationCode generates and sends a verification code
+func (e *CooldownError) Error() string {
+	return fmt.Sprintf("a code was sent recently, retry in %v", e.RetryAfter.Round(time.Second))
+}
+
+// SendVerificationCode generates and sends a login code
 func SendVerificationCode(email string) error {
+	return sendCode(email, repository.CodeLogin, "PlayerPlus 登录验证码", "您的登录验证码是：")
+}
+
+// sendCode generates a code for the purpose, stores it and mails it. Returns a
+// *CooldownError if the email was sent one within CODE_RESEND_COOLDOWN, and
+// ErrCodeNotSent if mailing failed. Codes are only printed to the log without
+// DirectMail or with DEV_LOGIN.
+func sendCode(email, purpose, subject, intro string) error {
+	ctx := context.Background()
+	email = normalizeEmail(email)
+	if wait, err := codeCooldown(ctx, email, purpose); err != nil {
+		return err
+	} else if wait > 0 {
+		return &CooldownError{RetryAfter: wait}
+	}
+
 	code := generateCode()
 	expiresAt := time.Now().Add(10 * time.Minute)
 
 	// Save to database if available
-	ctx := context.Background()
 	if repository.IsDBAvailable() {
-		if err := repository.SaveVerificationCode(ctx, email, code, expiresAt); err != nil {
+		if err := repository.SaveVerificationCode(ctx, email, purpose, code, expiresAt); err != nil {
 			fmt.Printf("[ERROR] Failed to save code to DB: %v\n", err)
 		}
 	} else {
 		// Fallback to in-memory
 		codeMu.Lock()
-		codeStore[email] = codeEntry{
+		codeStore[purpose+":"+email] = codeEntry{
 			Code:      code,
 			ExpiresAt: expiresAt,
+			SentAt:    time.Now(),
 		}
 		codeMu.Unlock()
 	}
 
 	// Send email via Aliyun DirectMail
 	if dmClient != nil {
-		cfg := config.Get()
-		request := dm.CreateSingleSendMailRequest()
-		request.Scheme = "https"
-		request.AccountName = cfg.AliyunEmailFrom
-		request.FromAlias = "PlayerPlus"
-		request.AddressType = "1"
-		request.ReplyToAddress = "false"
-		request.ToAddress = email
-		request.Subject = "PlayerPlus 登录验证码"
-		request.HtmlBody = fmt.Sprintf(`
+		body := fmt.Sprintf(`
 			<div style="font-family: sans-serif; max-width: 600px; margin: 0 auto;">
 				<h2 style="color: #1890ff;">PlayerPlus Platform</h2>
 				<p>您好，</p>
-				<p>您的登录验证码是：</p>
+				<p>%s</p>
 				<div style="background: #f5f5f5; padding: 20px; text-align: center; margin: 20px 0;">
 					<span style="font-size: 32px; font-weight: bold; letter-spacing: 8px; color: #333;">%s</span>
 				</div>
 				<p>验证码有效期为 10 分钟，请勿泄露给他人。</p>
 				<p style="color: #999; font-size: 12px;">如果您没有请求此验证码，请忽略此邮件。</p>
 			</div>
-		`, code)
+		`, intro, code)
 
-		_, err := dmClient.SingleSendMail(request)
-		if err != nil {
+		if err := sendMail(email, subject, body); err != nil {
 			fmt.Printf("[ERROR] Failed to send email via Aliyun: %v\n", err)
-			fmt.Printf("[DEV] Verification code for %s: %s\n", email, code)
+			if !config.Get().DevLogin {
+				return fmt.Errorf("%w: %v", ErrCodeNotSent, err)
+			}
+			fmt.Printf("[DEV] Verification code (%s) for %s: %s\n", purpose, email, code)
 			return nil
 		}
-		fmt.Printf("[INFO] Verification code sent to %s via Aliyun DirectMail\n", email)
+		fmt.Printf("[INFO] Verification code (%s) sent to %s via Aliyun DirectMail\n", purpose, email)
 	} else {
-		fmt.Printf("[DEV] Verification code for %s: %s (Aliyun DM not configured)\n", email, code)
+		fmt.Printf("[DEV] Verification code (%s) for %s: %s (Aliyun DM not configured)\n", purpose, email, code)
 	}
 
 	return nil
 }
 
-// VerifyCode checks the code and returns a session token
-func VerifyCode(email, code string) (string, error) {
-	ctx := context.Background()
+// normalizeEmail is the form codes are stored and looked up under, so a
+// change of case or whitespace neither bypasses the cooldown nor loses the code
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
 
-	// Try database first
+// codeCooldown returns how long until the email can be sent another code
+func codeCooldown(ctx context.Context, email, purpose string) (time.Duration, error) {
+	var sentAt time.Time
 	if repository.IsDBAvailable() {
-		valid, err := repository.VerifyCodeDB(ctx, email, code)
+		last, err := repository.LastCodeSentAt(ctx, email, purpose)
 		if err != nil {
-			return "", err
-		}
-		if !valid {
-			return "", errors.New("invalid or expired code")
+			return 0, err
 		}
+		sentAt = last.Time
+	} else {
+		codeMu.RLock()
+		sentAt = codeStore[purpose+":"+email].SentAt
+		codeMu.RUnlock()
+	}
 
-		// Create or get user
-		userID, err := repository.CreateOrGetUser(ctx, email)
-		if err != nil {
-			return "", err
-		}
+	if wait := time.Until(sentAt.Add(config.Get().CodeResendCooldown)); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
 
-		// Update last login
-		repository.UpdateUserLastLogin(ctx, userID)
+// checkCode consumes a code if it is valid for the email and purpose. Wrong
+// guesses count against the code, which is locked after CODE_MAX_ATTEMPTS.
+func checkCode(ctx context.Context, email, purpose, code, ip string) error {
+	email = normalizeEmail(email)
+	maxAttempts := config.Get().CodeMaxAttempts
 
-		// Generate and save session token
-		token := generateToken()
-		expiresAt := time.Now().Add(7 * 24 * time.Hour) // 7 days
-		if err := repository.CreateSession(ctx, userID, token, expiresAt); err != nil {
-			return "", err
+	// Try database first
+	if repository.IsDBAvailable() {
+		valid, locked, err := repository.VerifyCodeDB(ctx, email, purpose, code, maxAttempts)
+		if err != nil {
+			return err
 		}
-
-		return token, nil
+		if locked {
+			codeLocked(email, purpose, ip, maxAttempts)
+			return ErrCodeLocked
+		}
+		if !valid {
+			return ErrInvalidCode
+		}
+		return nil
 	}
 
 	// Fallback to in-memory
-	codeMu.RLock()
-	entry, exists := codeStore[email]
-	codeMu.RUnlock()
+	key := purpose + ":" + email
+	codeMu.Lock()
+	defer codeMu.Unlock()
+	entry, exists := codeStore[key]
+	if !exists || entry.Code == "" || time.Now().After(entry.ExpiresAt) {
+		return ErrInvalidCode
+	}
+	if entry.Code != code {
+		entry.Attempts++
+		if entry.Attempts >= maxAttempts {
+			// Keep SentAt for the resend cooldown
+			codeStore[key] = codeEntry{SentAt: entry.SentAt}
+			codeLocked(email, purpose, ip, maxAttempts)
+			return ErrCodeLocked
+		}
+		codeStore[key] = entry
+		return ErrInvalidCode
+	}
+	delete(codeStore, key)
+	return nil
+}
 
-	if !exists {
-		return "", errors.New("no code found")
+// codeLocked audits a code locked after too many wrong guesses
+func codeLocked(email, purpose, ip string, attempts int) {
+	fmt.Printf("[WARN] Locked %s code of %s after %d wrong attempts (last from %s)\n", purpose, email, attempts, ip)
+	recordAuthEvent(repository.AuthEventCodeLocked, email, ip,
+		fmt.Sprintf("%s code locked after %d wrong attempts", purpose, attempts))
+}
+
+// recordAuthEvent saves an audit entry. Failures are logged only.
+func recordAuthEvent(event, email, ip, detail string) {
+	e := &repository.AuthEvent{
+		Event:  event,
+		Email:  nullString(email),
+		IP:     nullString(ip),
+		Detail: nullString(detail),
+	}
+	if err := repository.SaveAuthEvent(context.Background(), e); err != nil {
+		fmt.Printf("[ERROR] Failed to record auth event %s: %v\n", event, err)
 	}
+}
 
-	if time.Now().After(entry.ExpiresAt) {
-		return "", errors.New("code expired")
+// VerifyCode checks the login code and returns a session token. ip is
+// recorded if the attempt locks the code.
+func VerifyCode(email, code, ip string) (string, error) {
+	ctx := context.Background()
+	if err := checkCode(ctx, email, repository.CodeLogin, code, ip); err != nil {
+		return "", err
+	}
+	if !repository.IsDBAvailable() {
+		return generateToken(), nil
 	}
 
-	if entry.Code != code {
-		return "", errors.New("invalid code")
+	// Create the user on first sign-in, if the domain or an invitation allows it
+	userID, err := signUpUser(ctx, email)
+	if err != nil {
+		return "", err
 	}
+	return startSession(ctx, userID)
+}
 
-	codeMu.Lock()
-	delete(codeStore, email)
-	codeMu.Unlock()
+// startSession records the login and returns a new session token
+func startSession(ctx context.Context, userID int64) (string, error) {
+	repository.UpdateUserLastLogin(ctx, userID)
 
 	token := generateToken()
+	if err := repository.CreateSession(ctx, userID, token, time.Now().Add(sessionTTL)); err != nil {
+		return "", err
+	}
 	return token, nil
 }
 
+// BootstrapAdmins gives the admin role to the existing users listed in
+// ADMIN_EMAILS while there is no admin yet. Listed users who haven't signed up
+// get it when their account is created. Later role changes are left alone.
+func BootstrapAdmins() {
+	emails := config.Get().AdminEmails
+	n, err := repository.PromoteAdmins(context.Background(), emails)
+	if err != nil {
+		fmt.Printf("[ERROR] Failed to bootstrap admins: %v\n", err)
+		return
+	}
+	if n > 0 {
+		fmt.Printf("[INFO] Promoted %d users from ADMIN_EMAILS to admin\n", n)
+	}
+}
+
 func generateCode() string {
 	b := make([]byte, 3)
 	rand.Read(b)
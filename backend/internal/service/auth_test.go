@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+func TestCheckCodeLocksAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	email := "lock@playerplus.cn"
+	key := repository.CodeLogin + ":" + email
+	codeMu.Lock()
+	codeStore[key] = codeEntry{Code: "123456", ExpiresAt: time.Now().Add(time.Minute), SentAt: time.Now()}
+	codeMu.Unlock()
+
+	maxAttempts := config.Get().CodeMaxAttempts
+	for i := 1; i < maxAttempts; i++ {
+		if err := checkCode(ctx, email, repository.CodeLogin, "000000", "192.0.2.1"); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidCode", i, err)
+		}
+	}
+	if err := checkCode(ctx, email, repository.CodeLogin, "000000", "192.0.2.1"); !errors.Is(err, ErrCodeLocked) {
+		t.Fatalf("attempt %d: got %v, want ErrCodeLocked", maxAttempts, err)
+	}
+	if err := checkCode(ctx, email, repository.CodeLogin, "123456", "192.0.2.1"); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("correct code after lock: got %v, want ErrInvalidCode", err)
+	}
+
+	// The lock doesn't reset the resend cooldown
+	if wait, _ := codeCooldown(ctx, email, repository.CodeLogin); wait <= 0 {
+		t.Error("expected a resend cooldown after the lock")
+	}
+}
+
+func TestCheckCodeConsumesValidCode(t *testing.T) {
+	ctx := context.Background()
+	email := "ok@playerplus.cn"
+	codeMu.Lock()
+	codeStore[repository.CodeLogin+":"+email] = codeEntry{Code: "654321", ExpiresAt: time.Now().Add(time.Minute), SentAt: time.Now()}
+	codeMu.Unlock()
+
+	if err := checkCode(ctx, email, repository.CodeLogin, "654321", ""); err != nil {
+		t.Fatalf("valid code: %v", err)
+	}
+	if err := checkCode(ctx, email, repository.CodeLogin, "654321", ""); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("reused code: got %v, want ErrInvalidCode", err)
+	}
+}
+
+func TestCodeEmailIsNormalized(t *testing.T) {
+	ctx := context.Background()
+	if err := sendCode("Case@PlayerPlus.cn", repository.CodeLogin, "subject", "intro"); err != nil {
+		t.Fatalf("sendCode() error: %v", err)
+	}
+
+	// Another spelling of the same address is still in the cooldown
+	var cooldown *CooldownError
+	if err := sendCode(" case@playerplus.CN ", repository.CodeLogin, "subject", "intro"); !errors.As(err, &cooldown) {
+		t.Errorf("sendCode() with other case: error = %v, want a *CooldownError", err)
+	}
+
+	codeMu.RLock()
+	code := codeStore[repository.CodeLogin+":case@playerplus.cn"].Code
+	codeMu.RUnlock()
+	if err := checkCode(ctx, "CASE@playerplus.cn", repository.CodeLogin, code, ""); err != nil {
+		t.Errorf("checkCode() with other case: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"playplus_platform/internal/repository"
+)
+
+var (
+	// ErrDetectionNotFound is returned for detections that don't exist or belong to someone else
+	ErrDetectionNotFound = errors.New("face detection not found")
+	// ErrUnknownFace is returned when a face_id is not part of the detection
+	ErrUnknownFace = errors.New("face_id not found in detection")
+)
+
+// RecordDetection saves a detection task submitted for a user.
+// DB failures are logged, not returned: the provider task already exists.
+func RecordDetection(ctx context.Context, userID int64, taskID, status, key string) {
+	d := &repository.FaceDetection{
+		TaskID:   taskID,
+		UserID:   userID,
+		MediaKey: nullString(key),
+		Status:   status,
+	}
+	if key != "" {
+		if media, err := repository.GetMediaFileByKey(ctx, key); err == nil && media != nil {
+			d.MediaID = nullString(media.MediaID)
+		}
+	}
+	if err := repository.CreateFaceDetection(ctx, d); err != nil {
+		log.Printf("[ERROR] Failed to save face detection %s: %v", taskID, err)
+	}
+}
+
+// UpdateDetection stores a polled provider status on the detection record
+func UpdateDetection(ctx context.Context, d *repository.FaceDetection, result *VModelDetectStatusResult) {
+	if d.Status == result.Status {
+		return
+	}
+
+	d.Status = result.Status
+	switch result.Status {
+	case "completed":
+		d.DetectID = nullString(result.DetectID)
+		d.Faces = make([]repository.DetectedFace, len(result.Faces))
+		for i, f := range result.Faces {
+			d.Faces[i] = repository.DetectedFace{FaceID: f.ID, Thumbnail: f.Link}
+		}
+		d.CompletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	case "failed":
+		d.ErrorMessage = nullString(result.Error)
+		d.CompletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+
+	if err := repository.UpdateFaceDetection(ctx, d); err != nil {
+		log.Printf("[ERROR] Failed to update face detection %s: %v", d.TaskID, err)
+	}
+}
+
+// DetectionFinished is true once a detection has completed or failed
+func DetectionFinished(d *repository.FaceDetection) bool {
+	return d.Status == "completed" || d.Status == "failed"
+}
+
+// ValidateSwapFaces checks that detectID is a completed detection of the user
+// and that it contains every face ID, so invalid requests fail before a paid
+// swap is submitted. Without the DB there are no records to check against.
+func ValidateSwapFaces(ctx context.Context, userID int64, detectID string, faceIDs []int) error {
+	if !repository.IsDBAvailable() {
+		return nil
+	}
+
+	d, err := repository.GetFaceDetectionByDetectID(ctx, detectID)
+	if err != nil {
+		return fmt.Errorf("load detection: %w", err)
+	}
+	if d == nil || d.UserID != userID || d.Status != "completed" {
+		return ErrDetectionNotFound
+	}
+
+	known := make(map[int]bool, len(d.Faces))
+	for _, f := range d.Faces {
+		known[f.FaceID] = true
+	}
+	for _, id := range faceIDs {
+		if !known[id] {
+			return fmt.Errorf("%w: %d", ErrUnknownFace, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"playplus_platform/internal/repository"
+)
+
+// MaxExportTasks caps how many tasks one zip export may contain
+const MaxExportTasks = 500
+
+// exportManifestHeader is the first row of manifest.csv
+var exportManifestHeader = []string{"task_id", "source_video", "faces_used", "status", "created_at", "file", "error"}
+
+// ExportResult is the outcome of one task in a zip export
+type ExportResult struct {
+	TaskID string
+	File   string // Path inside the archive, empty when skipped
+	Error  string
+}
+
+// WriteResultsZip streams the results of tasks into a zip archive followed by
+// manifest.csv. Objects are copied straight from storage without being
+// buffered; videos are stored uncompressed since they don't compress anyway.
+// Tasks without a stored result, or whose object can't be read, are listed in
+// the manifest with the reason. Only writer errors abort the archive.
+func WriteResultsZip(ctx context.Context, w io.Writer, tasks []repository.SwapTask, requested []string) ([]ExportResult, error) {
+	zw := zip.NewWriter(w)
+	storage := GetStorageService()
+
+	results := make([]ExportResult, 0, len(tasks)+len(requested))
+	manifest := make([][]string, 0, len(tasks)+len(requested))
+	usedNames := make(map[string]bool)
+
+	for i := range tasks {
+		task := &tasks[i]
+		res := ExportResult{TaskID: task.TaskID}
+
+		switch {
+		case ctx.Err() != nil:
+			return results, ctx.Err()
+		case !task.ResultKey.Valid:
+			res.Error = "no stored result (status " + task.Status + ")"
+		default:
+			name := uniqueExportName("results/"+ResultFilename(ctx, task), usedNames)
+			if err := addObjectToZip(ctx, zw, storage, task.ResultKey.String, name, ResultTime(task)); err != nil {
+				if _, ok := err.(zipWriteError); ok {
+					return results, err
+				}
+				log.Printf("[WARN] Export of task %s failed: %v", task.TaskID, err)
+				res.Error = err.Error()
+			} else {
+				res.File = name
+			}
+		}
+
+		results = append(results, res)
+		manifest = append(manifest, exportManifestRow(ctx, task, res))
+	}
+
+	// Requested IDs that don't exist or aren't the user's
+	found := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		found[t.TaskID] = true
+	}
+	for _, id := range requested {
+		if !found[id] {
+			found[id] = true
+			res := ExportResult{TaskID: id, Error: "task not found"}
+			results = append(results, res)
+			manifest = append(manifest, []string{id, "", "", "", "", "", res.Error})
+		}
+	}
+
+	if err := writeExportManifest(zw, manifest); err != nil {
+		return results, err
+	}
+	return results, zw.Close()
+}
+
+// zipWriteError marks failures writing the archive itself, as opposed to
+// failures reading a single object
+type zipWriteError struct{ error }
+
+// addObjectToZip copies one stored object into the archive
+func addObjectToZip(ctx context.Context, zw *zip.Writer, storage *StorageService, key, name string, modified time.Time) error {
+	obj, _, err := storage.Open(ctx, key)
+	if err != nil {
+		return fmt.Errorf("open result: %w", err)
+	}
+	defer obj.Close()
+
+	header := &zip.FileHeader{Name: name, Method: zip.Store, Modified: modified}
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return zipWriteError{err}
+	}
+
+	// A read failure leaves a truncated entry behind: it can't be taken back
+	// once streamed, so it is reported in the manifest instead
+	if _, err := io.Copy(entry, &storageReader{r: obj}); err != nil {
+		if marker, ok := err.(readError); ok {
+			return fmt.Errorf("read result: %w (entry is incomplete)", marker.error)
+		}
+		return zipWriteError{err}
+	}
+	return nil
+}
+
+// storageReader tags errors from the storage side of io.Copy
+type storageReader struct{ r io.Reader }
+
+type readError struct{ error }
+
+func (m *storageReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = readError{err}
+	}
+	return n, err
+}
+
+func writeExportManifest(zw *zip.Writer, rows [][]string) error {
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.csv", Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	// BOM so Excel opens non-ASCII filenames correctly
+	if _, err := entry.Write([]byte("\xEF\xBB\xBF")); err != nil {
+		return err
+	}
+	cw := csv.NewWriter(entry)
+	if err := cw.Write(exportManifestHeader); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func exportManifestRow(ctx context.Context, task *repository.SwapTask, res ExportResult) []string {
+	video := originalFilename(ctx, task)
+	if video == "" {
+		video = task.TargetVideoKey.String
+	}
+
+	faces := make([]string, 0, len(task.SourceFaceKeys))
+	for _, key := range task.SourceFaceKeys {
+		name := key
+		if media, err := repository.GetMediaFileByKey(ctx, key); err == nil && media != nil {
+			name = media.Filename
+		}
+		faces = append(faces, name)
+	}
+
+	return []string{
+		task.TaskID,
+		video,
+		strings.Join(faces, "; "),
+		task.Status,
+		task.CreatedAt.Format(time.RFC3339),
+		res.File,
+		res.Error,
+	}
+}
+
+// uniqueExportName appends a counter when two results get the same name
+func uniqueExportName(name string, used map[string]bool) string {
+	candidate := name
+	ext := path.Ext(name)
+	for i := 2; used[candidate]; i++ {
+		candidate = strings.TrimSuffix(name, ext) + "_" + strconv.Itoa(i) + ext
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// ResultTime is when a task's result was stored
+func ResultTime(task *repository.SwapTask) time.Time {
+	if task.CompletedAt.Valid {
+		return task.CompletedAt.Time
+	}
+	return task.UpdatedAt
+}
@@ -0,0 +1,16 @@
+package service
+
+import "testing"
+
+func TestUniqueExportName(t *testing.T) {
+	used := make(map[string]bool)
+	want := []string{"results/a.mp4", "results/a_2.mp4", "results/a_3.mp4"}
+	for _, w := range want {
+		if got := uniqueExportName("results/a.mp4", used); got != w {
+			t.Errorf("uniqueExportName = %q, want %q", got, w)
+		}
+	}
+	if got := uniqueExportName("results/b", used); got != "results/b" {
+		t.Errorf("uniqueExportName = %q, want results/b", got)
+	}
+}
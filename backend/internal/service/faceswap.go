@@ -42,7 +42,15 @@ func UploadMedia(userID int64, file *multipart.FileHeader) (string, error) {
 		if ct := file.Header.Get("Content-Type"); len(ct) > 5 && ct[:5] == "video" {
 			fileType = "video"
 		}
-		if err := repository.SaveMediaFile(ctx, userID, mediaID, file.Filename, fileType, file.Size); err != nil {
+		media := &repository.MediaFile{
+			UserID:   userID,
+			MediaID:  mediaID,
+			Filename: file.Filename,
+			FileType: fileType,
+			Category: MediaCategoryMedia,
+			FileSize: file.Size,
+		}
+		if err := repository.SaveMediaFile(ctx, media); err != nil {
 			fmt.Printf("[ERROR] Failed to save media to DB: %v\n", err)
 		}
 	}
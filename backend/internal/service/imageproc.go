@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Register WebP decoding for image.Decode
+	"playplus_platform/internal/config"
+)
+
+// ErrImageTooLarge is returned for images over IMAGE_MAX_PIXELS. A small file
+// can declare huge dimensions, and decoding allocates 4 bytes per pixel.
+var ErrImageTooLarge = errors.New("image dimensions too large")
+
+// ProcessedImage is a re-encoded JPEG variant of an uploaded image
+type ProcessedImage struct {
+	Data   []byte
+	Width  int
+	Height int
+}
+
+// NormalizeImage decodes any supported image, applies its EXIF orientation,
+// downscales it so the long edge is at most maxEdge (0 keeps the size) and
+// re-encodes it as JPEG. Re-encoding drops all metadata, including GPS
+// location. Transparent areas are flattened onto white. Images over
+// IMAGE_MAX_PIXELS are refused before decoding.
+func NormalizeImage(data []byte, maxEdge, quality int) (*ProcessedImage, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image header: %w", err)
+	}
+	if maxPixels := config.Get().ImageMaxPixels; maxPixels > 0 && int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d is over %d pixels", ErrImageTooLarge, cfg.Width, cfg.Height, maxPixels)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	w, h := fitWithin(bounds.Dx(), bounds.Dy(), maxEdge)
+
+	// Scale before rotating: the long edge is the same either way and it's cheaper
+	canvas := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	if w == bounds.Dx() && h == bounds.Dy() {
+		draw.Draw(canvas, canvas.Bounds(), src, bounds.Min, draw.Over)
+	} else {
+		draw.CatmullRom.Scale(canvas, canvas.Bounds(), src, bounds, draw.Over, nil)
+	}
+	img := applyOrientation(canvas, jpegOrientation(data))
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("encode jpeg: %w", err)
+	}
+	return &ProcessedImage{Data: buf.Bytes(), Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}, nil
+}
+
+// fitWithin scales w×h down so neither edge exceeds maxEdge, keeping the aspect ratio
+func fitWithin(w, h, maxEdge int) (int, int) {
+	if maxEdge <= 0 || (w <= maxEdge && h <= maxEdge) {
+		return w, h
+	}
+	if w >= h {
+		return maxEdge, imax(1, h*maxEdge/w)
+	}
+	return imax(1, w*maxEdge/h), maxEdge
+}
+
+func imax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// applyOrientation transforms the image so it displays upright for EXIF orientation 1-8
+func applyOrientation(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w // 90° rotations swap the edges
+	}
+
+	// For each destination pixel, where it comes from in the source
+	var from func(x, y int) (int, int)
+	switch orientation {
+	case 2: // Mirrored horizontally
+		from = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // Rotated 180°
+		from = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // Mirrored vertically
+		from = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // Transposed
+		from = func(x, y int) (int, int) { return y, x }
+	case 6: // Needs 90° clockwise rotation
+		from = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7: // Transversed
+		from = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8: // Needs 90° counter-clockwise rotation
+		from = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := from(x, y)
+			dst.SetRGBA(x, y, src.RGBAAt(sx, sy))
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG, or 1 when absent
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	// Walk the marker segments up to the start of scan looking for APP1/Exif
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // Fill byte
+			i++
+			continue
+		}
+		if marker == 0xDA || marker == 0xD9 { // SOS / EOI
+			return 1
+		}
+		segLen := int(binary.BigEndian.Uint16(data[i+2:]))
+		if segLen < 2 || i+2+segLen > len(data) {
+			return 1
+		}
+		if seg := data[i+4 : i+2+segLen]; marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			if o := exifOrientation(seg[6:]); o != 0 {
+				return o
+			}
+		}
+		i += 2 + segLen
+	}
+	return 1
+}
+
+// exifOrientation reads the Orientation tag (0x0112) from IFD0 of a TIFF structure, 0 if missing
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for k := 0; k < entries; k++ {
+		e := ifd + 2 + k*12
+		if e+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[e:]) == 0x0112 {
+			if v := int(order.Uint16(tiff[e+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 0
+		}
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// withOrientation inserts an APP1/Exif segment carrying the orientation tag after SOI
+func withOrientation(t *testing.T, jpg []byte, orientation uint16) []byte {
+	t.Helper()
+	tiff := make([]byte, 26)
+	copy(tiff, "MM\x00\x2a")
+	binary.BigEndian.PutUint32(tiff[4:], 8) // IFD0 offset
+	binary.BigEndian.PutUint16(tiff[8:], 1) // One entry
+	binary.BigEndian.PutUint16(tiff[10:], 0x0112)
+	binary.BigEndian.PutUint16(tiff[12:], 3) // SHORT
+	binary.BigEndian.PutUint32(tiff[14:], 1)
+	binary.BigEndian.PutUint16(tiff[18:], orientation)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return bytes.Join([][]byte{jpg[:2], seg, payload, jpg[2:]}, nil)
+}
+
+// halves returns a w×h image, red on the left half and blue on the right
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestNormalizeImageOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, halves(400, 200), &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	data := withOrientation(t, buf.Bytes(), 6)
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("jpegOrientation() = %d, want 6", got)
+	}
+
+	out, err := NormalizeImage(data, 100, 90)
+	if err != nil {
+		t.Fatalf("NormalizeImage() error: %v", err)
+	}
+	if out.Width != 50 || out.Height != 100 {
+		t.Fatalf("size = %dx%d, want 50x100", out.Width, out.Height)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(out.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Rotated 90° clockwise: the red left half ends up on top
+	if r, _, b, _ := img.At(25, 10).RGBA(); r < b {
+		t.Errorf("top of rotated image is not red")
+	}
+	if r, _, b, _ := img.At(25, 90).RGBA(); b < r {
+		t.Errorf("bottom of rotated image is not blue")
+	}
+	if jpegOrientation(out.Data) != 1 {
+		t.Errorf("normalized image still carries EXIF orientation")
+	}
+}
+
+func TestNormalizeImageFlattensAlpha(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 10, 10))); err != nil {
+		t.Fatal(err)
+	}
+	out, err := NormalizeImage(buf.Bytes(), 0, 90)
+	if err != nil {
+		t.Fatalf("NormalizeImage() error: %v", err)
+	}
+	img, _ := jpeg.Decode(bytes.NewReader(out.Data))
+	if r, g, b, _ := img.At(5, 5).RGBA(); r>>8 < 250 || g>>8 < 250 || b>>8 < 250 {
+		t.Errorf("transparent pixel = (%d,%d,%d), want white", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestNormalizeImageRejectsHugeDimensions(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	// Claim 100000×100000 pixels in the IHDR chunk of a tiny file
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[16:], 100000)
+	binary.BigEndian.PutUint32(data[20:], 100000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	if _, err := NormalizeImage(data, 0, 90); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("NormalizeImage() error = %v, want %v", err, ErrImageTooLarge)
+	}
+}
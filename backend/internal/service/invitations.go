@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+// maxInvitationExpiry caps how long an invitation can stay valid
+const maxInvitationExpiry = 90 * 24 * time.Hour
+
+var (
+	// ErrEmailNotAllowed is returned for sign-ups outside the allowed domains without an invitation
+	ErrEmailNotAllowed = errors.New("email is not in an allowed domain and has no invitation")
+	// ErrInvalidExpiry is returned for invitation lifetimes that are negative or too long
+	ErrInvalidExpiry = fmt.Errorf("invitation expiry must be positive and at most %d days", int(maxInvitationExpiry.Hours()/24))
+)
+
+// CanRequestCode reports whether an email may sign in with a code: its domain
+// is allowed, it already has an account, or it has a pending invitation
+func CanRequestCode(ctx context.Context, email string) (bool, error) {
+	if config.Get().IsAllowedEmailDomain(email) {
+		return true, nil
+	}
+	if !repository.IsDBAvailable() {
+		return false, nil
+	}
+
+	user, err := repository.GetUserByEmail(ctx, email)
+	if err != nil || user != nil {
+		return user != nil, err
+	}
+	inv, err := repository.GetPendingInvitation(ctx, email)
+	return inv != nil, err
+}
+
+// signUpUser returns the account for an email, creating it on first sign-in.
+// New users outside the allowed domains need a pending invitation, whose role
+// they get.
+func signUpUser(ctx context.Context, email string) (int64, error) {
+	user, err := repository.GetUserByEmail(ctx, email)
+	if err != nil {
+		return 0, err
+	}
+	if user != nil {
+		return user.ID, nil
+	}
+
+	inv, err := repository.GetPendingInvitation(ctx, email)
+	if err != nil {
+		return 0, err
+	}
+	if inv == nil && !config.Get().IsAllowedEmailDomain(email) {
+		return 0, ErrEmailNotAllowed
+	}
+
+	userID, err := repository.CreateOrGetUser(ctx, strings.ToLower(email))
+	if err != nil {
+		return 0, err
+	}
+	if inv != nil {
+		if _, err := repository.SetUserRole(ctx, userID, inv.Role); err != nil {
+			return 0, err
+		}
+		if err := repository.AcceptInvitation(ctx, inv.ID, userID); err != nil {
+			log.Printf("[ERROR] Failed to mark invitation %d accepted: %v", inv.ID, err)
+		}
+		log.Printf("[INFO] User %d (%s) signed up with invitation %d as %s", userID, email, inv.ID, inv.Role)
+	}
+
+	// Bootstrap admins get the role with their new account only, so a later
+	// demotion isn't undone by their next login
+	if config.Get().IsAdminEmail(email) {
+		if _, err := repository.SetUserRole(ctx, userID, repository.RoleAdmin); err != nil {
+			log.Printf("[ERROR] Failed to promote %s to admin: %v", email, err)
+		} else {
+			log.Printf("[INFO] User %d (%s) from ADMIN_EMAILS signed up as admin", userID, email)
+		}
+	}
+	return userID, nil
+}
+
+// InviteUser invites an email to sign up with a role. An open invitation for
+// the same email is replaced. expiresIn 0 uses INVITATION_EXPIRY.
+func InviteUser(ctx context.Context, email, role string, expiresIn time.Duration, invitedBy int64) (*repository.Invitation, error) {
+	if !repository.IsDBAvailable() {
+		return nil, ErrAccountsUnavailable
+	}
+	if expiresIn == 0 {
+		expiresIn = config.Get().InvitationExpiry
+	}
+	if expiresIn <= 0 || expiresIn > maxInvitationExpiry {
+		return nil, ErrInvalidExpiry
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	user, err := repository.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		return nil, ErrUserExists
+	}
+
+	inv := &repository.Invitation{
+		Email:     email,
+		Role:      role,
+		InvitedBy: sql.NullInt64{Int64: invitedBy, Valid: invitedBy > 0},
+		ExpiresAt: time.Now().Add(expiresIn),
+	}
+	if err := repository.CreateInvitation(ctx, inv); err != nil {
+		return nil, err
+	}
+	log.Printf("[INFO] User %d invited %s as %s until %s", invitedBy, email, role, inv.ExpiresAt.Format(time.RFC3339))
+
+	go notifyInvited(inv)
+	return inv, nil
+}
+
+func notifyInvited(inv *repository.Invitation) {
+	signIn := "请使用该邮箱通过验证码登录平台。"
+	if base := config.Get().PublicBaseURL; base != "" {
+		signIn = fmt.Sprintf("请访问 %s/login 使用该邮箱通过验证码登录。", base)
+	}
+	sendNotification([]string{inv.Email}, "邀请您加入 PlayerPlus Platform",
+		fmt.Sprintf("您已被邀请加入 PlayerPlus Platform，角色为 %s。", inv.Role),
+		signIn,
+		fmt.Sprintf("邀请有效期至 %s。", inv.ExpiresAt.Format("2006-01-02 15:04")))
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+
+	"github.com/aliyun/alibaba-cloud-sdk-go/services/dm"
+	"playplus_platform/internal/config"
+)
+
+// errMailNotConfigured is returned by sendMail when Aliyun DirectMail isn't set up
+var errMailNotConfigured = errors.New("mail not configured")
+
+// sendMail sends an HTML email via Aliyun DirectMail
+func sendMail(to, subject, htmlBody string) error {
+	if dmClient == nil {
+		return errMailNotConfigured
+	}
+
+	cfg := config.Get()
+	request := dm.CreateSingleSendMailRequest()
+	request.Scheme = "https"
+	request.AccountName = cfg.AliyunEmailFrom
+	request.FromAlias = "PlayerPlus"
+	request.AddressType = "1"
+	request.ReplyToAddress = "false"
+	request.ToAddress = to
+	request.Subject = subject
+	request.HtmlBody = htmlBody
+
+	_, err := dmClient.SingleSendMail(request)
+	return err
+}
+
+// sendNotification emails a short notice with the platform layout. Failures
+// are logged only: notifications never block the action that triggered them.
+func sendNotification(to []string, subject string, paragraphs ...string) {
+	var body strings.Builder
+	body.WriteString(`<div style="font-family: sans-serif; max-width: 600px; margin: 0 auto;">
+				<h2 style="color: #1890ff;">PlayerPlus Platform</h2>`)
+	for _, p := range paragraphs {
+		fmt.Fprintf(&body, "<p>%s</p>", html.EscapeString(p))
+	}
+	body.WriteString("</div>")
+
+	for _, addr := range to {
+		if err := sendMail(addr, subject, body.String()); err != nil {
+			log.Printf("[WARN] Failed to send \"%s\" to %s: %v", subject, addr, err)
+			continue
+		}
+		log.Printf("[INFO] Sent \"%s\" to %s", subject, addr)
+	}
+}
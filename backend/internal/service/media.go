@@ -0,0 +1,314 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"mime/multipart"
+	"path"
+	"strings"
+
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+// Media library categories
+const (
+	MediaCategoryMedia = "media" // Videos/images uploaded for processing
+	MediaCategoryFace  = "face"  // Replacement face photos
+	MediaCategoryFrame = "frame" // Video frames for face detection
+)
+
+// ErrFileTooLarge is returned when an upload exceeds its category's size limit
+var ErrFileTooLarge = errors.New("file too large")
+
+// MediaInfo describes a stored object recorded in the media library
+type MediaInfo struct {
+	MediaID     string
+	UserID      int64
+	Key         string
+	Filename    string
+	Category    string
+	FileType    string // video, image
+	ContentType string
+	Size        int64
+	Hash        string // SHA-256 hex
+	Width       int
+	Height      int
+	Duration    float64 // seconds, videos only
+	FrameRate   float64 // videos only
+	Codec       string  // videos only
+	Rotation    int     // videos only, clockwise degrees
+
+	// Face images only: upright, downscaled JPEG without metadata, used for swaps
+	NormalizedKey  string
+	NormalizedSize int64
+
+	ThumbnailKey  string // Images only
+	ThumbnailSize int64
+}
+
+// MediaKeyPrefix returns the storage prefix for a new object
+func MediaKeyPrefix(category, contentType string) string {
+	switch category {
+	case MediaCategoryFace:
+		return "faces"
+	case MediaCategoryFrame:
+		return "frames"
+	}
+	if strings.HasPrefix(contentType, "image/") {
+		return "images"
+	}
+	return "videos"
+}
+
+// MaxUploadSize returns the configured size limit for uploads in a category
+func MaxUploadSize(category string) int64 {
+	cfg := config.Get()
+	switch category {
+	case MediaCategoryFace:
+		return cfg.UploadMaxFaceSize
+	case MediaCategoryFrame:
+		return cfg.UploadMaxFrameSize
+	}
+	return cfg.UploadMaxMediaSize
+}
+
+// StoreUpload uploads a multipart file and records it in the media library
+func StoreUpload(ctx context.Context, userID int64, category string, file *multipart.FileHeader) (*MediaInfo, error) {
+	if file.Size > MaxUploadSize(category) {
+		return nil, ErrFileTooLarge
+	}
+	if err := CheckStorageQuota(ctx, userID, file.Size); err != nil {
+		return nil, err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("open file: %w", err)
+	}
+	defer src.Close()
+
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	// Store the sniffed type, never the one the client claimed
+	contentType, err := CheckMediaType(content, file.Header.Get("Content-Type"), category)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := GetStorageService()
+	key := storage.GenerateKey(MediaKeyPrefix(category, contentType), file.Filename)
+	if _, err := storage.UploadBytes(ctx, key, content, contentType); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+	info := &MediaInfo{
+		UserID:      userID,
+		Key:         key,
+		Filename:    file.Filename,
+		Category:    category,
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		Hash:        hex.EncodeToString(sum[:]),
+	}
+	probeMedia(info, bytes.NewReader(content), info.Size)
+	if category == MediaCategoryFace {
+		storeNormalizedFace(ctx, info, content)
+	}
+	if strings.HasPrefix(contentType, "image/") {
+		storeThumbnail(ctx, info, content)
+	}
+
+	RecordMedia(ctx, info)
+	return info, nil
+}
+
+// storeNormalizedFace stores the normalized variant of a face image next to the
+// original. On failure the original is kept as the swap input.
+func storeNormalizedFace(ctx context.Context, info *MediaInfo, content []byte) {
+	cfg := config.Get()
+	normalized, err := NormalizeImage(content, cfg.FaceMaxEdge, cfg.FaceJPEGQuality)
+	if err != nil {
+		log.Printf("[WARN] Failed to normalize face image %s: %v", info.Key, err)
+		return
+	}
+
+	key := strings.TrimSuffix(info.Key, path.Ext(info.Key)) + "_normalized.jpg"
+	if _, err := GetStorageService().UploadBytes(ctx, key, normalized.Data, "image/jpeg"); err != nil {
+		log.Printf("[WARN] Failed to store normalized face image %s: %v", key, err)
+		return
+	}
+	info.NormalizedKey = key
+	info.NormalizedSize = int64(len(normalized.Data))
+}
+
+// SwapFaceKey maps a face image key to the variant that should be sent for swapping
+func SwapFaceKey(ctx context.Context, key string) string {
+	media, err := repository.GetMediaFileByKey(ctx, key)
+	if err != nil || media == nil || !media.NormalizedKey.Valid {
+		return key
+	}
+	return media.NormalizedKey.String
+}
+
+// RecordMedia assigns a media ID and saves the library record.
+// DB failures are logged, not returned: the object is already stored.
+func RecordMedia(ctx context.Context, info *MediaInfo) {
+	if info.MediaID == "" {
+		info.MediaID = generateMediaID()
+	}
+	if info.FileType == "" {
+		info.FileType = mediaFileType(info.ContentType)
+	}
+
+	err := repository.SaveMediaFile(ctx, &repository.MediaFile{
+		UserID:         info.UserID,
+		MediaID:        info.MediaID,
+		Filename:       info.Filename,
+		FileType:       info.FileType,
+		Category:       info.Category,
+		ContentType:    nullString(info.ContentType),
+		FileSize:       info.Size,
+		FileHash:       nullString(info.Hash),
+		Width:          sql.NullInt32{Int32: int32(info.Width), Valid: info.Width > 0},
+		Height:         sql.NullInt32{Int32: int32(info.Height), Valid: info.Height > 0},
+		Duration:       sql.NullFloat64{Float64: info.Duration, Valid: info.Duration > 0},
+		FrameRate:      sql.NullFloat64{Float64: info.FrameRate, Valid: info.FrameRate > 0},
+		VideoCodec:     nullString(info.Codec),
+		Rotation:       sql.NullInt32{Int32: int32(info.Rotation), Valid: info.Codec != ""},
+		StorageKey:     nullString(info.Key),
+		NormalizedKey:  nullString(info.NormalizedKey),
+		ThumbnailKey:   nullString(info.ThumbnailKey),
+		NormalizedSize: sql.NullInt64{Int64: info.NormalizedSize, Valid: info.NormalizedKey != ""},
+		ThumbnailSize:  sql.NullInt64{Int64: info.ThumbnailSize, Valid: info.ThumbnailKey != ""},
+		StorageBackend: nullString(GetStorageService().BackendID()),
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to save media %s to DB: %v", info.Key, err)
+	}
+}
+
+// DeleteMedia removes a media record, its dependent records and every object they referenced
+func DeleteMedia(ctx context.Context, mediaID string) error {
+	keys, err := repository.DeleteMediaFile(ctx, mediaID)
+	if err != nil {
+		return err
+	}
+
+	// DB is the source of truth: objects that fail to delete here are left for GC
+	storage := GetStorageService()
+	for _, key := range keys {
+		if err := storage.Delete(ctx, key); err != nil {
+			log.Printf("[WARN] Failed to delete object %s: %v", key, err)
+		}
+	}
+	// On-demand thumbnails aren't recorded; most of these won't exist
+	for _, key := range thumbnailVariantKeys(mediaID) {
+		storage.Delete(ctx, key)
+	}
+	return nil
+}
+
+// probeMedia fills in dimensions, and for videos the container metadata.
+// Probe failures are logged only: the file type itself was already verified.
+func probeMedia(info *MediaInfo, r io.ReaderAt, size int64) {
+	if strings.HasPrefix(info.ContentType, "image/") {
+		if cfg, _, err := image.DecodeConfig(io.NewSectionReader(r, 0, size)); err == nil {
+			info.Width = cfg.Width
+			info.Height = cfg.Height
+		}
+		return
+	}
+
+	meta, err := ProbeVideo(r, size, info.ContentType)
+	if err != nil {
+		log.Printf("[WARN] Failed to probe video %s: %v", info.Key, err)
+		return
+	}
+	info.Duration = meta.Duration
+	info.Width = meta.Width
+	info.Height = meta.Height
+	info.FrameRate = meta.FrameRate
+	info.Codec = meta.Codec
+	info.Rotation = meta.Rotation
+}
+
+// ErrVideoLimitExceeded is returned when a video is too long or too large for processing
+var ErrVideoLimitExceeded = errors.New("video exceeds limits")
+
+// CheckVideoLimits enforces the configured duration and resolution limits.
+// While a limit is set, an unknown value (0) fails it too: a video whose
+// length the container doesn't record could be of any length.
+func CheckVideoLimits(meta *VideoMeta) error {
+	cfg := config.Get()
+	if cfg.VideoMaxDuration > 0 && meta.Duration <= 0 {
+		return fmt.Errorf("%w: duration is unknown, the maximum is %s", ErrVideoLimitExceeded, cfg.VideoMaxDuration)
+	}
+	if cfg.VideoMaxDuration > 0 && meta.Duration > cfg.VideoMaxDuration.Seconds() {
+		return fmt.Errorf("%w: duration %.1fs is over the %s maximum", ErrVideoLimitExceeded, meta.Duration, cfg.VideoMaxDuration)
+	}
+	if (cfg.VideoMaxWidth > 0 || cfg.VideoMaxHeight > 0) && (meta.Width <= 0 || meta.Height <= 0) {
+		return fmt.Errorf("%w: resolution is unknown", ErrVideoLimitExceeded)
+	}
+
+	long, short := int64(meta.Width), int64(meta.Height)
+	if short > long {
+		long, short = short, long
+	}
+	if (cfg.VideoMaxWidth > 0 && long > cfg.VideoMaxWidth) || (cfg.VideoMaxHeight > 0 && short > cfg.VideoMaxHeight) {
+		return fmt.Errorf("%w: resolution %dx%d is over the %dx%d maximum",
+			ErrVideoLimitExceeded, meta.Width, meta.Height, cfg.VideoMaxWidth, cfg.VideoMaxHeight)
+	}
+	return nil
+}
+
+// VideoMetaForKey returns the metadata of a stored video, from the media
+// library when recorded there, otherwise by probing the object directly.
+// Returns nil metadata for images and other non-video objects.
+func VideoMetaForKey(ctx context.Context, key string) (*VideoMeta, error) {
+	if media, err := repository.GetMediaFileByKey(ctx, key); err == nil && media != nil && media.VideoCodec.Valid {
+		return &VideoMeta{
+			Duration:  media.Duration.Float64,
+			Width:     int(media.Width.Int32),
+			Height:    int(media.Height.Int32),
+			FrameRate: media.FrameRate.Float64,
+			Codec:     media.VideoCodec.String,
+			Rotation:  int(media.Rotation.Int32),
+		}, nil
+	}
+
+	obj, size, err := GetStorageService().Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+
+	head, err := readAtMost(obj, 0, SniffLen)
+	if err != nil {
+		return nil, err
+	}
+	contentType := SniffContentType(head)
+	if !strings.HasPrefix(contentType, "video/") {
+		return nil, nil
+	}
+	return ProbeVideo(obj, size, contentType)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+// Media record statuses
+const (
+	MediaStatusImporting = "importing"
+	MediaStatusReady     = "ready"
+	MediaStatusFailed    = "failed"
+)
+
+// maxImportFilename matches the media library's filename limit
+const maxImportFilename = 255
+
+// importStatusTTL is how long finished imports stay in memory for status polling
+const importStatusTTL = time.Hour
+
+// MediaImport is the progress of a URL import, reported with task statuses
+type MediaImport struct {
+	MediaID   string
+	UserID    int64
+	Filename  string
+	Status    string // processing, completed, failed
+	Received  int64
+	Total     int64 // -1 until known
+	Error     string
+	UpdatedAt time.Time
+}
+
+// importJob tracks a running import; received is updated while streaming
+type importJob struct {
+	mu       sync.Mutex
+	state    MediaImport
+	received atomic.Int64
+}
+
+func (j *importJob) snapshot() *MediaImport {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	s := j.state
+	s.Received = j.received.Load()
+	return &s
+}
+
+func (j *importJob) update(fn func(s *MediaImport)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(&j.state)
+	j.state.UpdatedAt = time.Now()
+}
+
+func (j *importJob) Write(p []byte) (int, error) {
+	j.received.Add(int64(len(p)))
+	return len(p), nil
+}
+
+var mediaImports sync.Map // media ID -> *importJob
+
+// importStartMu makes checking a user's running imports and adding one atomic
+var importStartMu sync.Mutex
+
+// ErrTooManyImports is returned when a user already runs MEDIA_IMPORT_MAX_ACTIVE imports
+var ErrTooManyImports = errors.New("too many imports in progress")
+
+// activeImports returns the number of a user's running imports other than skip,
+// and the bytes they are going to add to storage: the announced size, or what
+// has arrived so far when that is more or no size was announced.
+func activeImports(userID int64, skip string) (count int, bytes int64) {
+	mediaImports.Range(func(_, val any) bool {
+		s := val.(*importJob).snapshot()
+		if s.UserID != userID || s.Status != "processing" || s.MediaID == skip {
+			return true
+		}
+		count++
+		if s.Total > s.Received {
+			bytes += s.Total
+		} else {
+			bytes += s.Received
+		}
+		return true
+	})
+	return count, bytes
+}
+
+// StartMediaImport validates a remote URL, records an importing media entry
+// and downloads it in the background. Only the URL itself is checked here;
+// size, type and quota are checked against the actual download. The user's
+// other running imports count against the quota as well.
+func StartMediaImport(ctx context.Context, userID int64, rawURL, filename string) (*MediaImport, error) {
+	policy := ImportURLPolicy()
+	if err := policy.Check(rawURL); err != nil {
+		return nil, err
+	}
+
+	importStartMu.Lock()
+	defer importStartMu.Unlock()
+	running, inFlight := activeImports(userID, "")
+	if limit := config.Get().MediaImportMaxActive; limit > 0 && running >= limit {
+		return nil, fmt.Errorf("%w: %d running, wait for one to finish", ErrTooManyImports, running)
+	}
+	if err := CheckStorageQuota(ctx, userID, inFlight); err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		filename = importFilename(rawURL)
+	}
+	if len(filename) > maxImportFilename {
+		filename = strings.ToValidUTF8(filename[:maxImportFilename], "")
+	}
+
+	job := &importJob{state: MediaImport{
+		MediaID:   generateMediaID(),
+		UserID:    userID,
+		Filename:  filename,
+		Status:    "processing",
+		Total:     -1,
+		UpdatedAt: time.Now(),
+	}}
+
+	err := repository.SaveMediaFile(ctx, &repository.MediaFile{
+		UserID:    userID,
+		MediaID:   job.state.MediaID,
+		Filename:  filename,
+		FileType:  "video",
+		Category:  MediaCategoryMedia,
+		Status:    MediaStatusImporting,
+		SourceURL: nullString(redactURL(rawURL)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("save media record: %w", err)
+	}
+
+	mediaImports.Store(job.state.MediaID, job)
+	go runMediaImport(job, policy, rawURL)
+
+	log.Printf("[INFO] Media import %s started for user %d: %s", job.state.MediaID, userID, redactURL(rawURL))
+	return job.snapshot(), nil
+}
+
+// GetMediaImport returns the progress of a user's import. Imports no longer in
+// memory are reported from the media record.
+func GetMediaImport(ctx context.Context, userID int64, mediaID string) (*MediaImport, error) {
+	if val, ok := mediaImports.Load(mediaID); ok {
+		if s := val.(*importJob).snapshot(); s.UserID == userID {
+			return s, nil
+		}
+		return nil, nil
+	}
+
+	media, err := repository.GetMediaFile(ctx, mediaID)
+	if err != nil || media == nil || media.UserID != userID || !media.SourceURL.Valid {
+		return nil, err
+	}
+	s := &MediaImport{
+		MediaID:   media.MediaID,
+		UserID:    media.UserID,
+		Filename:  media.Filename,
+		Status:    "processing",
+		Total:     -1,
+		Error:     media.ErrorMessage.String,
+		UpdatedAt: media.UpdatedAt,
+	}
+	switch media.Status {
+	case MediaStatusReady:
+		s.Status = "completed"
+		s.Received, s.Total = media.FileSize, media.FileSize
+	case MediaStatusFailed:
+		s.Status = "failed"
+	}
+	return s, nil
+}
+
+func runMediaImport(job *importJob, policy *URLPolicy, rawURL string) {
+	mediaID := job.state.MediaID
+	defer func() {
+		if r := recover(); r != nil {
+			failMediaImport(job, fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	info, err := downloadMediaImport(context.Background(), job, policy, rawURL)
+	if err != nil {
+		failMediaImport(job, err)
+		return
+	}
+
+	job.update(func(s *MediaImport) {
+		s.Status = "completed"
+		s.Total = info.Size
+	})
+	log.Printf("[INFO] Media import %s completed: %s (%d bytes)", mediaID, info.Key, info.Size)
+}
+
+func failMediaImport(job *importJob, err error) {
+	mediaID := job.state.MediaID
+	// The URL may carry access tokens; keep it out of the message
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	msg := importErrorMessage(err)
+	log.Printf("[WARN] Media import %s failed: %v", mediaID, err)
+	job.update(func(s *MediaImport) {
+		s.Status = "failed"
+		s.Error = msg
+	})
+	if err := repository.FailMediaImport(context.Background(), mediaID, msg); err != nil {
+		log.Printf("[ERROR] Failed to record media import failure %s: %v", mediaID, err)
+	}
+}
+
+// downloadMediaImport streams the URL to a staging file, checks it like an
+// upload, stores it and fills in the media record
+func downloadMediaImport(ctx context.Context, job *importJob, policy *URLPolicy, rawURL string) (*MediaInfo, error) {
+	userID := job.state.UserID
+
+	resp, err := policy.Get(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("download error: HTTP %d", resp.StatusCode)
+	}
+	if resp.ContentLength >= 0 {
+		job.update(func(s *MediaImport) { s.Total = resp.ContentLength })
+		_, inFlight := activeImports(userID, job.state.MediaID)
+		if err := CheckStorageQuota(ctx, userID, resp.ContentLength+inFlight); err != nil {
+			return nil, err
+		}
+	}
+
+	tmp, err := os.CreateTemp(config.Get().TusStagingDir, "import-*")
+	if err != nil {
+		return nil, fmt.Errorf("create staging file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher, job), resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+
+	// The remote Content-Type is not the user's claim and often generic: only the content counts
+	head := make([]byte, SniffLen)
+	n, _ := tmp.ReadAt(head, 0)
+	contentType, err := CheckMediaType(head[:n], "", MediaCategoryMedia)
+	if err != nil {
+		return nil, err
+	}
+	_, inFlight := activeImports(userID, job.state.MediaID)
+	if err := CheckStorageQuota(ctx, userID, size+inFlight); err != nil {
+		return nil, err
+	}
+
+	storage := GetStorageService()
+	info := &MediaInfo{
+		MediaID:     job.state.MediaID,
+		UserID:      userID,
+		Filename:    job.state.Filename,
+		Category:    MediaCategoryMedia,
+		FileType:    mediaFileType(contentType),
+		ContentType: contentType,
+		Size:        size,
+		Hash:        hex.EncodeToString(hasher.Sum(nil)),
+	}
+	// URLs often carry no or misleading extensions, so the key uses the detected one
+	info.Key = storage.GenerateKey(MediaKeyPrefix(MediaCategoryMedia, contentType), "import"+contentTypeExtensions[contentType])
+	if err := storage.UploadStream(ctx, info.Key, io.NewSectionReader(tmp, 0, size), size, contentType); err != nil {
+		return nil, err
+	}
+
+	probeMedia(info, tmp, size)
+	if info.FileType == "image" {
+		if content, err := io.ReadAll(io.NewSectionReader(tmp, 0, size)); err == nil {
+			storeThumbnail(ctx, info, content)
+		}
+	}
+
+	ok, err := repository.CompleteMediaImport(ctx, &repository.MediaFile{
+		MediaID:        info.MediaID,
+		FileType:       info.FileType,
+		ContentType:    nullString(info.ContentType),
+		FileSize:       info.Size,
+		FileHash:       nullString(info.Hash),
+		Width:          sql.NullInt32{Int32: int32(info.Width), Valid: info.Width > 0},
+		Height:         sql.NullInt32{Int32: int32(info.Height), Valid: info.Height > 0},
+		Duration:       sql.NullFloat64{Float64: info.Duration, Valid: info.Duration > 0},
+		FrameRate:      sql.NullFloat64{Float64: info.FrameRate, Valid: info.FrameRate > 0},
+		VideoCodec:     nullString(info.Codec),
+		Rotation:       sql.NullInt32{Int32: int32(info.Rotation), Valid: info.Codec != ""},
+		StorageKey:     nullString(info.Key),
+		ThumbnailKey:   nullString(info.ThumbnailKey),
+		ThumbnailSize:  sql.NullInt64{Int64: info.ThumbnailSize, Valid: info.ThumbnailKey != ""},
+		StorageBackend: nullString(storage.BackendID()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("save media record: %w", err)
+	}
+	if !ok {
+		// Deleted while downloading: don't leave the objects behind
+		for _, key := range []string{info.Key, info.ThumbnailKey} {
+			if key != "" {
+				storage.Delete(context.Background(), key)
+			}
+		}
+		return nil, errors.New("media was deleted during the import")
+	}
+	return info, nil
+}
+
+// importErrorMessage turns an import error into a message for the user
+func importErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrURLRejected):
+		return "URL not allowed"
+	case errors.Is(err, ErrResponseTooLarge):
+		return fmt.Sprintf("File too large. Maximum size is %d MB", MaxUploadSize(MediaCategoryMedia)>>20)
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return "Invalid file type. Only images and videos are allowed"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Download timed out"
+	}
+	return err.Error()
+}
+
+// importFilename derives a filename from the last URL path segment
+func importFilename(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if name, err := url.PathUnescape(path.Base(u.Path)); err == nil {
+			name = strings.TrimSpace(name)
+			if name != "" && name != "/" && name != "." {
+				return name
+			}
+		}
+	}
+	return "import"
+}
+
+// mediaFileType returns the library file type for a content type
+func mediaFileType(contentType string) string {
+	if strings.HasPrefix(contentType, "image/") {
+		return "image"
+	}
+	return "video"
+}
+
+// FailInterruptedImports marks imports cut off by a restart as failed
+func FailInterruptedImports() {
+	n, err := repository.FailInterruptedImports(context.Background(), "Import interrupted by a server restart")
+	if err != nil {
+		log.Printf("[ERROR] Failed to clean up interrupted media imports: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("[INFO] Marked %d interrupted media imports as failed", n)
+	}
+}
+
+// CleanupMediaImports drops finished imports from memory once their TTL passes
+func CleanupMediaImports() {
+	now := time.Now()
+	mediaImports.Range(func(key, value interface{}) bool {
+		s := value.(*importJob).snapshot()
+		if s.Status != "processing" && now.Sub(s.UpdatedAt) > importStatusTTL {
+			mediaImports.Delete(key)
+		}
+		return true
+	})
+}
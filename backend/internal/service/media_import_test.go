@@ -0,0 +1,41 @@
+package service
+
+import "testing"
+
+func TestImportFilename(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://example.com/videos/demo.mp4", "demo.mp4"},
+		{"https://example.com/videos/%E6%96%B0%E5%93%81.mp4?token=abc", "新品.mp4"},
+		{"https://example.com/", "import"},
+		{"https://example.com", "import"},
+	}
+	for _, tt := range tests {
+		if got := importFilename(tt.url); got != tt.want {
+			t.Errorf("importFilename(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestActiveImports(t *testing.T) {
+	jobs := []*importJob{
+		{state: MediaImport{MediaID: "imp-a", UserID: 7, Status: "processing", Total: 1000}},
+		{state: MediaImport{MediaID: "imp-b", UserID: 7, Status: "processing", Total: -1}},
+		{state: MediaImport{MediaID: "imp-c", UserID: 7, Status: "completed", Total: 5000}},
+		{state: MediaImport{MediaID: "imp-d", UserID: 8, Status: "processing", Total: 3000}},
+	}
+	jobs[0].received.Store(400)
+	jobs[1].received.Store(250) // No announced size: what arrived counts
+	for _, j := range jobs {
+		mediaImports.Store(j.state.MediaID, j)
+		defer mediaImports.Delete(j.state.MediaID)
+	}
+
+	if count, bytes := activeImports(7, ""); count != 2 || bytes != 1250 {
+		t.Errorf("activeImports(7) = %d, %d; want 2, 1250", count, bytes)
+	}
+	if count, bytes := activeImports(7, "imp-a"); count != 1 || bytes != 250 {
+		t.Errorf("activeImports(7) skipping imp-a = %d, %d; want 1, 250", count, bytes)
+	}
+}
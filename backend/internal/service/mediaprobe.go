@@ -0,0 +1,656 @@
+package service
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+)
+
+// Container metadata probing in pure Go: only the boxes/elements needed for
+// duration, resolution, frame rate, codec and rotation are read, so the whole
+// file never has to be loaded (moov at the end of an MP4 costs one seek).
+
+// VideoMeta is the container-level metadata of a video
+type VideoMeta struct {
+	Duration  float64 // seconds
+	Width     int     // coded width, before rotation
+	Height    int     // coded height, before rotation
+	FrameRate float64 // frames per second (average)
+	Codec     string  // e.g. avc1, hvc1, V_VP9, H264
+	Rotation  int     // clockwise degrees: 0, 90, 180 or 270
+}
+
+var (
+	ErrProbeUnsupported = errors.New("unsupported video container")
+	ErrProbeMalformed   = errors.New("malformed video container")
+	ErrProbeTooComplex  = errors.New("video container nests too deep or has too many boxes")
+	errProbeDone        = errors.New("probe done")
+)
+
+// Limits on the structure walked by one probe. Crafted files can nest boxes
+// arbitrarily deep or contain millions of tiny ones; real files need a few
+// levels and, before the media data, a few hundred.
+const (
+	maxProbeDepth = 8
+	maxProbeBoxes = 10000
+)
+
+// probeBudget counts the boxes/elements/chunks visited by one probe
+type probeBudget struct {
+	visited int
+}
+
+// visit accounts for one box at depth (top level is 0)
+func (b *probeBudget) visit(depth int) error {
+	b.visited++
+	if depth > maxProbeDepth || b.visited > maxProbeBoxes {
+		return ErrProbeTooComplex
+	}
+	return nil
+}
+
+// ProbeVideo reads container metadata from MP4/MOV, WebM or AVI content
+func ProbeVideo(r io.ReaderAt, size int64, contentType string) (*VideoMeta, error) {
+	var (
+		meta *VideoMeta
+		err  error
+	)
+	switch contentType {
+	case "video/mp4", "video/quicktime":
+		meta, err = probeMP4(r, size)
+	case "video/webm":
+		meta, err = probeWebM(r, size)
+	case "video/x-msvideo":
+		meta, err = probeAVI(r, size)
+	default:
+		return nil, ErrProbeUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	meta.Duration = roundTo(meta.Duration, 3)
+	meta.FrameRate = roundTo(meta.FrameRate, 3)
+	return meta, nil
+}
+
+func roundTo(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
+
+// readAtMost reads up to n bytes at off, tolerating a short read at EOF
+func readAtMost(r io.ReaderAt, off int64, n int64) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, off)
+	if err != nil && !(errors.Is(err, io.EOF) && read > 0) {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+// --- MP4 / QuickTime (ISO base media file format) ---
+
+type mp4Box struct {
+	typ       string
+	dataStart int64
+	end       int64
+	depth     int
+}
+
+// walkMP4Boxes calls fn for every box between start and end, which are at
+// depth. fn may return errProbeDone to stop early.
+func walkMP4Boxes(r io.ReaderAt, budget *probeBudget, start, end int64, depth int, fn func(b mp4Box) error) error {
+	var hdr [16]byte
+	for off := start; off+8 <= end; {
+		if err := budget.visit(depth); err != nil {
+			return err
+		}
+		if _, err := r.ReadAt(hdr[:8], off); err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(hdr[:4]))
+		headerLen := int64(8)
+		switch size {
+		case 0: // Box extends to the end of its parent
+			size = end - off
+		case 1: // 64-bit largesize follows the type
+			if _, err := r.ReadAt(hdr[8:16], off+8); err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			headerLen = 16
+		}
+		if size < headerLen || size > end-off {
+			return ErrProbeMalformed
+		}
+		if err := fn(mp4Box{typ: string(hdr[4:8]), dataStart: off + headerLen, end: off + size, depth: depth}); err != nil {
+			return err
+		}
+		off += size
+	}
+	return nil
+}
+
+// mp4Track collects what we need from a trak box
+type mp4Track struct {
+	handler   string
+	width     int
+	height    int
+	rotation  int
+	timescale uint32
+	duration  uint64
+	samples   uint32
+	codec     string
+}
+
+func probeMP4(r io.ReaderAt, size int64) (*VideoMeta, error) {
+	meta := &VideoMeta{}
+	budget := &probeBudget{}
+	foundMoov := false
+	err := walkMP4Boxes(r, budget, 0, size, 0, func(b mp4Box) error {
+		if b.typ != "moov" {
+			return nil
+		}
+		foundMoov = true
+		if err := parseMoov(r, budget, b, meta); err != nil {
+			return err
+		}
+		return errProbeDone
+	})
+	// Trailing garbage or a truncated mdat after moov doesn't matter
+	if !foundMoov {
+		if err == nil {
+			err = ErrProbeMalformed
+		}
+		return nil, err
+	}
+	if err != nil && err != errProbeDone {
+		return nil, err
+	}
+	if meta.Codec == "" {
+		return nil, errors.New("no video track found")
+	}
+	return meta, nil
+}
+
+func parseMoov(r io.ReaderAt, budget *probeBudget, moov mp4Box, meta *VideoMeta) error {
+	var movieTimescale uint32
+	var fragmentDuration uint64
+	err := walkMP4Boxes(r, budget, moov.dataStart, moov.end, moov.depth+1, func(b mp4Box) error {
+		switch b.typ {
+		case "mvhd":
+			data, err := readAtMost(r, b.dataStart, 32)
+			if err != nil {
+				return err
+			}
+			if timescale, duration, ok := parseMediaHeader(data); ok && timescale > 0 {
+				movieTimescale = timescale
+				meta.Duration = float64(duration) / float64(timescale)
+			}
+		case "mvex":
+			// Fragmented MP4 leaves the mvhd duration at 0; mehd holds the total
+			return walkMP4Boxes(r, budget, b.dataStart, b.end, b.depth+1, func(b mp4Box) error {
+				if b.typ != "mehd" {
+					return nil
+				}
+				data, err := readAtMost(r, b.dataStart, 12)
+				if err != nil {
+					return err
+				}
+				fragmentDuration = parseMovieExtendsHeader(data)
+				return nil
+			})
+		case "trak":
+			if meta.Codec != "" {
+				return nil // First video track wins
+			}
+			track := &mp4Track{}
+			if err := parseTrak(r, budget, b, track); err != nil {
+				return err
+			}
+			if track.handler != "vide" {
+				return nil
+			}
+			meta.Codec = track.codec
+			meta.Width = track.width
+			meta.Height = track.height
+			meta.Rotation = track.rotation
+			if track.timescale > 0 && track.duration > 0 {
+				seconds := float64(track.duration) / float64(track.timescale)
+				meta.FrameRate = float64(track.samples) / seconds
+				if meta.Duration == 0 {
+					meta.Duration = seconds
+				}
+			}
+		}
+		return nil
+	})
+	if meta.Duration == 0 && fragmentDuration > 0 && movieTimescale > 0 {
+		meta.Duration = float64(fragmentDuration) / float64(movieTimescale)
+	}
+	return err
+}
+
+func parseTrak(r io.ReaderAt, budget *probeBudget, parent mp4Box, track *mp4Track) error {
+	return walkMP4Boxes(r, budget, parent.dataStart, parent.end, parent.depth+1, func(b mp4Box) error {
+		switch b.typ {
+		case "mdia", "minf", "stbl":
+			return parseTrak(r, budget, b, track)
+		case "tkhd":
+			data, err := readAtMost(r, b.dataStart, 96)
+			if err != nil {
+				return err
+			}
+			parseTrackHeader(data, track)
+		case "mdhd":
+			data, err := readAtMost(r, b.dataStart, 32)
+			if err != nil {
+				return err
+			}
+			track.timescale, track.duration, _ = parseMediaHeader(data)
+		case "hdlr":
+			data, err := readAtMost(r, b.dataStart, 12)
+			if err != nil {
+				return err
+			}
+			// QuickTime also has a data handler (dhlr) under minf; the media handler comes first
+			if len(data) == 12 && track.handler == "" {
+				track.handler = string(data[8:12])
+			}
+		case "stsd":
+			// version/flags, entry count, then the first sample entry:
+			// size, format, 6 reserved, data ref index, 16 predefined, width, height
+			data, err := readAtMost(r, b.dataStart, 44)
+			if err != nil {
+				return err
+			}
+			if len(data) >= 16 {
+				track.codec = strings.TrimSpace(string(data[12:16]))
+			}
+			if len(data) == 44 && track.width == 0 {
+				track.width = int(binary.BigEndian.Uint16(data[40:42]))
+				track.height = int(binary.BigEndian.Uint16(data[42:44]))
+			}
+		case "stsz", "stz2":
+			// version/flags, sample size (or field size), sample count
+			data, err := readAtMost(r, b.dataStart, 12)
+			if err != nil {
+				return err
+			}
+			if len(data) == 12 {
+				track.samples = binary.BigEndian.Uint32(data[8:12])
+			}
+		}
+		return nil
+	})
+}
+
+// parseMediaHeader reads timescale and duration from an mvhd or mdhd payload
+func parseMediaHeader(data []byte) (timescale uint32, duration uint64, ok bool) {
+	if len(data) < 1 {
+		return 0, 0, false
+	}
+	if data[0] == 1 {
+		if len(data) < 32 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint32(data[20:24]), binary.BigEndian.Uint64(data[24:32]), true
+	}
+	if len(data) < 20 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(data[12:16]), uint64(binary.BigEndian.Uint32(data[16:20])), true
+}
+
+// parseMovieExtendsHeader reads the fragment duration from a mehd payload
+func parseMovieExtendsHeader(data []byte) uint64 {
+	if len(data) >= 12 && data[0] == 1 {
+		return binary.BigEndian.Uint64(data[4:12])
+	}
+	if len(data) >= 8 && data[0] == 0 {
+		return uint64(binary.BigEndian.Uint32(data[4:8]))
+	}
+	return 0
+}
+
+// parseTrackHeader reads the display size and transformation matrix from a tkhd payload
+func parseTrackHeader(data []byte, track *mp4Track) {
+	matrixOff := 40
+	if len(data) > 0 && data[0] == 1 {
+		matrixOff = 52 // 64-bit times and duration
+	}
+	if len(data) < matrixOff+44 {
+		return
+	}
+
+	// Width and height are 16.16 fixed point after the 3x3 matrix
+	track.width = int(binary.BigEndian.Uint32(data[matrixOff+36:]) >> 16)
+	track.height = int(binary.BigEndian.Uint32(data[matrixOff+40:]) >> 16)
+
+	// Matrix is {a, b, u, c, d, v, x, y, w}; rotation comes from a, b, c, d
+	a := int32(binary.BigEndian.Uint32(data[matrixOff:]))
+	b := int32(binary.BigEndian.Uint32(data[matrixOff+4:]))
+	c := int32(binary.BigEndian.Uint32(data[matrixOff+12:]))
+	d := int32(binary.BigEndian.Uint32(data[matrixOff+16:]))
+	switch {
+	case a == 0 && b > 0 && c < 0:
+		track.rotation = 90
+	case a < 0 && d < 0:
+		track.rotation = 180
+	case a == 0 && b < 0 && c > 0:
+		track.rotation = 270
+	}
+}
+
+// --- WebM (Matroska / EBML) ---
+
+const (
+	ebmlIDSegment        = 0x18538067
+	ebmlIDInfo           = 0x1549A966
+	ebmlIDTimecodeScale  = 0x2AD7B1
+	ebmlIDDuration       = 0x4489
+	ebmlIDTracks         = 0x1654AE6B
+	ebmlIDTrackEntry     = 0xAE
+	ebmlIDTrackType      = 0x83
+	ebmlIDCodecID        = 0x86
+	ebmlIDDefaultDur     = 0x23E383
+	ebmlIDVideo          = 0xE0
+	ebmlIDPixelWidth     = 0xB0
+	ebmlIDPixelHeight    = 0xBA
+	ebmlIDCluster        = 0x1F43B675
+	ebmlTrackTypeVideo   = 1
+	ebmlUnknownSize      = -1
+	ebmlMaxElementHeader = 12 // 4-byte ID + 8-byte size
+	ebmlMaxCodecID       = 64 // Matroska codec IDs are short ASCII strings
+)
+
+// ebmlFunc handles an element at depth; children are walked at depth+1
+type ebmlFunc func(id uint32, dataStart, dataEnd int64, depth int) error
+
+// walkEBML calls fn for every element between start and end, which are at depth.
+// Elements of unknown size (live recordings) extend to the end of their parent.
+func walkEBML(r io.ReaderAt, budget *probeBudget, start, end int64, depth int, fn ebmlFunc) error {
+	for off := start; off < end; {
+		if err := budget.visit(depth); err != nil {
+			return err
+		}
+		hdr, err := readAtMost(r, off, ebmlMaxElementHeader)
+		if err != nil {
+			return err
+		}
+		id, idLen := readEBMLID(hdr)
+		if idLen == 0 {
+			return ErrProbeMalformed
+		}
+		size, sizeLen := readEBMLSize(hdr[idLen:])
+		if sizeLen == 0 {
+			return ErrProbeMalformed
+		}
+		dataStart := off + int64(idLen+sizeLen)
+		dataEnd := end
+		if size != ebmlUnknownSize {
+			if size > end-dataStart {
+				return ErrProbeMalformed
+			}
+			dataEnd = dataStart + size
+		}
+		if err := fn(id, dataStart, dataEnd, depth); err != nil {
+			return err
+		}
+		off = dataEnd
+	}
+	return nil
+}
+
+// readEBMLID reads an element ID, keeping its length marker bits
+func readEBMLID(b []byte) (uint32, int) {
+	if len(b) == 0 || b[0] < 0x10 {
+		return 0, 0 // IDs are at most 4 bytes
+	}
+	n := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if len(b) < n {
+		return 0, 0
+	}
+	var id uint32
+	for _, c := range b[:n] {
+		id = id<<8 | uint32(c)
+	}
+	return id, n
+}
+
+// readEBMLSize reads an element data size; all value bits set means unknown size
+func readEBMLSize(b []byte) (int64, int) {
+	value, n := readVint(b)
+	if n == 0 {
+		return 0, 0
+	}
+	if value == 1<<(7*n)-1 {
+		return ebmlUnknownSize, n
+	}
+	return int64(value), n
+}
+
+func readEBMLUint(r io.ReaderAt, start, end int64) (uint64, error) {
+	if end-start > 8 {
+		return 0, ErrProbeMalformed
+	}
+	data, err := readAtMost(r, start, end-start)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range data {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func readEBMLFloat(r io.ReaderAt, start, end int64) (float64, error) {
+	if n := end - start; n != 4 && n != 8 {
+		return 0, ErrProbeMalformed
+	}
+	data, err := readAtMost(r, start, end-start)
+	if err != nil {
+		return 0, err
+	}
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), nil
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	}
+	return 0, ErrProbeMalformed
+}
+
+func probeWebM(r io.ReaderAt, size int64) (*VideoMeta, error) {
+	meta := &VideoMeta{}
+	budget := &probeBudget{}
+	timecodeScale := uint64(1000000) // Default: 1ms
+	var rawDuration float64
+
+	var parseTrack ebmlFunc
+	var trackType uint64
+	var codec string
+	var defaultDuration uint64
+	var width, height uint64
+
+	parseTrack = func(id uint32, start, end int64, depth int) error {
+		var err error
+		switch id {
+		case ebmlIDTrackType:
+			trackType, err = readEBMLUint(r, start, end)
+		case ebmlIDCodecID:
+			if end-start > ebmlMaxCodecID {
+				return ErrProbeMalformed
+			}
+			var data []byte
+			if data, err = readAtMost(r, start, end-start); err == nil {
+				codec = strings.TrimRight(string(data), "\x00")
+			}
+		case ebmlIDDefaultDur:
+			defaultDuration, err = readEBMLUint(r, start, end)
+		case ebmlIDVideo:
+			err = walkEBML(r, budget, start, end, depth+1, parseTrack)
+		case ebmlIDPixelWidth:
+			width, err = readEBMLUint(r, start, end)
+		case ebmlIDPixelHeight:
+			height, err = readEBMLUint(r, start, end)
+		}
+		return err
+	}
+
+	parseSegment := func(id uint32, start, end int64, depth int) error {
+		switch id {
+		case ebmlIDInfo:
+			return walkEBML(r, budget, start, end, depth+1, func(id uint32, start, end int64, _ int) error {
+				var err error
+				switch id {
+				case ebmlIDTimecodeScale:
+					timecodeScale, err = readEBMLUint(r, start, end)
+				case ebmlIDDuration:
+					rawDuration, err = readEBMLFloat(r, start, end)
+				}
+				return err
+			})
+		case ebmlIDTracks:
+			return walkEBML(r, budget, start, end, depth+1, func(id uint32, start, end int64, depth int) error {
+				if id != ebmlIDTrackEntry || meta.Codec != "" {
+					return nil
+				}
+				trackType, codec, defaultDuration, width, height = 0, "", 0, 0, 0
+				if err := walkEBML(r, budget, start, end, depth+1, parseTrack); err != nil {
+					return err
+				}
+				if trackType == ebmlTrackTypeVideo {
+					meta.Codec = codec
+					meta.Width = int(width)
+					meta.Height = int(height)
+					if defaultDuration > 0 {
+						meta.FrameRate = 1e9 / float64(defaultDuration)
+					}
+				}
+				return nil
+			})
+		case ebmlIDCluster:
+			// Headers always precede media data
+			return errProbeDone
+		}
+		return nil
+	}
+
+	err := walkEBML(r, budget, 0, size, 0, func(id uint32, start, end int64, depth int) error {
+		if id != ebmlIDSegment {
+			return nil
+		}
+		if err := walkEBML(r, budget, start, end, depth+1, parseSegment); err != nil {
+			return err
+		}
+		return errProbeDone
+	})
+	// Errors past the track headers (e.g. a truncated cluster) don't matter
+	if meta.Codec == "" {
+		if err != nil && err != errProbeDone {
+			return nil, err
+		}
+		return nil, errors.New("no video track found")
+	}
+
+	// Recordings from MediaRecorder often omit Duration; it is left at 0
+	meta.Duration = rawDuration * float64(timecodeScale) / 1e9
+	return meta, nil
+}
+
+// --- AVI (RIFF) ---
+
+// riffFunc handles a chunk at depth; children are walked at depth+1
+type riffFunc func(id string, dataStart, dataEnd int64, depth int) error
+
+// walkRIFFChunks calls fn for every chunk between start and end, which are at
+// depth (little-endian sizes, even padding)
+func walkRIFFChunks(r io.ReaderAt, budget *probeBudget, start, end int64, depth int, fn riffFunc) error {
+	var hdr [8]byte
+	for off := start; off+8 <= end; {
+		if err := budget.visit(depth); err != nil {
+			return err
+		}
+		if _, err := r.ReadAt(hdr[:], off); err != nil {
+			return err
+		}
+		size := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+		dataStart := off + 8
+		if size > end-dataStart {
+			return ErrProbeMalformed
+		}
+		if err := fn(string(hdr[:4]), dataStart, dataStart+size, depth); err != nil {
+			return err
+		}
+		off = dataStart + size + size%2
+	}
+	return nil
+}
+
+func probeAVI(r io.ReaderAt, size int64) (*VideoMeta, error) {
+	meta := &VideoMeta{}
+	budget := &probeBudget{}
+	var found bool
+
+	var walk riffFunc
+	walk = func(id string, start, end int64, depth int) error {
+		switch id {
+		case "LIST":
+			listType, err := readAtMost(r, start, 4)
+			if err != nil {
+				return err
+			}
+			switch string(listType) {
+			case "hdrl", "strl":
+				return walkRIFFChunks(r, budget, start+4, end, depth+1, walk)
+			case "movi":
+				return errProbeDone // Headers always precede media data
+			}
+		case "avih":
+			// MainAVIHeader: µs per frame, ..., total frames at 16, width at 32, height at 36
+			data, err := readAtMost(r, start, 40)
+			if err != nil {
+				return err
+			}
+			if len(data) < 40 {
+				return ErrProbeMalformed
+			}
+			found = true
+			usPerFrame := binary.LittleEndian.Uint32(data[0:4])
+			totalFrames := binary.LittleEndian.Uint32(data[16:20])
+			meta.Width = int(binary.LittleEndian.Uint32(data[32:36]))
+			meta.Height = int(binary.LittleEndian.Uint32(data[36:40]))
+			if usPerFrame > 0 {
+				meta.FrameRate = 1e6 / float64(usPerFrame)
+				meta.Duration = float64(totalFrames) * float64(usPerFrame) / 1e6
+			}
+		case "strh":
+			// AVIStreamHeader: fccType, fccHandler
+			data, err := readAtMost(r, start, 8)
+			if err != nil {
+				return err
+			}
+			if len(data) == 8 && string(data[:4]) == "vids" && meta.Codec == "" {
+				meta.Codec = strings.TrimRight(string(data[4:8]), " \x00")
+			}
+		}
+		return nil
+	}
+
+	// RIFF header: "RIFF", size, "AVI "
+	err := walkRIFFChunks(r, budget, 12, size, 0, walk)
+	if !found {
+		if err != nil && err != errProbeDone {
+			return nil, err
+		}
+		return nil, ErrProbeMalformed
+	}
+	return meta, nil
+}
@@ -0,0 +1,223 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(out, uint32(8+len(body)))
+	copy(out[4:], typ)
+	return append(out, body...)
+}
+
+func u32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+// buildMP4 creates a minimal MP4 with the moov box after mdat, like most camera files
+func buildMP4(rotation int) []byte {
+	mvhd := bytes.Join([][]byte{make([]byte, 12), u32(1000), u32(12500), make([]byte, 80)}, nil)
+
+	// Matrix entries a, b, c, d in 16.16 fixed point
+	one, neg := uint32(0x10000), uint32(0xFFFF0000)
+	a, b, c, d := one, uint32(0), uint32(0), one
+	switch rotation {
+	case 90:
+		a, b, c, d = 0, one, neg, 0
+	case 180:
+		a, d = neg, neg
+	}
+	matrix := bytes.Join([][]byte{u32(a), u32(b), u32(0), u32(c), u32(d), u32(0), u32(0), u32(0), u32(0x40000000)}, nil)
+	tkhd := bytes.Join([][]byte{make([]byte, 40), matrix, u32(1920 << 16), u32(1080 << 16)}, nil)
+
+	mdhd := bytes.Join([][]byte{make([]byte, 12), u32(30000), u32(375000), make([]byte, 4)}, nil)
+	hdlr := bytes.Join([][]byte{make([]byte, 8), []byte("vide"), make([]byte, 13)}, nil)
+	stsd := bytes.Join([][]byte{make([]byte, 4), u32(1), box("avc1", make([]byte, 70))}, nil)
+	stsz := bytes.Join([][]byte{make([]byte, 8), u32(375)}, nil)
+
+	trak := box("trak",
+		box("tkhd", tkhd),
+		box("mdia", box("mdhd", mdhd), box("hdlr", hdlr),
+			box("minf", box("stbl", box("stsd", stsd), box("stsz", stsz)))),
+	)
+	return bytes.Join([][]byte{
+		box("ftyp", []byte("isom"), u32(512), []byte("isomiso2")),
+		box("mdat", make([]byte, 1024)),
+		box("moov", box("mvhd", mvhd), trak),
+	}, nil)
+}
+
+func ebml(id []byte, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(body)))
+	size[0] = 0x01 // 8-byte size vint
+	return bytes.Join([][]byte{id, size, body}, nil)
+}
+
+func buildWebM() []byte {
+	duration := make([]byte, 8)
+	binary.BigEndian.PutUint64(duration, math.Float64bits(90500)) // ms at the default timecode scale
+
+	header := ebml([]byte{0x1A, 0x45, 0xDF, 0xA3}, ebml([]byte{0x42, 0x82}, []byte("webm")))
+	info := ebml([]byte{0x15, 0x49, 0xA9, 0x66}, ebml([]byte{0x44, 0x89}, duration))
+	audio := ebml([]byte{0xAE}, ebml([]byte{0x83}, []byte{2}), ebml([]byte{0x86}, []byte("A_OPUS")))
+	video := ebml([]byte{0xAE},
+		ebml([]byte{0x83}, []byte{1}),
+		ebml([]byte{0x86}, []byte("V_VP9")),
+		ebml([]byte{0x23, 0xE3, 0x83}, u32(40000000)), // 25fps
+		ebml([]byte{0xE0}, ebml([]byte{0xB0}, []byte{0x02, 0x80}), ebml([]byte{0xBA}, []byte{0x01, 0xE0})),
+	)
+	tracks := ebml([]byte{0x16, 0x54, 0xAE, 0x6B}, audio, video)
+
+	// Segment and cluster of unknown size, as MediaRecorder writes them
+	unknown := []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+	cluster := bytes.Join([][]byte{{0x1F, 0x43, 0xB6, 0x75}, unknown, make([]byte, 64)}, nil)
+	segment := bytes.Join([][]byte{{0x18, 0x53, 0x80, 0x67}, unknown, info, tracks, cluster}, nil)
+	return append(header, segment...)
+}
+
+func TestProbeMP4(t *testing.T) {
+	for _, rotation := range []int{0, 90, 180} {
+		data := buildMP4(rotation)
+		if got := SniffContentType(data); got != "video/mp4" {
+			t.Fatalf("SniffContentType() = %q", got)
+		}
+		meta, err := ProbeVideo(bytes.NewReader(data), int64(len(data)), "video/mp4")
+		if err != nil {
+			t.Fatalf("ProbeVideo() error: %v", err)
+		}
+		want := VideoMeta{Duration: 12.5, Width: 1920, Height: 1080, FrameRate: 30, Codec: "avc1", Rotation: rotation}
+		if *meta != want {
+			t.Errorf("ProbeVideo() = %+v, want %+v", *meta, want)
+		}
+	}
+}
+
+func TestProbeWebM(t *testing.T) {
+	data := buildWebM()
+	if got := SniffContentType(data); got != "video/webm" {
+		t.Fatalf("SniffContentType() = %q", got)
+	}
+	meta, err := ProbeVideo(bytes.NewReader(data), int64(len(data)), "video/webm")
+	if err != nil {
+		t.Fatalf("ProbeVideo() error: %v", err)
+	}
+	want := VideoMeta{Duration: 90.5, Width: 640, Height: 480, FrameRate: 25, Codec: "V_VP9"}
+	if *meta != want {
+		t.Errorf("ProbeVideo() = %+v, want %+v", *meta, want)
+	}
+}
+
+func TestProbeFragmentedMP4(t *testing.T) {
+	// Fragmented files carry no durations in mvhd/mdhd, only the total in mvex/mehd
+	mvhd := bytes.Join([][]byte{make([]byte, 12), u32(1000), u32(0), make([]byte, 80)}, nil)
+	mehd := bytes.Join([][]byte{make([]byte, 4), u32(42000)}, nil)
+	hdlr := bytes.Join([][]byte{make([]byte, 8), []byte("vide"), make([]byte, 13)}, nil)
+	stsd := bytes.Join([][]byte{make([]byte, 4), u32(1), box("avc1", make([]byte, 70))}, nil)
+	data := bytes.Join([][]byte{
+		box("ftyp", []byte("iso5"), u32(512), []byte("iso5dash")),
+		box("moov",
+			box("mvhd", mvhd),
+			box("trak", box("mdia", box("hdlr", hdlr), box("minf", box("stbl", box("stsd", stsd))))),
+			box("mvex", box("mehd", mehd)),
+		),
+		box("moof", make([]byte, 16)),
+	}, nil)
+
+	meta, err := ProbeVideo(bytes.NewReader(data), int64(len(data)), "video/mp4")
+	if err != nil {
+		t.Fatalf("ProbeVideo() error: %v", err)
+	}
+	if meta.Duration != 42 {
+		t.Errorf("Duration = %v, want 42", meta.Duration)
+	}
+}
+
+func TestProbeTruncated(t *testing.T) {
+	data := buildMP4(0)
+	data = data[:len(data)-40] // Cut into moov
+	if _, err := ProbeVideo(bytes.NewReader(data), int64(len(data)), "video/mp4"); err == nil {
+		t.Error("expected an error for a truncated moov box")
+	}
+}
+
+func TestProbeWebMOversizedElements(t *testing.T) {
+	header := ebml([]byte{0x1A, 0x45, 0xDF, 0xA3}, ebml([]byte{0x42, 0x82}, []byte("webm")))
+	segment := func(children ...[]byte) []byte {
+		return append(append([]byte(nil), header...), ebml([]byte{0x18, 0x53, 0x80, 0x67}, children...)...)
+	}
+	codecID := ebml([]byte{0x16, 0x54, 0xAE, 0x6B}, ebml([]byte{0xAE},
+		ebml([]byte{0x83}, []byte{1}),
+		ebml([]byte{0x86}, bytes.Repeat([]byte("V"), ebmlMaxCodecID+1)),
+	))
+	duration := ebml([]byte{0x15, 0x49, 0xA9, 0x66}, ebml([]byte{0x44, 0x89}, make([]byte, 16)))
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"codec id", segment(codecID)},
+		{"duration", segment(duration)},
+	}
+	for _, tt := range tests {
+		_, err := ProbeVideo(bytes.NewReader(tt.data), int64(len(tt.data)), "video/webm")
+		if !errors.Is(err, ErrProbeMalformed) {
+			t.Errorf("%s: ProbeVideo() error = %v, want %v", tt.name, err, ErrProbeMalformed)
+		}
+	}
+}
+
+func riffChunk(id string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	out := make([]byte, 8, 8+len(body))
+	copy(out, id)
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(body)))
+	return append(out, body...)
+}
+
+func TestProbeNestingLimits(t *testing.T) {
+	// mdia boxes nested far deeper than any real file, which used to recurse without bound
+	mp4 := box("stbl", box("stsd", make([]byte, 8)))
+	for i := 0; i < 1000; i++ {
+		mp4 = box("mdia", mp4)
+	}
+	mp4 = bytes.Join([][]byte{box("ftyp", []byte("isom")), box("moov", box("trak", mp4))}, nil)
+
+	// The same with strl lists in an AVI
+	avi := riffChunk("avih", make([]byte, 40))
+	for i := 0; i < 1000; i++ {
+		avi = riffChunk("LIST", []byte("strl"), avi)
+	}
+	avi = bytes.Join([][]byte{[]byte("RIFF"), make([]byte, 4), []byte("AVI "), avi}, nil)
+
+	// Many empty boxes at the top level, before any moov
+	var many []byte
+	for i := 0; i <= maxProbeBoxes; i++ {
+		many = append(many, box("free")...)
+	}
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+	}{
+		{"nested mp4", mp4, "video/mp4"},
+		{"nested avi", avi, "video/x-msvideo"},
+		{"too many boxes", many, "video/mp4"},
+	}
+	for _, tt := range tests {
+		_, err := ProbeVideo(bytes.NewReader(tt.data), int64(len(tt.data)), tt.contentType)
+		if !errors.Is(err, ErrProbeTooComplex) {
+			t.Errorf("%s: ProbeVideo() error = %v, want %v", tt.name, err, ErrProbeTooComplex)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+var (
+	// ErrCreditQuotaExceeded is returned when a task would take a user over their monthly credits
+	ErrCreditQuotaExceeded = errors.New("credit quota exceeded")
+	// ErrDurationUnknown is returned when a swap can't be priced because the video has no known duration
+	ErrDurationUnknown = errors.New("video duration unknown")
+	// ErrNoPricing is returned for model versions missing from the pricing table
+	ErrNoPricing = errors.New("no pricing for model version")
+)
+
+// VModelPrice is the pricing of one VModel version. Credits are charged per
+// second of video; processing time is estimated the same way.
+type VModelPrice struct {
+	PerSecond          float64 `json:"per_second"`          // Credits per second of video, one face
+	PerExtraFace       float64 `json:"per_extra_face"`      // Credits per second for each additional face
+	EnhanceFactor      float64 `json:"enhance_factor"`      // Multiplier with face_enhance, 0 = no surcharge
+	Minimum            float64 `json:"minimum"`             // Smallest charge per task
+	ProcessingFactor   float64 `json:"processing_factor"`   // Processing seconds per second of video
+	ProcessingOverhead float64 `json:"processing_overhead"` // Fixed processing seconds (queueing, upload)
+}
+
+// defaultPricing is used for versions not set in VMODEL_PRICING
+var defaultPricing = map[string]VModelPrice{
+	VModelVideoMultiFaceSwapVersion: {
+		PerSecond:          1,
+		PerExtraFace:       0.5,
+		EnhanceFactor:      1.5,
+		Minimum:            5,
+		ProcessingFactor:   3,
+		ProcessingOverhead: 30,
+	},
+}
+
+var (
+	pricingOnce  sync.Once
+	pricingTable map[string]VModelPrice
+)
+
+// getPricing returns the pricing table with the configured overrides applied
+func getPricing() map[string]VModelPrice {
+	pricingOnce.Do(func() {
+		pricingTable = make(map[string]VModelPrice, len(defaultPricing))
+		for version, price := range defaultPricing {
+			pricingTable[version] = price
+		}
+		raw := config.Get().VModelPricing
+		if raw == "" {
+			return
+		}
+		var overrides map[string]VModelPrice
+		if err := json.Unmarshal([]byte(raw), &overrides); err != nil {
+			log.Printf("[WARN] Ignoring invalid VMODEL_PRICING: %v", err)
+			return
+		}
+		for version, price := range overrides {
+			pricingTable[version] = price
+		}
+	})
+	return pricingTable
+}
+
+// SwapEstimate is the expected cost and duration of a face swap
+type SwapEstimate struct {
+	Version        string
+	Duration       float64 // Video duration in seconds
+	Faces          int
+	Credits        float64
+	ProcessingTime time.Duration
+
+	DurationUnknown bool // Priced at the worst case by WorstCaseSwapEstimate
+}
+
+// Estimate prices a swap of a video of the given duration
+func (p VModelPrice) Estimate(duration float64, faces int, enhance bool) (credits float64, processing time.Duration) {
+	if faces < 1 {
+		faces = 1
+	}
+	credits = duration * (p.PerSecond + p.PerExtraFace*float64(faces-1))
+	if enhance && p.EnhanceFactor > 0 {
+		credits *= p.EnhanceFactor
+	}
+	credits = math.Max(math.Ceil(credits*100)/100, p.Minimum)
+
+	seconds := p.ProcessingOverhead + duration*p.ProcessingFactor*float64(faces)
+	return credits, time.Duration(seconds * float64(time.Second))
+}
+
+// EstimateSwap prices a multi-face swap of a stored video
+func EstimateSwap(ctx context.Context, videoKey string, faces int, enhance bool) (*SwapEstimate, error) {
+	version := VModelVideoMultiFaceSwapVersion
+	price, ok := getPricing()[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoPricing, version)
+	}
+
+	meta, err := VideoMetaForKey(ctx, videoKey)
+	if err != nil {
+		return nil, fmt.Errorf("read video metadata: %w", err)
+	}
+	if meta == nil || meta.Duration <= 0 {
+		return nil, ErrDurationUnknown
+	}
+
+	est := &SwapEstimate{Version: version, Duration: meta.Duration, Faces: faces}
+	est.Credits, est.ProcessingTime = price.Estimate(meta.Duration, faces, enhance)
+	return est, nil
+}
+
+// WorstCaseSwapEstimate prices a swap of a video without a known duration as if
+// it ran for VIDEO_MAX_DURATION, the longest video detection accepts. Without
+// that limit there is no worst case and ErrDurationUnknown is returned.
+func WorstCaseSwapEstimate(faces int, enhance bool) (*SwapEstimate, error) {
+	duration := config.Get().VideoMaxDuration.Seconds()
+	if duration <= 0 {
+		return nil, ErrDurationUnknown
+	}
+	version := VModelVideoMultiFaceSwapVersion
+	price, ok := getPricing()[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoPricing, version)
+	}
+
+	est := &SwapEstimate{Version: version, Duration: duration, Faces: faces, DurationUnknown: true}
+	est.Credits, est.ProcessingTime = price.Estimate(duration, faces, enhance)
+	return est, nil
+}
+
+// CreditUsage is a user's credit spend in the current month (Quota 0 = unlimited)
+type CreditUsage struct {
+	Used        float64
+	Quota       float64
+	PeriodStart time.Time
+}
+
+// Remaining returns the credits left under the quota, or -1 when unlimited
+func (u *CreditUsage) Remaining() float64 {
+	if u.Quota <= 0 {
+		return -1
+	}
+	return math.Max(u.Quota-u.Used, 0)
+}
+
+// Allows reports whether the given credits can still be spent under the quota
+func (u *CreditUsage) Allows(credits float64) bool {
+	return u.Quota <= 0 || u.Used+credits <= u.Quota
+}
+
+// GetCreditUsage returns the credits a user has spent this calendar month
+func GetCreditUsage(ctx context.Context, userID int64) (*CreditUsage, error) {
+	now := time.Now()
+	u := &CreditUsage{
+		Quota:       config.Get().CreditQuotaUser,
+		PeriodStart: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()),
+	}
+
+	quota, err := repository.GetCreditQuota(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if quota.Valid {
+		u.Quota = quota.Float64
+	}
+	if u.Used, err = repository.SumUserCredits(ctx, userID, u.PeriodStart); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// CheckCreditQuota rejects a task costing credits that would exceed the user's
+// monthly quota. Accounting failures are logged and let through.
+func CheckCreditQuota(ctx context.Context, userID int64, credits float64) error {
+	usage, err := GetCreditUsage(ctx, userID)
+	if err != nil {
+		log.Printf("[WARN] Failed to check credit quota for user %d: %v", userID, err)
+		return nil
+	}
+	if !usage.Allows(credits) {
+		return fmt.Errorf("%w: %.2f used of %.2f this month", ErrCreditQuotaExceeded, usage.Used, usage.Quota)
+	}
+	return nil
+}
+
+// RecordSwapCredits adds a swap's cost to the ledger; failures are logged since
+// the task already exists at the provider
+func RecordSwapCredits(ctx context.Context, userID int64, taskID string, credits float64, estimated bool) {
+	recordCredits(ctx, userID, taskID, "swap", credits, estimated)
+}
+
+// RecordDetectCredits adds the cost VModel reported for a detection to the ledger
+func RecordDetectCredits(ctx context.Context, userID int64, taskID string, credits float64) {
+	recordCredits(ctx, userID, taskID, "detect", credits, false)
+}
+
+func recordCredits(ctx context.Context, userID int64, taskID, kind string, credits float64, estimated bool) {
+	if credits <= 0 {
+		return
+	}
+	err := repository.AddCreditEntry(ctx, &repository.CreditEntry{
+		UserID:    userID,
+		TaskID:    sql.NullString{String: taskID, Valid: true},
+		Kind:      kind,
+		Credits:   credits,
+		Estimated: estimated,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to record %.2f credits for task %s: %v", credits, taskID, err)
+	}
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVModelPriceEstimate(t *testing.T) {
+	p := VModelPrice{PerSecond: 1, PerExtraFace: 0.5, EnhanceFactor: 2, Minimum: 5, ProcessingFactor: 3, ProcessingOverhead: 30}
+	tests := []struct {
+		duration    float64
+		faces       int
+		enhance     bool
+		wantCredits float64
+		wantTime    time.Duration
+	}{
+		{10, 1, false, 10, 60 * time.Second},
+		{10, 3, false, 20, 120 * time.Second},
+		{10, 1, true, 20, 60 * time.Second},
+		{2, 1, false, 5, 36 * time.Second},             // Minimum charge
+		{1.234, 1, false, 5, 33702 * time.Millisecond}, // Minimum still applies to fractions
+		{10.001, 1, false, 10.01, 60003 * time.Millisecond},
+		{10, 0, false, 10, 60 * time.Second}, // At least one face
+	}
+	for _, tt := range tests {
+		credits, processing := p.Estimate(tt.duration, tt.faces, tt.enhance)
+		if credits != tt.wantCredits || processing != tt.wantTime {
+			t.Errorf("Estimate(%v, %d, %v) = %v, %v; want %v, %v",
+				tt.duration, tt.faces, tt.enhance, credits, processing, tt.wantCredits, tt.wantTime)
+		}
+	}
+}
+
+func TestCreditUsageAllows(t *testing.T) {
+	unlimited := &CreditUsage{Used: 1000}
+	if !unlimited.Allows(500) || unlimited.Remaining() != -1 {
+		t.Error("unlimited usage should allow anything")
+	}
+	limited := &CreditUsage{Used: 90, Quota: 100}
+	if !limited.Allows(10) || limited.Allows(10.5) {
+		t.Error("Allows() doesn't respect the quota")
+	}
+	if limited.Remaining() != 10 {
+		t.Errorf("Remaining() = %v, want 10", limited.Remaining())
+	}
+}
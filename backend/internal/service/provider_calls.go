@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+// maxRecordedResponse caps the response body kept per provider call
+const maxRecordedResponse = 64 << 10
+
+const vmodelTaskEndpoint = "/api/tasks/v1/get/"
+
+// signedQuery matches URL query strings, which carry storage signatures. The
+// character class stops at escaped quotes so nested JSON strings are handled.
+var signedQuery = regexp.MustCompile(`(https?://[^\s"?\\]+)\?[^\s"\\]*`)
+
+// lastPolls remembers the last recorded status response per task, so clients
+// polling an unchanged task don't fill the table
+var lastPolls sync.Map // task ID -> [32]byte
+
+// recordProviderCall saves one VModel request for the admin task view.
+// Failures are logged only; recording never fails the request itself.
+func recordProviderCall(method, endpoint string, attempt int, reqBody []byte, statusCode int, respBody []byte, callErr error, elapsed time.Duration) {
+	if !repository.IsDBAvailable() {
+		return
+	}
+
+	pc := &repository.ProviderCall{
+		Provider:   "vmodel",
+		Method:     method,
+		Endpoint:   endpoint,
+		Attempt:    attempt,
+		DurationMS: elapsed.Milliseconds(),
+	}
+	if statusCode > 0 {
+		pc.StatusCode = sql.NullInt32{Int32: int32(statusCode), Valid: true}
+	}
+	if callErr != nil {
+		pc.Error = nullString(redactSignatures(callErr.Error()))
+	}
+	if len(reqBody) > 0 {
+		pc.Request = []byte(redactSignatures(string(reqBody)))
+	}
+
+	// The task is in the path for status requests and in the response for creates
+	var call struct {
+		Version string `json:"version"`
+	}
+	json.Unmarshal(reqBody, &call)
+	var resp struct {
+		Result struct {
+			TaskID  string `json:"task_id"`
+			Version string `json:"version"`
+		} `json:"result"`
+	}
+	json.Unmarshal(respBody, &resp)
+	taskID := resp.Result.TaskID
+	if strings.HasPrefix(endpoint, vmodelTaskEndpoint) {
+		taskID = strings.TrimPrefix(endpoint, vmodelTaskEndpoint)
+	}
+	pc.TaskID = nullString(taskID)
+	pc.Version = nullString(call.Version)
+	if call.Version == "" {
+		pc.Version = nullString(resp.Result.Version)
+	}
+
+	if method == "GET" && taskID != "" && callErr == nil {
+		sum := sha256.Sum256(respBody)
+		if prev, ok := lastPolls.Load(taskID); ok && prev.([32]byte) == sum {
+			return
+		}
+		lastPolls.Store(taskID, sum)
+	}
+
+	if len(respBody) > 0 {
+		body := respBody
+		if len(body) > maxRecordedResponse {
+			body = body[:maxRecordedResponse]
+		}
+		pc.Response = nullString(strings.ToValidUTF8(redactSignatures(string(body)), string(utf8.RuneError)))
+	}
+
+	if err := repository.SaveProviderCall(context.Background(), pc); err != nil {
+		log.Printf("[WARN] Failed to record VModel call %s %s: %v", method, endpoint, err)
+	}
+}
+
+// redactSignatures drops the query strings of URLs in s
+func redactSignatures(s string) string {
+	return signedQuery.ReplaceAllString(s, "$1?<redacted>")
+}
+
+// CleanupProviderCalls drops recorded calls past the retention period
+func CleanupProviderCalls() {
+	lastPolls.Range(func(key, _ interface{}) bool {
+		lastPolls.Delete(key)
+		return true
+	})
+
+	retention := config.Get().ProviderCallRetention
+	if retention <= 0 {
+		return
+	}
+	n, err := repository.DeleteProviderCallsBefore(context.Background(), time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("[ERROR] Failed to clean up provider calls: %v", err)
+		return
+	}
+	if n > 0 {
+		log.Printf("[INFO] Deleted %d provider calls older than %v", n, retention)
+	}
+}
@@ -0,0 +1,29 @@
+package service
+
+import "testing"
+
+func TestRedactSignatures(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{
+			`{"target":"https://oss.example.com/a.mp4?Expires=1&Signature=abc"}`,
+			`{"target":"https://oss.example.com/a.mp4?<redacted>"}`,
+		},
+		{
+			`{"output":"{\"url\":\"https://cdn.vmodel.ai/r.mp4?token=x\"}"}`,
+			`{"output":"{\"url\":\"https://cdn.vmodel.ai/r.mp4?<redacted>\"}"}`,
+		},
+		{
+			`Get "https://oss.example.com/b.jpg?sig=1": timeout`,
+			`Get "https://oss.example.com/b.jpg?<redacted>": timeout`,
+		},
+		{`{"url":"https://vmodel.ai/data/a.mp4"}`, `{"url":"https://vmodel.ai/data/a.mp4"}`},
+	}
+	for _, tt := range tests {
+		if got := redactSignatures(tt.in); got != tt.want {
+			t.Errorf("redactSignatures(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"path"
+	"strings"
+	"unicode"
+
+	"playplus_platform/internal/repository"
+)
+
+// maxFilenameBase keeps generated download names readable
+const maxFilenameBase = 80
+
+// ResultFilename names a task's result for downloads after the video it was
+// made from, e.g. "新品展示_faceswap_20240115-1432.mp4", instead of the
+// random storage key.
+func ResultFilename(ctx context.Context, task *repository.SwapTask) string {
+	base := "faceswap"
+	if original := originalFilename(ctx, task); original != "" {
+		if name := sanitizeFilename(strings.TrimSuffix(original, path.Ext(original))); name != "" {
+			base = name + "_faceswap"
+		}
+	}
+
+	ext := path.Ext(task.ResultKey.String)
+	if ext == "" {
+		ext = ".mp4"
+	}
+	return base + "_" + task.CreatedAt.Format("20060102-1504") + ext
+}
+
+// originalFilename returns the upload filename of the task's target video
+func originalFilename(ctx context.Context, task *repository.SwapTask) string {
+	var media *repository.MediaFile
+	if task.MediaID != "" {
+		media, _ = repository.GetMediaFile(ctx, task.MediaID)
+	}
+	if media == nil && task.TargetVideoKey.Valid {
+		media, _ = repository.GetMediaFileByKey(ctx, task.TargetVideoKey.String)
+	}
+	if media == nil {
+		return ""
+	}
+	return media.Filename
+}
+
+// sanitizeFilename drops path separators, quotes and control characters and
+// collapses whitespace, keeping non-ASCII letters intact
+func sanitizeFilename(name string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range name {
+		switch {
+		case r == '/' || r == '\\' || r == '"' || r == ':' || r == '*' || r == '?' || r == '<' || r == '>' || r == '|':
+			continue
+		case unicode.IsSpace(r):
+			space = true
+			continue
+		case unicode.IsControl(r):
+			continue
+		}
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteRune(r)
+	}
+
+	name = strings.Trim(b.String(), ". ")
+	if runes := []rune(name); len(runes) > maxFilenameBase {
+		name = string(runes[:maxFilenameBase])
+	}
+	return name
+}
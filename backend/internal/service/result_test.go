@@ -0,0 +1,21 @@
+package service
+
+import "testing"
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"product demo", "product demo"},
+		{"新品展示", "新品展示"},
+		{"../../etc/passwd", "etcpasswd"},
+		{`a"b\c`, "abc"},
+		{"  tabs\tand\nnewlines  ", "tabs and newlines"},
+		{"...", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeFilename(tt.in); got != tt.want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
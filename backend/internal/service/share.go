@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+// shareTokenBytes of randomness make share tokens unguessable
+const shareTokenBytes = 32
+
+// ShareAccessTTL is how long one counted access to a share link covers the
+// range requests that follow it
+const ShareAccessTTL = 30 * time.Minute
+
+var (
+	shareSigningKey     []byte
+	shareSigningKeyOnce sync.Once
+)
+
+var (
+	ErrShareNotFound    = errors.New("share link not found")
+	ErrShareUnavailable = errors.New("share link expired, revoked or used up")
+	ErrInvalidShare     = errors.New("invalid share options")
+)
+
+// ShareOptions configures a new share link
+type ShareOptions struct {
+	ExpiresIn    time.Duration // 0 uses the configured default
+	Password     string        // Optional
+	MaxDownloads int           // Views and downloads combined, 0 = unlimited
+}
+
+// CreateShareLink creates a share link for a completed task and returns it with
+// its token. Only a hash of the token is stored: it cannot be shown again.
+func CreateShareLink(ctx context.Context, task *repository.SwapTask, opts ShareOptions) (*repository.ShareLink, string, error) {
+	cfg := config.Get()
+	if opts.ExpiresIn <= 0 {
+		opts.ExpiresIn = cfg.ShareDefaultExpiry
+	}
+	if opts.ExpiresIn > cfg.ShareMaxExpiry {
+		return nil, "", fmt.Errorf("%w: expiry cannot exceed %s", ErrInvalidShare, cfg.ShareMaxExpiry)
+	}
+	if opts.MaxDownloads < 0 {
+		return nil, "", fmt.Errorf("%w: max_downloads cannot be negative", ErrInvalidShare)
+	}
+
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	link := &repository.ShareLink{
+		TokenHash:    hashShareToken(token),
+		UserID:       task.UserID,
+		TaskID:       task.TaskID,
+		MaxDownloads: sql.NullInt32{Int32: int32(opts.MaxDownloads), Valid: opts.MaxDownloads > 0},
+		ExpiresAt:    time.Now().Add(opts.ExpiresIn),
+	}
+	if opts.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(opts.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, "", err
+		}
+		link.PasswordHash = sql.NullString{String: string(hash), Valid: true}
+	}
+
+	if err := repository.CreateShareLink(ctx, link); err != nil {
+		return nil, "", err
+	}
+	return link, token, nil
+}
+
+// LookupShareLink resolves a token to a share link that is neither revoked nor expired
+func LookupShareLink(ctx context.Context, token string) (*repository.ShareLink, error) {
+	link, err := repository.GetShareLinkByTokenHash(ctx, hashShareToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return nil, ErrShareNotFound
+	}
+	// The download limit is enforced per access by repository.ClaimShareAccess,
+	// so range requests covered by ShareAccessCookie still go through
+	if link.RevokedAt.Valid || time.Now().After(link.ExpiresAt) {
+		return nil, ErrShareUnavailable
+	}
+	return link, nil
+}
+
+// ShareLinkActive reports whether a share link can still be used
+func ShareLinkActive(link *repository.ShareLink) bool {
+	if link.RevokedAt.Valid || time.Now().After(link.ExpiresAt) {
+		return false
+	}
+	return !link.MaxDownloads.Valid || link.ViewCount+link.DownloadCount < int(link.MaxDownloads.Int32)
+}
+
+// CheckSharePassword verifies the password of a protected share link
+func CheckSharePassword(link *repository.ShareLink, password string) bool {
+	if !link.PasswordHash.Valid {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(link.PasswordHash.String), []byte(password)) == nil
+}
+
+// ShareAuthCookie is the cookie value proving the password of a share link was
+// entered. It is derived from the token and the stored hash, so it stops working
+// when either changes, without keeping server-side sessions for anonymous visitors.
+func ShareAuthCookie(token string, link *repository.ShareLink) string {
+	sum := sha256.Sum256([]byte(token + "\x00" + link.PasswordHash.String))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckShareAuthCookie verifies a value produced by ShareAuthCookie
+func CheckShareAuthCookie(token string, link *repository.ShareLink, value string) bool {
+	return subtle.ConstantTimeCompare([]byte(value), []byte(ShareAuthCookie(token, link))) == 1
+}
+
+// ShareAccessCookie is the cookie value given to a visitor whose access to a
+// share link was counted. It expires after ShareAccessTTL and is signed with a
+// server key, so visitors cannot extend or forge it.
+func ShareAccessCookie(link *repository.ShareLink) string {
+	expires := strconv.FormatInt(time.Now().Add(ShareAccessTTL).Unix(), 10)
+	return expires + "." + signShareAccess(link, expires)
+}
+
+// CheckShareAccessCookie verifies an unexpired value produced by ShareAccessCookie
+func CheckShareAccessCookie(link *repository.ShareLink, value string) bool {
+	expires, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() >= unix {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(signShareAccess(link, expires)))
+}
+
+func signShareAccess(link *repository.ShareLink, expires string) string {
+	shareSigningKeyOnce.Do(func() {
+		if key := config.Get().ShareSigningKey; key != "" {
+			shareSigningKey = []byte(key)
+			return
+		}
+		// Cookies then only survive until a restart, which costs visitors one more count
+		shareSigningKey = make([]byte, 32)
+		if _, err := rand.Read(shareSigningKey); err != nil {
+			panic(err)
+		}
+	})
+	mac := hmac.New(sha256.New, shareSigningKey)
+	mac.Write([]byte(strconv.FormatInt(link.ID, 10) + "\x00" + link.TokenHash + "\x00" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
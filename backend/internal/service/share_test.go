@@ -0,0 +1,89 @@
+package service
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"playplus_platform/internal/repository"
+)
+
+func TestShareLinkActive(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	tests := []struct {
+		name string
+		link repository.ShareLink
+		want bool
+	}{
+		{"open", repository.ShareLink{ExpiresAt: future}, true},
+		{"expired", repository.ShareLink{ExpiresAt: time.Now().Add(-time.Second)}, false},
+		{"revoked", repository.ShareLink{ExpiresAt: future, RevokedAt: sql.NullTime{Time: time.Now(), Valid: true}}, false},
+		{"under limit", repository.ShareLink{ExpiresAt: future, MaxDownloads: sql.NullInt32{Int32: 3, Valid: true}, ViewCount: 1, DownloadCount: 1}, true},
+		{"limit reached", repository.ShareLink{ExpiresAt: future, MaxDownloads: sql.NullInt32{Int32: 2, Valid: true}, ViewCount: 1, DownloadCount: 1}, false},
+	}
+	for _, tt := range tests {
+		if got := ShareLinkActive(&tt.link); got != tt.want {
+			t.Errorf("%s: ShareLinkActive() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSharePassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := &repository.ShareLink{PasswordHash: sql.NullString{String: string(hash), Valid: true}}
+
+	if !CheckSharePassword(link, "secret") {
+		t.Error("correct password rejected")
+	}
+	if CheckSharePassword(link, "guess") {
+		t.Error("wrong password accepted")
+	}
+	if !CheckSharePassword(&repository.ShareLink{}, "") {
+		t.Error("link without password rejected")
+	}
+
+	cookie := ShareAuthCookie("token-a", link)
+	if !CheckShareAuthCookie("token-a", link, cookie) {
+		t.Error("cookie rejected for its own token")
+	}
+	if CheckShareAuthCookie("token-b", link, cookie) {
+		t.Error("cookie accepted for another token")
+	}
+	changed := &repository.ShareLink{PasswordHash: sql.NullString{String: string(hash) + "x", Valid: true}}
+	if CheckShareAuthCookie("token-a", changed, cookie) {
+		t.Error("cookie accepted after the password changed")
+	}
+}
+
+func TestShareAccessCookie(t *testing.T) {
+	link := &repository.ShareLink{ID: 1, TokenHash: hashShareToken("token-a")}
+	other := &repository.ShareLink{ID: 2, TokenHash: hashShareToken("token-b")}
+
+	cookie := ShareAccessCookie(link)
+	if !CheckShareAccessCookie(link, cookie) {
+		t.Error("access cookie rejected for its own link")
+	}
+	if CheckShareAccessCookie(other, cookie) {
+		t.Error("access cookie accepted for another link")
+	}
+
+	// Extending the expiry invalidates the signature
+	expires, sig, _ := strings.Cut(cookie, ".")
+	unix, _ := strconv.ParseInt(expires, 10, 64)
+	if CheckShareAccessCookie(link, strconv.FormatInt(unix+3600, 10)+"."+sig) {
+		t.Error("access cookie with an extended expiry accepted")
+	}
+	past := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	if CheckShareAccessCookie(link, past+"."+signShareAccess(link, past)) {
+		t.Error("expired access cookie accepted")
+	}
+	if CheckShareAccessCookie(link, "") {
+		t.Error("empty access cookie accepted")
+	}
+}
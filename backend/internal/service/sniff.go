@@ -0,0 +1,165 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"strings"
+)
+
+// SniffLen is how many leading bytes SniffContentType needs to see
+const SniffLen = 512
+
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported file type")
+	ErrMediaTypeMismatch    = errors.New("file content does not match its declared type")
+)
+
+// contentTypeAliases maps non-canonical MIME types clients send to the ones we store
+var contentTypeAliases = map[string]string{
+	"image/jpg":       "image/jpeg",
+	"image/pjpeg":     "image/jpeg",
+	"video/avi":       "video/x-msvideo",
+	"video/msvideo":   "video/x-msvideo",
+	"video/x-m4v":     "video/mp4",
+	"application/mp4": "video/mp4",
+}
+
+// contentTypeExtensions is the file extension of each type SniffContentType detects
+var contentTypeExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"video/mp4":       ".mp4",
+	"video/quicktime": ".mov",
+	"video/webm":      ".webm",
+	"video/x-msvideo": ".avi",
+}
+
+// ISO-BMFF brands that share the ftyp layout but are not videos we accept
+var imageBrands = map[string]bool{
+	"heic": true, "heix": true, "hevc": true, "heim": true, "heis": true,
+	"mif1": true, "msf1": true, "avif": true, "avis": true,
+}
+
+// SniffContentType detects the media type from a file's leading bytes.
+// It returns "" for anything other than JPEG/PNG/WebP/GIF/MP4/MOV/WebM/AVI.
+func SniffContentType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return "image/jpeg"
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return "image/png"
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return "image/gif"
+	case len(head) >= 12 && bytes.HasPrefix(head, []byte("RIFF")):
+		switch string(head[8:12]) {
+		case "WEBP":
+			return "image/webp"
+		case "AVI ":
+			return "video/x-msvideo"
+		}
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		if ebmlDocType(head) == "webm" {
+			return "video/webm"
+		}
+	case len(head) >= 12:
+		return sniffISOBMFF(head)
+	}
+	return ""
+}
+
+// sniffISOBMFF recognizes MP4 (ftyp box) and QuickTime files, including
+// older MOVs that start directly with a moov/mdat/wide atom.
+func sniffISOBMFF(head []byte) string {
+	boxType := string(head[4:8])
+	if boxType == "ftyp" {
+		brand := string(head[8:12])
+		switch {
+		case brand == "qt  ":
+			return "video/quicktime"
+		case imageBrands[brand]:
+			return ""
+		}
+		return "video/mp4"
+	}
+	switch boxType {
+	case "moov", "mdat", "wide", "free", "skip", "pnot":
+		return "video/quicktime"
+	}
+	return ""
+}
+
+// ebmlDocType extracts the DocType string (e.g. "webm", "matroska") from an EBML header
+func ebmlDocType(head []byte) string {
+	i := bytes.Index(head, []byte{0x42, 0x82})
+	if i < 0 || i+2 >= len(head) {
+		return ""
+	}
+	size, n := readVint(head[i+2:])
+	start := i + 2 + n
+	if n == 0 || size <= 0 || start+size > len(head) {
+		return ""
+	}
+	return string(bytes.TrimRight(head[start:start+size], "\x00"))
+}
+
+// readVint decodes an EBML variable-length integer, returning the value and its width.
+// Values up to 8 bytes wide are supported; n is 0 when the input is malformed.
+func readVint(b []byte) (value int, n int) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0
+	}
+	mask := byte(0x80)
+	for n = 1; b[0]&mask == 0; n++ {
+		mask >>= 1
+	}
+	if len(b) < n {
+		return 0, 0
+	}
+	value = int(b[0] & (mask - 1))
+	for _, c := range b[1:n] {
+		value = value<<8 | int(c)
+	}
+	return value, n
+}
+
+// NormalizeContentType strips parameters and maps aliases to canonical MIME types
+func NormalizeContentType(contentType string) string {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if canonical, ok := contentTypeAliases[contentType]; ok {
+		return canonical
+	}
+	return contentType
+}
+
+// CheckMediaType sniffs the content and validates it against the type the client
+// declared and the kinds of file the category accepts. It returns the detected type.
+func CheckMediaType(head []byte, claimed, category string) (string, error) {
+	detected := SniffContentType(head)
+	if detected == "" {
+		return "", ErrUnsupportedMediaType
+	}
+	if category != MediaCategoryMedia && !strings.HasPrefix(detected, "image/") {
+		return "", ErrUnsupportedMediaType
+	}
+	if !contentTypesCompatible(NormalizeContentType(claimed), detected) {
+		return "", ErrMediaTypeMismatch
+	}
+	return detected, nil
+}
+
+// contentTypesCompatible reports whether a declared type can describe the detected one.
+// Missing or generic declarations are accepted; MP4 and MOV are interchangeable
+// because browsers and cameras label the two inconsistently.
+func contentTypesCompatible(claimed, detected string) bool {
+	if claimed == "" || claimed == "application/octet-stream" || claimed == detected {
+		return true
+	}
+	isMP4Family := func(t string) bool { return t == "video/mp4" || t == "video/quicktime" }
+	return isMP4Family(claimed) && isMP4Family(detected)
+}
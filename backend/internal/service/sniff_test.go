@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSniffContentType(t *testing.T) {
+	webmHeader := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x86, 0x81, 0x01, 0x42, 0x82, 0x84, 'w', 'e', 'b', 'm'}
+	mkvHeader := []byte{0x1A, 0x45, 0xDF, 0xA3, 0xA3, 0x42, 0x82, 0x88, 'm', 'a', 't', 'r', 'o', 's', 'k', 'a'}
+
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10, 'J', 'F', 'I', 'F'}, "image/jpeg"},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png"},
+		{"gif", []byte("GIF89a\x01\x00\x01\x00"), "image/gif"},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), "image/webp"},
+		{"avi", []byte("RIFF\x24\x00\x00\x00AVI LIST"), "video/x-msvideo"},
+		{"wav", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), ""},
+		{"mp4", []byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00"), "video/mp4"},
+		{"mov ftyp", []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00"), "video/quicktime"},
+		{"mov legacy", []byte("\x00\x00\x00\x08wide\x00\x00\x00\x00mdat"), "video/quicktime"},
+		{"heic", []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00"), ""},
+		{"webm", webmHeader, "video/webm"},
+		{"matroska", mkvHeader, ""},
+		{"text", []byte("<html><body>hello</body></html>"), ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := SniffContentType(tt.head); got != tt.want {
+			t.Errorf("%s: SniffContentType() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckMediaType(t *testing.T) {
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE0}
+	mp4 := []byte("\x00\x00\x00\x20ftypisom\x00\x00\x02\x00")
+
+	tests := []struct {
+		name     string
+		head     []byte
+		claimed  string
+		category string
+		want     string
+		err      error
+	}{
+		{"matching", jpeg, "image/jpeg", MediaCategoryFace, "image/jpeg", nil},
+		{"alias", jpeg, "image/jpg", MediaCategoryFace, "image/jpeg", nil},
+		{"undeclared", jpeg, "application/octet-stream", MediaCategoryFrame, "image/jpeg", nil},
+		{"mov labelled mp4", mp4, "video/quicktime", MediaCategoryMedia, "video/mp4", nil},
+		{"mismatch", jpeg, "video/mp4", MediaCategoryMedia, "", ErrMediaTypeMismatch},
+		{"video as face", mp4, "video/mp4", MediaCategoryFace, "", ErrUnsupportedMediaType},
+		{"unknown", []byte("#!/bin/sh"), "image/png", MediaCategoryMedia, "", ErrUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		got, err := CheckMediaType(tt.head, tt.claimed, tt.category)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("%s: CheckMediaType() = %q, %v; want %q, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+}
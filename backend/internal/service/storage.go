@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -18,6 +19,7 @@ import (
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
 )
 
 // StorageService handles file storage operations
@@ -66,29 +68,9 @@ func GetStorageService() *StorageService {
 
 // initMinioClient initializes the MinIO client
 func (s *StorageService) initMinioClient() error {
-	// Parse endpoint - remove protocol prefix
-	endpoint := s.cfg.StorageEndpoint
-	useSSL := true
-
-	if strings.HasPrefix(endpoint, "https://") {
-		endpoint = strings.TrimPrefix(endpoint, "https://")
-		useSSL = true
-	} else if strings.HasPrefix(endpoint, "http://") {
-		endpoint = strings.TrimPrefix(endpoint, "http://")
-		useSSL = false
-	}
-
-	// Remove trailing slash
-	endpoint = strings.TrimSuffix(endpoint, "/")
-
-	// Create MinIO client
-	client, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(s.cfg.StorageAccessKey, s.cfg.StorageSecretKey, ""),
-		Secure: useSSL,
-		Region: s.cfg.StorageRegion,
-	})
+	client, err := newMinioClient(s.cfg.StorageEndpoint, s.cfg.StorageAccessKey, s.cfg.StorageSecretKey, s.cfg.StorageRegion)
 	if err != nil {
-		return fmt.Errorf("create minio client: %w", err)
+		return err
 	}
 
 	s.minioClient = client
@@ -112,7 +94,17 @@ func (s *StorageService) initMinioClient() error {
 		log.Printf("Created bucket: %s", s.bucketName)
 	}
 
-	// Always ensure bucket has public read policy (for VModel API to access uploaded files)
+	// Private mode: drop any public policy, all access goes through presigned URLs
+	if s.cfg.StoragePrivate {
+		if err := client.SetBucketPolicy(ctx, s.bucketName, ""); err != nil {
+			log.Printf("Warning: Failed to remove bucket policy: %v", err)
+		} else {
+			log.Printf("Bucket %s is private, serving presigned URLs (expiry %v)", s.bucketName, s.cfg.StorageURLExpiry)
+		}
+		return nil
+	}
+
+	// Public mode: ensure bucket has public read policy (for VModel API to access uploaded files)
 	policy := fmt.Sprintf(`{
 		"Version": "2012-10-17",
 		"Statement": [{
@@ -133,6 +125,34 @@ func (s *StorageService) initMinioClient() error {
 	return nil
 }
 
+// newMinioClient creates a client for an S3-compatible endpoint ("https://host" or "http://host")
+func newMinioClient(endpoint, accessKey, secretKey, region string) (*minio.Client, error) {
+	// Parse endpoint - remove protocol prefix
+	useSSL := true
+
+	if strings.HasPrefix(endpoint, "https://") {
+		endpoint = strings.TrimPrefix(endpoint, "https://")
+		useSSL = true
+	} else if strings.HasPrefix(endpoint, "http://") {
+		endpoint = strings.TrimPrefix(endpoint, "http://")
+		useSSL = false
+	}
+
+	// Remove trailing slash
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	// Create MinIO client
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create minio client: %w", err)
+	}
+	return client, nil
+}
+
 // GenerateKey generates a unique storage key for a file
 func (s *StorageService) GenerateKey(prefix, filename string) string {
 	b := make([]byte, 8)
@@ -184,8 +204,7 @@ func (s *StorageService) uploadToMinio(ctx context.Context, key string, content
 		return "", fmt.Errorf("minio upload: %w", err)
 	}
 
-	// Return the public URL
-	return s.GetPublicURL(key), nil
+	return s.GetAccessURL(ctx, key)
 }
 
 // uploadToLocal saves file to local filesystem (development fallback)
@@ -207,10 +226,10 @@ func (s *StorageService) uploadToLocal(key string, content []byte) (string, erro
 	return fmt.Sprintf("/uploads/%s", key), nil
 }
 
-// UploadFromURL downloads a file from URL and uploads to storage
-func (s *StorageService) UploadFromURL(ctx context.Context, sourceURL, key string) (string, error) {
-	// Use a simple HTTP client for downloading
-	resp, err := s.cfg.HTTPClient().Get(sourceURL)
+// UploadFromURL downloads a file allowed by policy and uploads it to storage.
+// Bodies with a known length are streamed, others are buffered up to the policy's size cap.
+func (s *StorageService) UploadFromURL(ctx context.Context, policy *URLPolicy, sourceURL, key string) (string, error) {
+	resp, err := policy.Get(ctx, sourceURL)
 	if err != nil {
 		return "", fmt.Errorf("download failed: %w", err)
 	}
@@ -220,16 +239,22 @@ func (s *StorageService) UploadFromURL(ctx context.Context, sourceURL, key strin
 		return "", fmt.Errorf("download error: %d", resp.StatusCode)
 	}
 
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("read download: %w", err)
-	}
-
 	contentType := resp.Header.Get("Content-Type")
 	if contentType == "" {
 		contentType = "application/octet-stream"
 	}
 
+	if resp.ContentLength >= 0 {
+		if err := s.UploadStream(ctx, key, resp.Body, resp.ContentLength, contentType); err != nil {
+			return "", err
+		}
+		return s.GetAccessURL(ctx, key)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("read download: %w", err)
+	}
 	return s.UploadBytes(ctx, key, content, contentType)
 }
 
@@ -246,8 +271,53 @@ func (s *StorageService) Delete(ctx context.Context, key string) error {
 		return s.minioClient.RemoveObject(ctx, s.bucketName, key, minio.RemoveObjectOptions{})
 	}
 
-	// Local delete
-	return os.Remove(s.GetLocalPath(key))
+	// Local delete; a missing file is not an error, same as S3
+	if err := os.Remove(s.GetLocalPath(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// ErrObjectNotFound is returned when a key does not exist in storage
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectReader is a seekable stream over a stored object
+type ObjectReader interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+}
+
+// Open opens a stored object for reading and returns its size
+func (s *StorageService) Open(ctx context.Context, key string) (ObjectReader, int64, error) {
+	if s.minioClient != nil {
+		obj, err := s.minioClient.GetObject(ctx, s.bucketName, key, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, 0, fmt.Errorf("minio get: %w", err)
+		}
+		stat, err := obj.Stat()
+		if err != nil {
+			obj.Close()
+			if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+				return nil, 0, ErrObjectNotFound
+			}
+			return nil, 0, fmt.Errorf("minio stat: %w", err)
+		}
+		return obj, stat.Size, nil
+	}
+
+	f, err := os.Open(s.GetLocalPath(key))
+	if os.IsNotExist(err) {
+		return nil, 0, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
 }
 
 // IsConfigured returns true if storage is properly configured
@@ -297,30 +367,6 @@ func (s *StorageService) GetDirectURL(key string) string {
 	return fmt.Sprintf("/uploads/%s", key)
 }
 
-// ConvertToDirectURL converts a CDN URL to direct storage URL for external API access
-func (s *StorageService) ConvertToDirectURL(cdnURL string) string {
-	publicURL := s.cfg.StoragePublicURL
-	directURL := s.cfg.StorageDirectURL
-
-	// If no direct URL configured, return original
-	if directURL == "" {
-		return cdnURL
-	}
-
-	// If URL contains CDN domain, convert to direct URL
-	if publicURL != "" && strings.Contains(cdnURL, publicURL) {
-		// Extract key from CDN URL
-		// e.g., https://cdn.playerplus.cn/playerplus-media/videos/xxx.mp4 -> videos/xxx.mp4
-		bucketPrefix := "/" + s.bucketName + "/"
-		if idx := strings.Index(cdnURL, bucketPrefix); idx != -1 {
-			key := cdnURL[idx+len(bucketPrefix):]
-			return s.GetDirectURL(key)
-		}
-	}
-
-	return cdnURL
-}
-
 // GetPresignedURL returns a presigned URL for temporary access
 func (s *StorageService) GetPresignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
 	if s.minioClient == nil {
@@ -339,7 +385,8 @@ func (s *StorageService) GetPresignedURL(ctx context.Context, key string, expiry
 
 type TransferStatus struct {
 	Status   string // pending, completed, failed
-	MinioURL string
+	Key      string // Storage key of the transferred result
+	MinioURL string // Resolved on read, so presigned URLs are always fresh
 	Error    string
 }
 
@@ -387,6 +434,9 @@ func (s *StorageService) TransferFromVModel(taskID, vmodelURL string) {
 		TransferStatus: TransferStatus{Status: "pending"},
 		CreatedAt:      time.Now(),
 	})
+	if err := repository.SetSwapTaskTransfer(context.Background(), taskID, "pending", ""); err != nil {
+		log.Printf("Failed to record transfer start for task %s: %v", taskID, err)
+	}
 
 	// Start transfer in goroutine
 	go s.doTransfer(taskID, vmodelURL)
@@ -402,23 +452,34 @@ func (s *StorageService) doTransfer(taskID, vmodelURL string) {
 				TransferStatus: TransferStatus{Status: "failed", Error: fmt.Sprintf("panic: %v", r)},
 				CreatedAt:      time.Now(),
 			})
+			repository.SetSwapTaskTransfer(context.Background(), taskID, "failed", fmt.Sprintf("panic: %v", r))
 		}
 		// Clean up the per-task lock after transfer completes
 		transferLocks.Delete(taskID)
 	}()
 
 	key := s.GenerateKey("results", taskID+".mp4")
-	url, err := s.UploadFromURL(context.Background(), vmodelURL, key)
+	_, err := s.UploadFromURL(context.Background(), TransferURLPolicy(), vmodelURL, key)
 	if err != nil {
 		log.Printf("Failed to transfer video for task %s: %v", taskID, err)
 		transferCache.Store(taskID, TransferEntry{
 			TransferStatus: TransferStatus{Status: "failed", Error: err.Error()},
 			CreatedAt:      time.Now(),
 		})
+		if err := repository.SetSwapTaskTransfer(context.Background(), taskID, "failed", redactSignatures(err.Error())); err != nil {
+			log.Printf("Failed to record transfer failure for task %s: %v", taskID, err)
+		}
 		return
 	}
+	var size int64
+	if info, err := s.Stat(context.Background(), key); err == nil {
+		size = info.Size // Counted towards the task owner's storage usage
+	}
+	if err := repository.SetSwapTaskResultKey(context.Background(), taskID, key, s.BackendID(), size); err != nil {
+		log.Printf("Failed to record result key for task %s: %v", taskID, err)
+	}
 	transferCache.Store(taskID, TransferEntry{
-		TransferStatus: TransferStatus{Status: "completed", MinioURL: url},
+		TransferStatus: TransferStatus{Status: "completed", Key: key},
 		CreatedAt:      time.Now(),
 	})
 	log.Printf("Successfully transferred video for task %s to MinIO", taskID)
@@ -426,11 +487,8 @@ func (s *StorageService) doTransfer(taskID, vmodelURL string) {
 
 // GetTransferredURL returns the MinIO URL if transfer is completed
 func (s *StorageService) GetTransferredURL(taskID string) string {
-	if val, ok := transferCache.Load(taskID); ok {
-		entry := val.(TransferEntry)
-		if entry.Status == "completed" {
-			return entry.MinioURL
-		}
+	if ts := s.GetTransferStatus(taskID); ts != nil && ts.Status == "completed" {
+		return ts.MinioURL
 	}
 	return ""
 }
@@ -448,7 +506,15 @@ func (s *StorageService) IsTransferring(taskID string) bool {
 func (s *StorageService) GetTransferStatus(taskID string) *TransferStatus {
 	if val, ok := transferCache.Load(taskID); ok {
 		entry := val.(TransferEntry)
-		return &entry.TransferStatus
+		ts := entry.TransferStatus
+		if ts.Status == "completed" {
+			url, err := s.GetAccessURL(context.Background(), ts.Key)
+			if err != nil {
+				log.Printf("Failed to resolve URL for transferred task %s: %v", taskID, err)
+			}
+			ts.MinioURL = url
+		}
+		return &ts
 	}
 	return nil
 }
@@ -473,6 +539,9 @@ func StartCacheCleanupJob() {
 		defer ticker.Stop()
 		for range ticker.C {
 			CleanupExpiredCache()
+			GetTusService().CleanupExpiredUploads()
+			CleanupMediaImports()
+			CleanupProviderCalls()
 		}
 	}()
 }
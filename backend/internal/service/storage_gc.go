@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+// Storage GC modes
+const (
+	GCModeQuarantine = "quarantine" // Move orphans under quarantine/, purge them later
+	GCModeDelete     = "delete"     // Delete orphans right away
+)
+
+// QuarantinePrefix holds objects the GC considered orphaned, under their original key
+const QuarantinePrefix = "quarantine/"
+
+// ErrGCRunning is returned when a GC run is requested while one is in progress
+var ErrGCRunning = errors.New("storage GC already running")
+
+var gcRunning atomic.Bool
+
+// StorageGC finds objects no DB record references and deletes or quarantines them.
+// Frame uploads and unused face uploads are first dropped from the media library
+// once their retention passes, so their objects become orphans in the same run.
+type StorageGC struct {
+	Mode                string
+	DryRun              bool          // Report what would be collected, change nothing
+	GracePeriod         time.Duration // Younger objects may not have their DB record yet
+	QuarantineRetention time.Duration
+	FrameRetention      time.Duration // 0 keeps frames
+	FaceRetention       time.Duration // 0 keeps faces
+}
+
+// NewStorageGC returns a GC configured from the environment
+func NewStorageGC() *StorageGC {
+	cfg := config.Get()
+	return &StorageGC{
+		Mode:                cfg.GCMode,
+		GracePeriod:         cfg.GCGracePeriod,
+		QuarantineRetention: cfg.GCQuarantineRetention,
+		FrameRetention:      cfg.GCFrameRetention,
+		FaceRetention:       cfg.GCFaceRetention,
+	}
+}
+
+// IsStorageGCRunning returns true while a GC run is in progress
+func IsStorageGCRunning() bool {
+	return gcRunning.Load()
+}
+
+// Run collects garbage and records the run report
+func (g *StorageGC) Run(ctx context.Context) (*repository.GCRun, error) {
+	if err := g.acquire(); err != nil {
+		return nil, err
+	}
+	defer gcRunning.Store(false)
+	return g.execute(ctx)
+}
+
+// Start runs the GC in the background; the report is recorded when it finishes
+func (g *StorageGC) Start() error {
+	if err := g.acquire(); err != nil {
+		return err
+	}
+	go func() {
+		defer gcRunning.Store(false)
+		g.execute(context.Background())
+	}()
+	return nil
+}
+
+func (g *StorageGC) acquire() error {
+	if g.Mode != GCModeQuarantine && g.Mode != GCModeDelete {
+		return fmt.Errorf("unknown GC mode %q", g.Mode)
+	}
+	// Without the DB every object would look orphaned
+	if !repository.IsDBAvailable() {
+		return fmt.Errorf("storage GC requires the database")
+	}
+	if !gcRunning.CompareAndSwap(false, true) {
+		return ErrGCRunning
+	}
+	return nil
+}
+
+func (g *StorageGC) execute(ctx context.Context) (*repository.GCRun, error) {
+	run := &repository.GCRun{
+		Mode:      g.Mode,
+		DryRun:    g.DryRun,
+		Prefixes:  make(map[string]*repository.GCPrefixStats),
+		StartedAt: time.Now(),
+	}
+
+	err := g.collect(ctx, run)
+	if err != nil {
+		log.Printf("[ERROR] Storage GC failed: %v", err)
+		run.ErrorMessage = sql.NullString{String: err.Error(), Valid: true}
+	}
+	run.FinishedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	log.Printf("[INFO] Storage GC (%s, dry run %v): scanned %d objects, %d orphaned (%d bytes), %d quarantined, %d deleted, %d bytes reclaimed, %d failures",
+		run.Mode, run.DryRun, run.ScannedObjects, run.OrphanedObjects, run.OrphanedBytes,
+		run.QuarantinedObjects, run.DeletedObjects, run.ReclaimedBytes, run.Failures)
+
+	if saveErr := repository.SaveGCRun(context.Background(), run); saveErr != nil {
+		log.Printf("[ERROR] Failed to save storage GC report: %v", saveErr)
+	}
+	return run, err
+}
+
+func (g *StorageGC) collect(ctx context.Context, run *repository.GCRun) error {
+	refs, mediaIDs, err := repository.StorageReferences(ctx)
+	if err != nil {
+		return fmt.Errorf("load references: %w", err)
+	}
+
+	// References are only released once the library record is really gone
+	for _, m := range g.expiredMedia(ctx, run) {
+		if !g.DryRun {
+			if _, err := repository.DeleteMediaFile(ctx, m.MediaID); err != nil {
+				log.Printf("[WARN] Storage GC failed to expire media %s: %v", m.MediaID, err)
+				run.Failures++
+				continue
+			}
+		}
+		for _, k := range []sql.NullString{m.StorageKey, m.ThumbnailKey, m.NormalizedKey} {
+			if k.Valid {
+				if refs[k.String]--; refs[k.String] <= 0 {
+					delete(refs, k.String)
+				}
+			}
+		}
+		delete(mediaIDs, m.MediaID)
+		run.ExpiredMedia++
+	}
+
+	storage := GetStorageService()
+	cutoff := time.Now().Add(-g.GracePeriod)
+	for _, prefix := range keyPrefixes {
+		stats := &repository.GCPrefixStats{}
+		run.Prefixes[strings.TrimSuffix(prefix, "/")] = stats
+
+		err := storage.List(ctx, prefix, func(obj ObjectInfo) error {
+			stats.ScannedObjects++
+			stats.ScannedBytes += obj.Size
+			run.ScannedObjects++
+			run.ScannedBytes += obj.Size
+			if obj.LastModified.After(cutoff) || isReferencedKey(obj.Key, refs, mediaIDs) {
+				return nil
+			}
+
+			stats.OrphanedObjects++
+			stats.OrphanedBytes += obj.Size
+			run.OrphanedObjects++
+			run.OrphanedBytes += obj.Size
+			if !g.DryRun {
+				g.dispose(ctx, run, obj)
+			}
+			return ctx.Err()
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Printf("[WARN] Storage GC failed to list %s: %v", prefix, err)
+			run.Failures++
+		}
+	}
+
+	return g.purgeQuarantine(ctx, run)
+}
+
+// expiredMedia lists frames and faces whose retention has passed
+func (g *StorageGC) expiredMedia(ctx context.Context, run *repository.GCRun) []repository.MediaFile {
+	var expired []repository.MediaFile
+	for _, r := range []struct {
+		category  string
+		retention time.Duration
+	}{
+		{MediaCategoryFrame, g.FrameRetention},
+		{MediaCategoryFace, g.FaceRetention},
+	} {
+		if r.retention <= 0 {
+			continue
+		}
+		files, err := repository.ListExpiredMedia(ctx, r.category, time.Now().Add(-r.retention))
+		if err != nil {
+			log.Printf("[WARN] Storage GC failed to list expired %s media: %v", r.category, err)
+			run.Failures++
+			continue
+		}
+		expired = append(expired, files...)
+	}
+	return expired
+}
+
+// dispose quarantines or deletes one orphaned object
+func (g *StorageGC) dispose(ctx context.Context, run *repository.GCRun, obj ObjectInfo) {
+	storage := GetStorageService()
+	if g.Mode == GCModeDelete {
+		if err := storage.Delete(ctx, obj.Key); err != nil {
+			log.Printf("[WARN] Storage GC failed to delete %s: %v", obj.Key, err)
+			run.Failures++
+			return
+		}
+		log.Printf("[INFO] Storage GC deleted %s (%d bytes)", obj.Key, obj.Size)
+		run.DeletedObjects++
+		run.ReclaimedBytes += obj.Size
+		return
+	}
+
+	if err := storage.Move(ctx, obj.Key, QuarantinePrefix+obj.Key); err != nil {
+		log.Printf("[WARN] Storage GC failed to quarantine %s: %v", obj.Key, err)
+		run.Failures++
+		return
+	}
+	log.Printf("[INFO] Storage GC quarantined %s (%d bytes)", obj.Key, obj.Size)
+	run.QuarantinedObjects++
+	run.QuarantinedBytes += obj.Size
+}
+
+// purgeQuarantine deletes quarantined objects once their retention has passed
+func (g *StorageGC) purgeQuarantine(ctx context.Context, run *repository.GCRun) error {
+	stats := &repository.GCPrefixStats{}
+	run.Prefixes[strings.TrimSuffix(QuarantinePrefix, "/")] = stats
+
+	storage := GetStorageService()
+	cutoff := time.Now().Add(-g.QuarantineRetention)
+	err := storage.List(ctx, QuarantinePrefix, func(obj ObjectInfo) error {
+		stats.ScannedObjects++
+		stats.ScannedBytes += obj.Size
+		if obj.LastModified.After(cutoff) {
+			return nil
+		}
+
+		stats.OrphanedObjects++
+		stats.OrphanedBytes += obj.Size
+		if g.DryRun {
+			return nil
+		}
+		if err := storage.Delete(ctx, obj.Key); err != nil {
+			log.Printf("[WARN] Storage GC failed to purge %s: %v", obj.Key, err)
+			run.Failures++
+			return nil
+		}
+		run.DeletedObjects++
+		run.ReclaimedBytes += obj.Size
+		return ctx.Err()
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("[WARN] Storage GC failed to list %s: %v", QuarantinePrefix, err)
+		run.Failures++
+		return nil
+	}
+	return err
+}
+
+// isReferencedKey checks an object against the DB references. On-demand
+// thumbnail variants are not recorded; they live as long as their media.
+func isReferencedKey(key string, refs map[string]int, mediaIDs map[string]bool) bool {
+	if refs[key] > 0 {
+		return true
+	}
+	if name := strings.TrimPrefix(key, "thumbs/"); name != key {
+		if i := strings.LastIndex(name, "_w"); i > 0 {
+			return mediaIDs[name[:i]]
+		}
+	}
+	return false
+}
+
+// RestoreQuarantined moves a quarantined object back to its original key
+func RestoreQuarantined(ctx context.Context, key string) error {
+	key = strings.TrimPrefix(key, QuarantinePrefix)
+	if !isStorageKey(key) {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	storage := GetStorageService()
+	if _, err := storage.Stat(ctx, key); err == nil {
+		return fmt.Errorf("object %s already exists", key)
+	}
+	return storage.Move(ctx, QuarantinePrefix+key, key)
+}
+
+// StartStorageGCJob runs the GC periodically when enabled and the DB is available
+func StartStorageGCJob() {
+	interval := config.Get().GCInterval
+	if interval <= 0 {
+		log.Printf("[INFO] Scheduled storage GC is off; set GC_INTERVAL to enable it")
+		return
+	}
+	if !repository.IsDBAvailable() {
+		return
+	}
+	log.Printf("[INFO] Storage GC runs every %v in %s mode", interval, config.Get().GCMode)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := NewStorageGC().Run(context.Background()); errors.Is(err, ErrGCRunning) {
+				log.Printf("[INFO] Skipping scheduled storage GC: %v", err)
+			}
+		}
+	}()
+}
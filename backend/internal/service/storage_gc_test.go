@@ -0,0 +1,31 @@
+package service
+
+import "testing"
+
+func TestIsReferencedKey(t *testing.T) {
+	refs := map[string]int{
+		"faces/aa.jpg":            1,
+		"faces/aa_normalized.jpg": 1,
+		"results/bb.mp4":          2,
+	}
+	mediaIDs := map[string]bool{"media_0123456789abcdef": true}
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"faces/aa.jpg", true},
+		{"faces/aa_normalized.jpg", true},
+		{"results/bb.mp4", true},
+		{"frames/cc.jpg", false},
+		{"thumbs/media_0123456789abcdef_w320.jpg", true},
+		{"thumbs/media_0123456789abcdef_w64.jpg", true},
+		{"thumbs/media_fedcba9876543210_w320.jpg", false},
+		{"thumbs/garbage.jpg", false},
+	}
+	for _, tt := range tests {
+		if got := isReferencedKey(tt.key, refs, mediaIDs); got != tt.want {
+			t.Errorf("isReferencedKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
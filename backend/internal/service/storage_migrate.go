@@ -0,0 +1,373 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"playplus_platform/internal/config"
+)
+
+// OpenStorageBackend opens a storage backend from a spec:
+//
+//	default                                   the backend configured by environment
+//	local:/path/to/dir                        a local directory
+//	s3://[access:secret@]host[:port]/bucket   an S3-compatible bucket; ?region=..&insecure=1
+//
+// Credentials and region default to the configured ones, so "bucket A → bucket B"
+// on the same account only needs the host and bucket. With create set, a missing
+// bucket is created.
+func OpenStorageBackend(ctx context.Context, spec string, create bool) (*StorageService, error) {
+	cfg := config.Get()
+
+	switch {
+	case spec == "" || spec == "default":
+		return GetStorageService(), nil
+
+	case strings.HasPrefix(spec, "local:"):
+		dir := strings.TrimPrefix(spec, "local:")
+		if dir == "" {
+			return nil, errors.New("local backend needs a directory")
+		}
+		if create {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, err
+			}
+		}
+		return &StorageService{cfg: cfg, localDir: dir}, nil
+
+	case strings.HasPrefix(spec, "s3://"):
+		u, err := url.Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("parse %q: %w", spec, err)
+		}
+		bucket := strings.Trim(u.Path, "/")
+		if u.Host == "" || bucket == "" || strings.Contains(bucket, "/") {
+			return nil, fmt.Errorf("s3 backend must look like s3://host/bucket, got %q", spec)
+		}
+
+		accessKey, secretKey := cfg.StorageAccessKey, cfg.StorageSecretKey
+		if u.User != nil {
+			accessKey = u.User.Username()
+			secretKey, _ = u.User.Password()
+		}
+		region := u.Query().Get("region")
+		if region == "" {
+			region = cfg.StorageRegion
+		}
+		scheme := "https://"
+		if u.Query().Get("insecure") != "" {
+			scheme = "http://"
+		}
+
+		client, err := newMinioClient(scheme+u.Host, accessKey, secretKey, region)
+		if err != nil {
+			return nil, err
+		}
+		exists, err := client.BucketExists(ctx, bucket)
+		if err != nil {
+			return nil, fmt.Errorf("check bucket %s: %w", bucket, err)
+		}
+		if !exists {
+			if !create {
+				return nil, fmt.Errorf("bucket %s does not exist", bucket)
+			}
+			if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: region}); err != nil {
+				return nil, fmt.Errorf("create bucket %s: %w", bucket, err)
+			}
+		}
+		return &StorageService{cfg: cfg, minioClient: client, bucketName: bucket}, nil
+	}
+
+	return nil, fmt.Errorf("unknown storage backend %q (want default, local:DIR or s3://HOST/BUCKET)", spec)
+}
+
+// Describe returns a human-readable name for logs and reports
+func (s *StorageService) Describe() string {
+	if s.minioClient != nil {
+		return fmt.Sprintf("s3://%s/%s", s.minioClient.EndpointURL().Host, s.bucketName)
+	}
+	return "local:" + s.localDir
+}
+
+// StorageMigration copies every object under Prefix from Source to Dest.
+// Completed keys are appended to StatePath so an interrupted run resumes where
+// it stopped; objects already present in Dest with the same size (and checksum
+// when Verify is set) are skipped. The state file names the source and
+// destination it was written for and is refused for any other pair.
+type StorageMigration struct {
+	Source      *StorageService
+	Dest        *StorageService
+	Prefix      string
+	Concurrency int
+	Verify      bool // Re-read each copied object from Dest and compare SHA-256
+	DryRun      bool
+	StatePath   string
+}
+
+// MigrationFailure records an object that could not be copied
+type MigrationFailure struct {
+	Key   string
+	Error string
+}
+
+// MigrationReport summarizes a run (or, with DryRun, what a run would do)
+type MigrationReport struct {
+	Listed       int64
+	ListedBytes  int64
+	Copied       int64 // With DryRun: objects that would be copied
+	CopiedBytes  int64
+	Skipped      int64 // Already in Dest, from state or comparison
+	SkippedBytes int64
+	Failures     []MigrationFailure
+	Duration     time.Duration
+}
+
+// ErrMigrationStateMismatch is returned when the state file was written for
+// another source or destination
+var ErrMigrationStateMismatch = errors.New("state file belongs to a different migration")
+
+// migrationHeader is the first line of the state file
+type migrationHeader struct {
+	Source string `json:"source"`
+	Dest   string `json:"dest"`
+}
+
+// migrationState is one line of the state file after the header
+type migrationState struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Run performs the migration. Per-object errors are collected in the report;
+// the returned error is only for failures that stop the whole run.
+func (m *StorageMigration) Run(ctx context.Context) (*MigrationReport, error) {
+	start := time.Now()
+	report := &MigrationReport{}
+
+	header := migrationHeader{Source: m.Source.Describe(), Dest: m.Dest.Describe()}
+	done, err := loadMigrationState(m.StatePath, header)
+	if err != nil {
+		return nil, err
+	}
+
+	var stateFile *os.File
+	if m.StatePath != "" && !m.DryRun {
+		if stateFile, err = os.OpenFile(m.StatePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			return nil, fmt.Errorf("open state file: %w", err)
+		}
+		defer stateFile.Close()
+		if fi, err := stateFile.Stat(); err == nil && fi.Size() == 0 {
+			line, _ := json.Marshal(header)
+			if _, err := stateFile.Write(append(line, '\n')); err != nil {
+				return nil, fmt.Errorf("write state file: %w", err)
+			}
+		}
+	}
+
+	var (
+		mu      sync.Mutex // Guards report.Failures and stateFile
+		jobs    = make(chan ObjectInfo)
+		wg      sync.WaitGroup
+		workers = m.Concurrency
+	)
+	if workers <= 0 {
+		workers = 1
+	}
+
+	record := func(st migrationState) {
+		if stateFile == nil {
+			return
+		}
+		line, _ := json.Marshal(st)
+		mu.Lock()
+		stateFile.Write(append(line, '\n'))
+		mu.Unlock()
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range jobs {
+				copied, st, err := m.migrateObject(ctx, obj, done)
+				switch {
+				case err != nil:
+					log.Printf("[ERROR] Migrate %s: %v", obj.Key, err)
+					mu.Lock()
+					report.Failures = append(report.Failures, MigrationFailure{Key: obj.Key, Error: err.Error()})
+					mu.Unlock()
+				case copied:
+					atomic.AddInt64(&report.Copied, 1)
+					atomic.AddInt64(&report.CopiedBytes, obj.Size)
+					record(st)
+				default:
+					atomic.AddInt64(&report.Skipped, 1)
+					atomic.AddInt64(&report.SkippedBytes, obj.Size)
+					if st.Key != "" {
+						record(st)
+					}
+				}
+			}
+		}()
+	}
+
+	listErr := m.Source.List(ctx, m.Prefix, func(obj ObjectInfo) error {
+		report.Listed++
+		report.ListedBytes += obj.Size
+		select {
+		case jobs <- obj:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(jobs)
+	wg.Wait()
+
+	report.Duration = time.Since(start)
+	if listErr != nil {
+		return report, fmt.Errorf("list source: %w", listErr)
+	}
+	return report, nil
+}
+
+// migrateObject copies one object unless Dest already has it. It returns whether a
+// copy happened (or would, with DryRun) and the state line to record, if any.
+func (m *StorageMigration) migrateObject(ctx context.Context, obj ObjectInfo, done map[string]migrationState) (bool, migrationState, error) {
+	if st, ok := done[obj.Key]; ok && st.Size == obj.Size {
+		return false, migrationState{}, nil
+	}
+
+	existing, err := m.Dest.Stat(ctx, obj.Key)
+	if err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return false, migrationState{}, fmt.Errorf("stat destination: %w", err)
+	}
+	if existing != nil && existing.Size == obj.Size {
+		if !m.Verify {
+			return false, migrationState{Key: obj.Key, Size: obj.Size}, nil
+		}
+		srcSum, _, err := hashObject(ctx, m.Source, obj.Key)
+		if err != nil {
+			return false, migrationState{}, fmt.Errorf("hash source: %w", err)
+		}
+		dstSum, _, err := hashObject(ctx, m.Dest, obj.Key)
+		if err != nil {
+			return false, migrationState{}, fmt.Errorf("hash destination: %w", err)
+		}
+		if srcSum == dstSum {
+			return false, migrationState{Key: obj.Key, Size: obj.Size, SHA256: srcSum}, nil
+		}
+	}
+
+	if m.DryRun {
+		return true, migrationState{}, nil
+	}
+
+	sum, err := m.copyObject(ctx, obj.Key)
+	if err != nil {
+		return false, migrationState{}, err
+	}
+	return true, migrationState{Key: obj.Key, Size: obj.Size, SHA256: sum}, nil
+}
+
+// copyObject streams one object from Source to Dest and returns its SHA-256
+func (m *StorageMigration) copyObject(ctx context.Context, key string) (string, error) {
+	info, err := m.Source.Stat(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("stat source: %w", err)
+	}
+	src, size, err := m.Source.Open(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("open source: %w", err)
+	}
+	defer src.Close()
+
+	hasher := sha256.New()
+	if err := m.Dest.UploadStream(ctx, key, io.TeeReader(src, hasher), size, info.ContentType); err != nil {
+		return "", err
+	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+
+	if m.Verify {
+		dstSum, dstSize, err := hashObject(ctx, m.Dest, key)
+		if err != nil {
+			return "", fmt.Errorf("verify: %w", err)
+		}
+		if dstSize != size || dstSum != sum {
+			return "", fmt.Errorf("verify: checksum mismatch (source %s, destination %s)", sum, dstSum)
+		}
+	}
+	return sum, nil
+}
+
+// hashObject returns the SHA-256 and size of a stored object
+func hashObject(ctx context.Context, s *StorageService, key string) (string, int64, error) {
+	obj, _, err := s.Open(ctx, key)
+	if err != nil {
+		return "", 0, err
+	}
+	defer obj.Close()
+
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, obj)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), n, nil
+}
+
+// loadMigrationState reads completed keys from a previous run; a missing or empty
+// file is an empty state. A file whose header names other backends is refused:
+// its keys say nothing about what the destination holds.
+func loadMigrationState(path string, header migrationHeader) (map[string]migrationState, error) {
+	done := make(map[string]migrationState)
+	if path == "" {
+		return done, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open state file: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return done, scanner.Err()
+	}
+	var got migrationHeader
+	if err := json.Unmarshal(scanner.Bytes(), &got); err != nil || got.Source == "" {
+		return nil, fmt.Errorf("%w: %s has no source/destination header; remove it or pass another -state", ErrMigrationStateMismatch, path)
+	}
+	if got != header {
+		return nil, fmt.Errorf("%w: %s is for %s -> %s; remove it or pass another -state",
+			ErrMigrationStateMismatch, path, got.Source, got.Dest)
+	}
+
+	for scanner.Scan() {
+		var st migrationState
+		// A torn last line from a crash is ignored; that key is simply redone
+		if json.Unmarshal(scanner.Bytes(), &st) == nil && st.Key != "" {
+			done[st.Key] = st
+		}
+	}
+	return done, scanner.Err()
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrationStateBoundToBackends(t *testing.T) {
+	ctx := context.Background()
+	source := &StorageService{localDir: t.TempDir()}
+	if err := os.MkdirAll(filepath.Join(source.localDir, "media"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source.localDir, "media", "a.jpg"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	statePath := filepath.Join(t.TempDir(), "migrate.state")
+
+	first := &StorageMigration{Source: source, Dest: &StorageService{localDir: t.TempDir()}, StatePath: statePath}
+	report, err := first.Run(ctx)
+	if err != nil {
+		t.Fatalf("first Run() error: %v", err)
+	}
+	if report.Copied != 1 {
+		t.Fatalf("first run copied %d objects, want 1", report.Copied)
+	}
+
+	// Re-running the same pair resumes from the state file
+	report, err = first.Run(ctx)
+	if err != nil {
+		t.Fatalf("resumed Run() error: %v", err)
+	}
+	if report.Skipped != 1 || report.Copied != 0 {
+		t.Errorf("resumed run: copied %d, skipped %d; want 0, 1", report.Copied, report.Skipped)
+	}
+
+	// Another destination must not trust keys copied to the first one
+	second := &StorageMigration{Source: source, Dest: &StorageService{localDir: t.TempDir()}, StatePath: statePath}
+	if _, err := second.Run(ctx); !errors.Is(err, ErrMigrationStateMismatch) {
+		t.Errorf("Run() to another destination: error = %v, want %v", err, ErrMigrationStateMismatch)
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	ContentType  string // Only filled by Stat
+}
+
+// List calls fn for every object whose key starts with prefix.
+// fn may return an error to stop listing; that error is returned.
+func (s *StorageService) List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error {
+	if s.minioClient != nil {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel() // Stops the lister goroutine if fn bails out early
+
+		for obj := range s.minioClient.ListObjects(ctx, s.bucketName, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+			if obj.Err != nil {
+				return fmt.Errorf("minio list: %w", obj.Err)
+			}
+			if err := fn(ObjectInfo{Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Local: walk the directory the prefix falls in and filter by key
+	root := filepath.Join(s.localDir, filepath.FromSlash(prefix[:strings.LastIndex(prefix, "/")+1]))
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == root {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return ctx.Err()
+		}
+		if strings.HasPrefix(d.Name(), ".") { // In-progress UploadStream temp files
+			return nil
+		}
+		rel, err := filepath.Rel(s.localDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+	})
+	return err
+}
+
+// Stat returns an object's metadata, or ErrObjectNotFound
+func (s *StorageService) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	if s.minioClient != nil {
+		stat, err := s.minioClient.StatObject(ctx, s.bucketName, key, minio.StatObjectOptions{})
+		if err != nil {
+			if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+				return nil, ErrObjectNotFound
+			}
+			return nil, fmt.Errorf("minio stat: %w", err)
+		}
+		return &ObjectInfo{Key: key, Size: stat.Size, LastModified: stat.LastModified, ContentType: stat.ContentType}, nil
+	}
+
+	info, err := os.Stat(s.GetLocalPath(key))
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime(), ContentType: contentType}, nil
+}
+
+// UploadStream stores size bytes from r without buffering the whole object.
+// S3 uploads send Content-MD5 so the server rejects corrupted parts.
+func (s *StorageService) UploadStream(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if s.minioClient != nil {
+		_, err := s.minioClient.PutObject(ctx, s.bucketName, key, r, size, minio.PutObjectOptions{
+			ContentType:    contentType,
+			SendContentMd5: true,
+		})
+		if err != nil {
+			return fmt.Errorf("minio upload: %w", err)
+		}
+		return nil
+	}
+
+	// Local: write to a temp file and rename so readers never see partial objects
+	filePath := s.GetLocalPath(key)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write file: %w", err)
+	}
+	if n != size {
+		return fmt.Errorf("write file: got %d bytes, expected %d", n, size)
+	}
+	return os.Rename(tmp.Name(), filePath)
+}
+
+// Move renames an object. The destination's modification time is the time of
+// the move, so age-based cleanup of the new location starts from zero.
+func (s *StorageService) Move(ctx context.Context, srcKey, dstKey string) error {
+	if s.minioClient != nil {
+		_, err := s.minioClient.CopyObject(ctx,
+			minio.CopyDestOptions{Bucket: s.bucketName, Object: dstKey},
+			minio.CopySrcOptions{Bucket: s.bucketName, Object: srcKey})
+		if err != nil {
+			if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+				return ErrObjectNotFound
+			}
+			return fmt.Errorf("minio copy: %w", err)
+		}
+		return s.Delete(ctx, srcKey)
+	}
+
+	dst := s.GetLocalPath(dstKey)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	if err := os.Rename(s.GetLocalPath(srcKey), dst); err != nil {
+		if os.IsNotExist(err) {
+			return ErrObjectNotFound
+		}
+		return err
+	}
+	now := time.Now()
+	return os.Chtimes(dst, now, now)
+}
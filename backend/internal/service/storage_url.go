@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// URLKind selects which kind of URL ResolveURL produces for a storage key
+type URLKind int
+
+const (
+	URLPublic URLKind = iota // Frontend access, through the CDN when configured
+	URLDirect                // External APIs (VModel), bypassing the CDN
+	URLSigned                // Short-lived presigned URL
+)
+
+// Storage backend IDs persisted next to keys
+const (
+	BackendS3    = "s3"
+	BackendLocal = "local"
+)
+
+// keyPrefixes are the top-level prefixes GenerateKey is called with
+var keyPrefixes = []string{"videos/", "images/", "faces/", "frames/", "results/", "thumbs/"}
+
+// BackendID identifies where new objects are written, stored alongside keys in the database
+func (s *StorageService) BackendID() string {
+	if s.minioClient != nil {
+		return BackendS3
+	}
+	return BackendLocal
+}
+
+// IsPrivate returns true if objects are only reachable through presigned URLs
+func (s *StorageService) IsPrivate() bool {
+	return s.minioClient != nil && s.cfg.StoragePrivate
+}
+
+// ResolveURL turns a storage key into a URL of the requested kind.
+// In private mode every kind resolves to a fresh presigned URL.
+func (s *StorageService) ResolveURL(ctx context.Context, key string, kind URLKind) (string, error) {
+	if kind == URLSigned || s.IsPrivate() {
+		return s.GetPresignedURL(ctx, key, s.cfg.StorageURLExpiry)
+	}
+	if kind == URLDirect {
+		return s.GetDirectURL(key), nil
+	}
+	return s.GetPublicURL(key), nil
+}
+
+// GetAccessURL returns the URL handed to the frontend for a stored file
+func (s *StorageService) GetAccessURL(ctx context.Context, key string) (string, error) {
+	return s.ResolveURL(ctx, key, URLPublic)
+}
+
+// GetExternalURL returns the URL external APIs (VModel) should fetch a stored file from
+func (s *StorageService) GetExternalURL(ctx context.Context, key string) (string, error) {
+	return s.ResolveURL(ctx, key, URLDirect)
+}
+
+// KeyFromURL extracts the storage key from any URL this service has handed out:
+// public/CDN URLs (bucket-scoped or not), direct URLs, presigned URLs and local
+// /uploads/ paths. A bare key is returned as-is. Returns false for foreign URLs.
+func (s *StorageService) KeyFromURL(rawURL string) (string, bool) {
+	if rawURL == "" {
+		return "", false
+	}
+	if isStorageKey(rawURL) {
+		return rawURL, true
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	// Drop query (signatures) and fragment, compare on scheme-less host + path
+	target := u.Host + u.Path
+
+	var bases []string
+	if s.minioClient != nil {
+		// Derive bases from the URL builders so bucket-scoped CDNs are handled
+		// exactly the way GetPublicURL/GetDirectURL construct them
+		bases = append(bases,
+			urlBase(s.GetPublicURL("")),
+			urlBase(s.GetDirectURL("")),
+			s.minioClient.EndpointURL().Host+"/"+s.bucketName+"/",
+		)
+	}
+
+	for _, base := range bases {
+		if base == "" || !strings.HasPrefix(target, base) {
+			continue
+		}
+		if key := strings.TrimPrefix(target, base); isStorageKey(key) {
+			return key, true
+		}
+	}
+
+	// Local storage, relative or absolute (served by this app)
+	if key := strings.TrimPrefix(u.Path, "/uploads/"); key != u.Path && isStorageKey(key) {
+		return key, true
+	}
+	return "", false
+}
+
+// urlBase strips the scheme from a URL prefix, e.g. "https://cdn/bucket/" -> "cdn/bucket/"
+func urlBase(prefix string) string {
+	if i := strings.Index(prefix, "://"); i != -1 {
+		return prefix[i+3:]
+	}
+	return prefix
+}
+
+// isStorageKey reports whether s looks like a key produced by GenerateKey
+func isStorageKey(s string) bool {
+	if strings.Contains(s, "..") || strings.Contains(s, "://") {
+		return false
+	}
+	for _, prefix := range keyPrefixes {
+		if strings.HasPrefix(s, prefix) && len(s) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
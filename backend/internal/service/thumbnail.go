@@ -0,0 +1,165 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+// Thumbnails are JPEG previews (WebP has no pure-Go encoder) that fit within a
+// width×width box. The default size is generated on upload and recorded as the
+// media's thumbnail; other sizes are rendered on first request and cached in storage.
+
+const thumbnailQuality = 80
+
+// ThumbnailWidths are the sizes served on demand; requests snap up to the next one
+var ThumbnailWidths = []int{64, 128, 256, 320, 512, 1024}
+
+// ErrNoThumbnail is returned for media that can't be previewed (videos)
+var ErrNoThumbnail = errors.New("no thumbnail available for this media")
+
+var (
+	thumbnailMu    sync.Mutex
+	thumbnailLocks = make(map[string]*thumbnailLock) // Per-key locks so concurrent requests render once
+)
+
+// thumbnailLock is held while a thumbnail is rendered; refs counts the requests
+// holding or waiting for it, so the entry can be dropped after the last one
+type thumbnailLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockThumbnail locks a thumbnail key and returns the unlock function, which
+// removes the key's lock once nobody else waits for it
+func lockThumbnail(key string) func() {
+	thumbnailMu.Lock()
+	lock := thumbnailLocks[key]
+	if lock == nil {
+		lock = &thumbnailLock{}
+		thumbnailLocks[key] = lock
+	}
+	lock.refs++
+	thumbnailMu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		thumbnailMu.Lock()
+		if lock.refs--; lock.refs == 0 {
+			delete(thumbnailLocks, key)
+		}
+		thumbnailMu.Unlock()
+	}
+}
+
+// ThumbnailKey returns the storage key of a media's thumbnail at the given width
+func ThumbnailKey(mediaID string, width int) string {
+	return fmt.Sprintf("thumbs/%s_w%d.jpg", mediaID, width)
+}
+
+// SnapThumbnailWidth maps a requested width to a supported one (0 means the default)
+func SnapThumbnailWidth(width int) int {
+	if width <= 0 {
+		width = config.Get().ThumbnailSize
+	}
+	for _, w := range ThumbnailWidths {
+		if width <= w {
+			return w
+		}
+	}
+	return ThumbnailWidths[len(ThumbnailWidths)-1]
+}
+
+// storeThumbnail renders and stores the default thumbnail of an uploaded image.
+// Failures are logged only: the thumbnail can still be rendered on demand.
+func storeThumbnail(ctx context.Context, info *MediaInfo, content []byte) {
+	if info.MediaID == "" {
+		info.MediaID = generateMediaID()
+	}
+
+	key := ThumbnailKey(info.MediaID, SnapThumbnailWidth(0))
+	thumb, err := NormalizeImage(content, SnapThumbnailWidth(0), thumbnailQuality)
+	if err != nil {
+		log.Printf("[WARN] Failed to render thumbnail for %s: %v", info.Key, err)
+		return
+	}
+	if _, err := GetStorageService().UploadBytes(ctx, key, thumb.Data, "image/jpeg"); err != nil {
+		log.Printf("[WARN] Failed to store thumbnail %s: %v", key, err)
+		return
+	}
+	info.ThumbnailKey = key
+	info.ThumbnailSize = int64(len(thumb.Data))
+}
+
+// OpenThumbnail returns the media's thumbnail at the given width, rendering and
+// caching it in storage on first request.
+func OpenThumbnail(ctx context.Context, media *repository.MediaFile, width int) (ObjectReader, int64, error) {
+	if media.FileType != "image" || !media.StorageKey.Valid {
+		return nil, 0, ErrNoThumbnail
+	}
+
+	storage := GetStorageService()
+	width = SnapThumbnailWidth(width)
+	key := ThumbnailKey(media.MediaID, width)
+
+	unlock := lockThumbnail(key)
+	defer unlock()
+
+	obj, size, err := storage.Open(ctx, key)
+	if err == nil {
+		return obj, size, nil
+	}
+	if !errors.Is(err, ErrObjectNotFound) {
+		return nil, 0, err
+	}
+
+	// Render from the original
+	src, _, err := storage.Open(ctx, media.StorageKey.String)
+	if err != nil {
+		return nil, 0, fmt.Errorf("open original: %w", err)
+	}
+	content, err := io.ReadAll(src)
+	src.Close()
+	if err != nil {
+		return nil, 0, fmt.Errorf("read original: %w", err)
+	}
+
+	thumb, err := NormalizeImage(content, width, thumbnailQuality)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := storage.UploadBytes(ctx, key, thumb.Data, "image/jpeg"); err != nil {
+		log.Printf("[WARN] Failed to cache thumbnail %s: %v", key, err)
+	} else if width == SnapThumbnailWidth(0) && !media.ThumbnailKey.Valid {
+		// Media uploaded before thumbnails existed gets its default one recorded now
+		if err := repository.SetMediaThumbnailKey(ctx, media.MediaID, key, int64(len(thumb.Data))); err != nil {
+			log.Printf("[WARN] Failed to record thumbnail for %s: %v", media.MediaID, err)
+		}
+	}
+
+	return bytesObject{bytes.NewReader(thumb.Data)}, int64(len(thumb.Data)), nil
+}
+
+// thumbnailVariantKeys lists every thumbnail key a media may have
+func thumbnailVariantKeys(mediaID string) []string {
+	keys := make([]string, len(ThumbnailWidths))
+	for i, w := range ThumbnailWidths {
+		keys[i] = ThumbnailKey(mediaID, w)
+	}
+	return keys
+}
+
+// bytesObject adapts an in-memory buffer to ObjectReader
+type bytesObject struct {
+	*bytes.Reader
+}
+
+func (bytesObject) Close() error { return nil }
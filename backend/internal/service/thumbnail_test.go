@@ -0,0 +1,25 @@
+package service
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestThumbnailLocksReleased(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			unlock := lockThumbnail(ThumbnailKey("media_test", ThumbnailWidths[i%2]))
+			unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	thumbnailMu.Lock()
+	defer thumbnailMu.Unlock()
+	if len(thumbnailLocks) != 0 {
+		t.Errorf("%d thumbnail locks left after all renders finished", len(thumbnailLocks))
+	}
+}
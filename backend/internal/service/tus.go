@@ -0,0 +1,544 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// Resumable uploads (tus 1.0)
+//
+// Each upload keeps two files in the staging directory:
+//   <id>.info - JSON session state (TusUpload)
+//   <id>.bin  - received bytes not yet pushed to storage
+//
+// With MinIO configured, the .bin file is flushed as a multipart part once it
+// reaches tusPartSize, so staging never holds more than one part plus a chunk.
+// With local storage, .bin accumulates the whole file and is moved into the
+// upload dir on completion.
+
+const (
+	TusVersion    = "1.0.0"
+	TusUploadTTL  = 24 * time.Hour  // 未完成的上传保留24小时
+	tusPartSize   = 8 * 1024 * 1024 // S3 requires parts >= 5MB (except the last)
+	tusIDByteSize = 16
+)
+
+var (
+	ErrTusNotFound       = errors.New("upload not found")
+	ErrTusOffsetMismatch = errors.New("upload offset mismatch")
+	ErrTusCompleted      = errors.New("upload already completed")
+	// ErrTusTooLarge is returned when a chunk runs past the declared upload length.
+	// Nothing of the chunk is stored.
+	ErrTusTooLarge = errors.New("chunk exceeds the remaining upload length")
+	// ErrTusInterrupted is returned when the request body breaks off mid-chunk.
+	// The bytes received before are kept and the client can resume.
+	ErrTusInterrupted = errors.New("upload chunk interrupted")
+)
+
+// TusUpload is the persisted state of a resumable upload
+type TusUpload struct {
+	ID          string               `json:"id"`
+	UserID      int64                `json:"user_id"`
+	Key         string               `json:"key"`
+	Filename    string               `json:"filename"`
+	ContentType string               `json:"content_type"`
+	Size        int64                `json:"size"`
+	Offset      int64                `json:"offset"`
+	Flushed     int64                `json:"flushed"` // bytes already sent to MinIO as parts
+	MultipartID string               `json:"multipart_id,omitempty"`
+	Parts       []minio.CompletePart `json:"parts,omitempty"`
+	Completed   bool                 `json:"completed"`
+	MediaID     string               `json:"media_id,omitempty"`
+	HashState   []byte               `json:"hash_state,omitempty"` // Marshaled SHA-256 state after Hashed bytes
+	Hashed      int64                `json:"hashed"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+// ExpiresAt returns when an unfinished upload will be discarded
+func (u *TusUpload) ExpiresAt() time.Time {
+	return u.UpdatedAt.Add(TusUploadTTL)
+}
+
+// TusService manages resumable upload sessions
+type TusService struct {
+	storage    *StorageService
+	stagingDir string
+}
+
+var (
+	tusService *TusService
+	tusOnce    sync.Once
+	tusLocks   = sync.Map{} // Per-upload locks to serialize PATCH requests
+	// tusCreateMu serializes the quota check with session creation, so parallel
+	// creates can't each pass against the same free space
+	tusCreateMu sync.Mutex
+)
+
+// GetTusService returns the singleton tus service
+func GetTusService() *TusService {
+	tusOnce.Do(func() {
+		storage := GetStorageService()
+
+		stagingDir := storage.cfg.TusStagingDir
+		if stagingDir == "" {
+			stagingDir = filepath.Join(os.TempDir(), "playplus-tus")
+		}
+		os.MkdirAll(stagingDir, 0755)
+
+		tusService = &TusService{
+			storage:    storage,
+			stagingDir: stagingDir,
+		}
+	})
+	return tusService
+}
+
+func getUploadLock(id string) *sync.Mutex {
+	lock, _ := tusLocks.LoadOrStore(id, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+func (t *TusService) infoPath(id string) string {
+	return filepath.Join(t.stagingDir, id+".info")
+}
+
+func (t *TusService) binPath(id string) string {
+	return filepath.Join(t.stagingDir, id+".bin")
+}
+
+// Create starts a new upload session for a file of the given size. The size,
+// the user's other unfinished uploads and running imports count against the
+// storage quota.
+func (t *TusService) Create(ctx context.Context, userID int64, key, filename, contentType string, size int64) (*TusUpload, error) {
+	tusCreateMu.Lock()
+	defer tusCreateMu.Unlock()
+	_, importing := activeImports(userID, "")
+	if err := CheckStorageQuota(ctx, userID, size+t.pendingBytes(userID, "")+importing); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, tusIDByteSize)
+	rand.Read(b)
+
+	now := time.Now()
+	upload := &TusUpload{
+		ID:          hex.EncodeToString(b),
+		UserID:      userID,
+		Key:         key,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        size,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	f, err := os.Create(t.binPath(upload.ID))
+	if err != nil {
+		return nil, fmt.Errorf("create staging file: %w", err)
+	}
+	f.Close()
+
+	if err := t.save(upload); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// Get loads an upload session by ID
+func (t *TusService) Get(id string) (*TusUpload, error) {
+	if len(id) != tusIDByteSize*2 {
+		return nil, ErrTusNotFound
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return nil, ErrTusNotFound
+	}
+
+	data, err := os.ReadFile(t.infoPath(id))
+	if os.IsNotExist(err) {
+		return nil, ErrTusNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read upload info: %w", err)
+	}
+
+	var upload TusUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, fmt.Errorf("decode upload info: %w", err)
+	}
+
+	// The staging file is the source of truth for received bytes, so an
+	// interrupted PATCH that wrote data but not the info file is still counted.
+	if !upload.Completed {
+		if fi, err := os.Stat(t.binPath(id)); err == nil {
+			upload.Offset = upload.Flushed + fi.Size()
+		}
+	}
+
+	return &upload, nil
+}
+
+// WriteChunk appends data at the given offset and returns the updated session.
+// Bytes received before a read error are kept so the client can resume; the
+// error then wraps ErrTusInterrupted. Any other error is a server-side failure.
+func (t *TusService) WriteChunk(ctx context.Context, id string, offset int64, r io.Reader) (*TusUpload, error) {
+	lock := getUploadLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	upload, err := t.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if upload.Completed {
+		return upload, ErrTusCompleted
+	}
+	if offset != upload.Offset {
+		return upload, ErrTusOffsetMismatch
+	}
+
+	// Validate the real file type from the first chunk before anything is stored
+	if upload.Offset == 0 {
+		br := bufio.NewReaderSize(r, SniffLen)
+		head, _ := br.Peek(SniffLen)
+		contentType, err := CheckMediaType(head, upload.ContentType, MediaCategoryMedia)
+		if err != nil {
+			return upload, err
+		}
+		upload.ContentType = contentType
+		r = br
+	}
+
+	f, err := os.OpenFile(t.binPath(id), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open staging file: %w", err)
+	}
+	// Hash incrementally so the media record gets a checksum without re-reading
+	// the object. If the state ever falls out of step with the offset (crash
+	// between write and save) hashing is abandoned for this upload.
+	hasher := sha256.New()
+	hashing := upload.Hashed == upload.Offset
+	if hashing && len(upload.HashState) > 0 {
+		hashing = hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(upload.HashState) == nil
+	}
+
+	// Read one byte past the remaining length to detect an oversized body
+	remaining := upload.Size - upload.Offset
+	body := &readErrRecorder{r: r}
+	src := io.LimitReader(body, remaining+1)
+	if hashing {
+		src = io.TeeReader(src, hasher)
+	}
+	n, copyErr := io.Copy(f, src)
+	if n > remaining {
+		// Roll the staging file back to where the chunk started
+		f.Truncate(upload.Offset - upload.Flushed)
+		f.Close()
+		return upload, ErrTusTooLarge
+	}
+	f.Close()
+	if copyErr != nil {
+		if copyErr == body.err {
+			copyErr = fmt.Errorf("%w: %v", ErrTusInterrupted, copyErr)
+		} else {
+			copyErr = fmt.Errorf("write staging file: %w", copyErr)
+		}
+	}
+
+	if hashing {
+		if state, err := hasher.(encoding.BinaryMarshaler).MarshalBinary(); err == nil {
+			upload.HashState = state
+			upload.Hashed = upload.Offset + n
+		}
+	}
+	upload.Offset += n
+	upload.UpdatedAt = time.Now()
+
+	if err := t.flushParts(ctx, upload, false); err != nil {
+		return upload, err
+	}
+
+	if upload.Offset == upload.Size {
+		if err := t.finish(ctx, upload); err != nil {
+			if errors.Is(err, ErrStorageQuotaExceeded) {
+				// Resending can't succeed; drop the upload instead of keeping it staged
+				t.discard(ctx, upload)
+			}
+			return upload, err
+		}
+	}
+
+	if err := t.save(upload); err != nil {
+		return upload, err
+	}
+	return upload, copyErr
+}
+
+// readErrRecorder remembers the error of the underlying reader, telling a
+// broken request body apart from a failed write
+type readErrRecorder struct {
+	r   io.Reader
+	err error
+}
+
+func (e *readErrRecorder) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err != nil && err != io.EOF {
+		e.err = err
+	}
+	return n, err
+}
+
+// flushParts pushes buffered bytes to MinIO as a multipart part.
+// Unless final is set, it only flushes once the buffer reaches tusPartSize.
+func (t *TusService) flushParts(ctx context.Context, upload *TusUpload, final bool) error {
+	if t.storage.minioClient == nil {
+		return nil
+	}
+
+	buffered := upload.Offset - upload.Flushed
+	if buffered == 0 || (!final && buffered < tusPartSize) {
+		return nil
+	}
+
+	core := minio.Core{Client: t.storage.minioClient}
+	if upload.MultipartID == "" {
+		multipartID, err := core.NewMultipartUpload(ctx, t.storage.bucketName, upload.Key, minio.PutObjectOptions{
+			ContentType: upload.ContentType,
+		})
+		if err != nil {
+			return fmt.Errorf("start multipart upload: %w", err)
+		}
+		upload.MultipartID = multipartID
+	}
+
+	f, err := os.Open(t.binPath(upload.ID))
+	if err != nil {
+		return fmt.Errorf("open staging file: %w", err)
+	}
+	defer f.Close()
+
+	partNumber := len(upload.Parts) + 1
+	part, err := core.PutObjectPart(ctx, t.storage.bucketName, upload.Key, upload.MultipartID, partNumber, f, buffered, minio.PutObjectPartOptions{})
+	if err != nil {
+		return fmt.Errorf("upload part %d: %w", partNumber, err)
+	}
+
+	upload.Parts = append(upload.Parts, minio.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag})
+	upload.Flushed += buffered
+
+	// Truncate before saving: if we crash in between, the offset falls back to
+	// the previous part boundary and the re-sent part overwrites the same part number.
+	if err := os.Truncate(t.binPath(upload.ID), 0); err != nil {
+		return fmt.Errorf("truncate staging file: %w", err)
+	}
+	return t.save(upload)
+}
+
+// pendingBytes returns the announced size of a user's unfinished, unexpired
+// uploads other than skip
+func (t *TusService) pendingBytes(userID int64, skip string) int64 {
+	entries, err := os.ReadDir(t.stagingDir)
+	if err != nil {
+		return 0
+	}
+
+	now := time.Now()
+	var total int64
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".info" {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".info")
+		if id == skip {
+			continue
+		}
+		upload, err := t.Get(id)
+		if err != nil || upload.UserID != userID || upload.Completed || !now.Before(upload.ExpiresAt()) {
+			continue
+		}
+		total += upload.Size
+	}
+	return total
+}
+
+// finish assembles the received bytes into the final storage object.
+// The quota is checked again first: other uploads may have completed since
+// this one was created.
+func (t *TusService) finish(ctx context.Context, upload *TusUpload) error {
+	_, importing := activeImports(upload.UserID, "")
+	if err := CheckStorageQuota(ctx, upload.UserID, upload.Size+t.pendingBytes(upload.UserID, upload.ID)+importing); err != nil {
+		return err
+	}
+
+	if t.storage.minioClient != nil {
+		if err := t.flushParts(ctx, upload, true); err != nil {
+			return err
+		}
+		core := minio.Core{Client: t.storage.minioClient}
+		_, err := core.CompleteMultipartUpload(ctx, t.storage.bucketName, upload.Key, upload.MultipartID, upload.Parts, minio.PutObjectOptions{
+			ContentType: upload.ContentType,
+		})
+		if err != nil {
+			return fmt.Errorf("complete multipart upload: %w", err)
+		}
+	} else {
+		dest := t.storage.GetLocalPath(upload.Key)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return fmt.Errorf("create directory: %w", err)
+		}
+		if err := moveFile(t.binPath(upload.ID), dest); err != nil {
+			return fmt.Errorf("move upload: %w", err)
+		}
+	}
+
+	upload.Completed = true
+	os.Remove(t.binPath(upload.ID))
+	log.Printf("[INFO] Resumable upload %s completed: %s (%d bytes)", upload.ID, upload.Key, upload.Size)
+
+	t.recordMedia(ctx, upload)
+	return nil
+}
+
+// recordMedia adds the completed upload to the media library, like StoreUpload does
+func (t *TusService) recordMedia(ctx context.Context, upload *TusUpload) {
+	info := &MediaInfo{
+		UserID:      upload.UserID,
+		Key:         upload.Key,
+		Filename:    upload.Filename,
+		Category:    MediaCategoryMedia,
+		ContentType: upload.ContentType,
+		Size:        upload.Size,
+	}
+
+	if upload.Hashed == upload.Size {
+		hasher := sha256.New()
+		if hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(upload.HashState) == nil {
+			info.Hash = hex.EncodeToString(hasher.Sum(nil))
+		}
+	}
+
+	if obj, size, err := t.storage.Open(ctx, upload.Key); err == nil {
+		probeMedia(info, obj, size)
+		if strings.HasPrefix(info.ContentType, "image/") {
+			if content, err := io.ReadAll(io.NewSectionReader(obj, 0, size)); err == nil {
+				storeThumbnail(ctx, info, content)
+			}
+		}
+		obj.Close()
+	}
+
+	RecordMedia(ctx, info)
+	upload.MediaID = info.MediaID
+	upload.HashState = nil
+}
+
+// Terminate aborts an upload and discards everything received so far
+func (t *TusService) Terminate(ctx context.Context, id string) error {
+	lock := getUploadLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	upload, err := t.Get(id)
+	if err != nil {
+		return err
+	}
+	t.discard(ctx, upload)
+	return nil
+}
+
+// discard aborts the multipart upload, if any, and removes the session.
+// The caller holds the upload lock.
+func (t *TusService) discard(ctx context.Context, upload *TusUpload) {
+	if upload.MultipartID != "" && !upload.Completed {
+		core := minio.Core{Client: t.storage.minioClient}
+		if err := core.AbortMultipartUpload(ctx, t.storage.bucketName, upload.Key, upload.MultipartID); err != nil {
+			log.Printf("[WARN] Failed to abort multipart upload %s: %v", upload.ID, err)
+		}
+	}
+	t.remove(upload.ID)
+}
+
+func (t *TusService) remove(id string) {
+	os.Remove(t.binPath(id))
+	os.Remove(t.infoPath(id))
+	tusLocks.Delete(id)
+}
+
+func (t *TusService) save(upload *TusUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return fmt.Errorf("encode upload info: %w", err)
+	}
+	tmp := t.infoPath(upload.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write upload info: %w", err)
+	}
+	return os.Rename(tmp, t.infoPath(upload.ID))
+}
+
+// CleanupExpiredUploads discards sessions that have been idle longer than TusUploadTTL.
+// Completed sessions are kept for the same period so clients can fetch the result.
+func (t *TusService) CleanupExpiredUploads() {
+	entries, err := os.ReadDir(t.stagingDir)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".info" {
+			continue
+		}
+		id := entry.Name()[:len(entry.Name())-len(".info")]
+		upload, err := t.Get(id)
+		if err != nil || now.Before(upload.ExpiresAt()) {
+			continue
+		}
+		if err := t.Terminate(context.Background(), id); err == nil {
+			log.Printf("Cleaned up expired resumable upload: %s", id)
+		}
+	}
+}
+
+// moveFile renames src to dst, falling back to copy when they are on different filesystems
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
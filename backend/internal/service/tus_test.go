@@ -0,0 +1,166 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func newTestTusService(t *testing.T) *TusService {
+	t.Helper()
+	return &TusService{
+		storage:    &StorageService{localDir: t.TempDir()},
+		stagingDir: t.TempDir(),
+	}
+}
+
+// brokenReader returns data and then fails, like a client disconnecting mid-chunk
+type brokenReader struct {
+	data []byte
+}
+
+func (b *brokenReader) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, errors.New("connection reset by peer")
+	}
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+	return n, nil
+}
+
+func TestTusOffsetMismatch(t *testing.T) {
+	tus := newTestTusService(t)
+	data := buildMP4(0)
+	upload, err := tus.Create(context.Background(), 1, "media/test.mp4", "test.mp4", "video/mp4", int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tus.WriteChunk(context.Background(), upload.ID, 100, bytes.NewReader(data[100:])); !errors.Is(err, ErrTusOffsetMismatch) {
+		t.Errorf("WriteChunk() at a wrong offset: error = %v, want %v", err, ErrTusOffsetMismatch)
+	}
+	got, err := tus.Get(upload.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Offset != 0 {
+		t.Errorf("offset after a rejected chunk = %d, want 0", got.Offset)
+	}
+}
+
+func TestTusResumeAfterPartialChunk(t *testing.T) {
+	tus := newTestTusService(t)
+	ctx := context.Background()
+	data := buildMP4(0)
+	upload, err := tus.Create(context.Background(), 1, "media/test.mp4", "test.mp4", "video/mp4", int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The body breaks off after 600 bytes
+	partial, err := tus.WriteChunk(ctx, upload.ID, 0, &brokenReader{data: data[:600]})
+	if !errors.Is(err, ErrTusInterrupted) {
+		t.Fatalf("WriteChunk() with a broken body: error = %v, want %v", err, ErrTusInterrupted)
+	}
+	if partial.Offset != 600 {
+		t.Fatalf("offset after the partial chunk = %d, want 600", partial.Offset)
+	}
+
+	// HEAD reports what was received, from the staging file
+	head, err := tus.Get(upload.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Offset != 600 || head.Completed {
+		t.Fatalf("Get() = offset %d, completed %v; want 600, false", head.Offset, head.Completed)
+	}
+
+	done, err := tus.WriteChunk(ctx, upload.ID, 600, bytes.NewReader(data[600:]))
+	if err != nil {
+		t.Fatalf("WriteChunk() resuming at 600: %v", err)
+	}
+	if !done.Completed || done.Offset != int64(len(data)) {
+		t.Fatalf("after resuming: offset %d, completed %v; want %d, true", done.Offset, done.Completed, len(data))
+	}
+	stored, err := os.ReadFile(tus.storage.GetLocalPath(upload.Key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, data) {
+		t.Error("stored file differs from the uploaded data")
+	}
+
+	if _, err := tus.WriteChunk(ctx, upload.ID, done.Offset, bytes.NewReader(nil)); !errors.Is(err, ErrTusCompleted) {
+		t.Errorf("WriteChunk() after completion: error = %v, want %v", err, ErrTusCompleted)
+	}
+}
+
+func TestTusTerminate(t *testing.T) {
+	tus := newTestTusService(t)
+	ctx := context.Background()
+	data := buildMP4(0)
+	upload, err := tus.Create(context.Background(), 1, "media/test.mp4", "test.mp4", "video/mp4", int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tus.WriteChunk(ctx, upload.ID, 0, io.LimitReader(bytes.NewReader(data), 600)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tus.Terminate(ctx, upload.ID); err != nil {
+		t.Fatalf("Terminate() error: %v", err)
+	}
+	if _, err := tus.Get(upload.ID); !errors.Is(err, ErrTusNotFound) {
+		t.Errorf("Get() after Terminate: error = %v, want %v", err, ErrTusNotFound)
+	}
+	if _, err := os.Stat(tus.binPath(upload.ID)); !os.IsNotExist(err) {
+		t.Errorf("staging file still exists after Terminate")
+	}
+	if _, err := tus.WriteChunk(ctx, upload.ID, 600, bytes.NewReader(data[600:])); !errors.Is(err, ErrTusNotFound) {
+		t.Errorf("WriteChunk() after Terminate: error = %v, want %v", err, ErrTusNotFound)
+	}
+}
+
+func TestTusRejectsOversizedChunk(t *testing.T) {
+	tus := newTestTusService(t)
+	ctx := context.Background()
+	data := buildMP4(0)
+	upload, err := tus.Create(ctx, 1, "media/test.mp4", "test.mp4", "video/mp4", int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tus.WriteChunk(ctx, upload.ID, 0, bytes.NewReader(data[:600])); err != nil {
+		t.Fatal(err)
+	}
+
+	oversized := append(append([]byte(nil), data[600:]...), "trailing"...)
+	if _, err := tus.WriteChunk(ctx, upload.ID, 600, bytes.NewReader(oversized)); !errors.Is(err, ErrTusTooLarge) {
+		t.Fatalf("WriteChunk() past the upload length: error = %v, want %v", err, ErrTusTooLarge)
+	}
+	got, err := tus.Get(upload.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Offset != 600 || got.Completed {
+		t.Fatalf("after the rejected chunk: offset %d, completed %v; want 600, false", got.Offset, got.Completed)
+	}
+
+	// The same chunk without the extra bytes is accepted
+	done, err := tus.WriteChunk(ctx, upload.ID, 600, bytes.NewReader(data[600:]))
+	if err != nil {
+		t.Fatalf("WriteChunk() resuming at 600: %v", err)
+	}
+	if !done.Completed {
+		t.Error("upload not completed after the remaining bytes")
+	}
+	stored, err := os.ReadFile(tus.storage.GetLocalPath(upload.Key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored, data) {
+		t.Error("stored file differs from the uploaded data")
+	}
+}
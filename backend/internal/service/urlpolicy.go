@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+var (
+	// ErrURLRejected is returned for URLs the policy doesn't allow to be used or fetched
+	ErrURLRejected = errors.New("URL not allowed")
+	// ErrResponseTooLarge is returned when a fetched body exceeds the policy's size cap
+	ErrResponseTooLarge = errors.New("response too large")
+)
+
+// URLPolicy restricts outbound fetches of URLs we don't control. Addresses are
+// checked after DNS resolution, so public names pointing at internal hosts are
+// refused as well.
+type URLPolicy struct {
+	Name         string   // Shown in rejection logs
+	AllowedHosts []string // Domains and their subdomains; empty allows any public host
+	MaxRedirects int
+	MaxSize      int64         // 0 = unlimited
+	Timeout      time.Duration // Whole request including the body, 0 = none
+}
+
+// TransferURLPolicy allows fetching provider results from the configured domains only
+func TransferURLPolicy() *URLPolicy {
+	cfg := config.Get()
+	return &URLPolicy{
+		Name:         "transfer",
+		AllowedHosts: cfg.TransferAllowedHosts,
+		MaxRedirects: cfg.FetchMaxRedirects,
+		MaxSize:      cfg.FetchMaxSize,
+		Timeout:      5 * time.Minute,
+	}
+}
+
+// ImportURLPolicy allows fetching media from any public host, up to the media upload limit
+func ImportURLPolicy() *URLPolicy {
+	cfg := config.Get()
+	return &URLPolicy{
+		Name:         "import",
+		MaxRedirects: cfg.FetchMaxRedirects,
+		MaxSize:      MaxUploadSize(MediaCategoryMedia),
+		Timeout:      cfg.MediaImportTimeout,
+	}
+}
+
+// UserMediaKey resolves a media URL or key sent by a user to one of their own
+// storage keys. Anything else is rejected rather than forwarded, so users can't
+// make the provider (or us) fetch arbitrary addresses or other users' files.
+// Ownership is checked against the media library when the DB is available.
+func UserMediaKey(ctx context.Context, userID int64, rawURL string) (string, error) {
+	key, ok := GetStorageService().KeyFromURL(rawURL)
+	if !ok {
+		log.Printf("[WARN] Rejected user media URL %s: not a storage URL", redactURL(rawURL))
+		return "", fmt.Errorf("%w: only files uploaded here can be used", ErrURLRejected)
+	}
+	if !repository.IsDBAvailable() {
+		return key, nil
+	}
+
+	media, err := repository.GetMediaFileByKey(ctx, key)
+	if err != nil {
+		log.Printf("[ERROR] Failed to check owner of %s: %v", key, err)
+		return "", fmt.Errorf("look up file: %w", err)
+	}
+	// Files of other users are reported like missing ones
+	if media == nil || media.UserID != userID {
+		log.Printf("[WARN] Rejected media key %s for user %d: not in their media library", key, userID)
+		return "", fmt.Errorf("%w: file not found", ErrURLRejected)
+	}
+	return key, nil
+}
+
+// Check validates a URL before it is fetched; rejections are logged
+func (p *URLPolicy) Check(rawURL string) error {
+	err := p.check(rawURL)
+	if err != nil {
+		log.Printf("[WARN] Rejected %s URL %s: %v", p.Name, redactURL(rawURL), err)
+	}
+	return err
+}
+
+func (p *URLPolicy) check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("%w: invalid URL", ErrURLRejected)
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("%w: unsupported scheme %q", ErrURLRejected, u.Scheme)
+	}
+	if u.User != nil {
+		return fmt.Errorf("%w: credentials in URL", ErrURLRejected)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return fmt.Errorf("%w: missing host", ErrURLRejected)
+	}
+	if ip := net.ParseIP(host); ip != nil && isBlockedIP(ip) {
+		return fmt.Errorf("%w: private address %s", ErrURLRejected, host)
+	}
+	if len(p.AllowedHosts) > 0 && !hostAllowed(host, p.AllowedHosts) {
+		return fmt.Errorf("%w: host %s is not allowed", ErrURLRejected, host)
+	}
+	return nil
+}
+
+// Get fetches a URL under the policy. Redirects are capped and checked like the
+// original URL, and the body fails with ErrResponseTooLarge past MaxSize.
+func (p *URLPolicy) Get(ctx context.Context, rawURL string) (*http.Response, error) {
+	if err := p.Check(rawURL); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Transport: safeTransport(),
+		Timeout:   p.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > p.MaxRedirects {
+				log.Printf("[WARN] Rejected %s URL %s: more than %d redirects", p.Name, redactURL(rawURL), p.MaxRedirects)
+				return fmt.Errorf("%w: too many redirects", ErrURLRejected)
+			}
+			return p.Check(req.URL.String())
+		},
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var dialErr *blockedAddrError
+		if errors.As(err, &dialErr) {
+			log.Printf("[WARN] Rejected %s URL %s: %v", p.Name, redactURL(rawURL), dialErr)
+		}
+		return nil, err
+	}
+
+	if p.MaxSize > 0 {
+		if resp.ContentLength > p.MaxSize {
+			resp.Body.Close()
+			log.Printf("[WARN] Rejected %s URL %s: %d bytes exceeds %d", p.Name, redactURL(rawURL), resp.ContentLength, p.MaxSize)
+			return nil, fmt.Errorf("%w: %d bytes", ErrResponseTooLarge, resp.ContentLength)
+		}
+		resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: p.MaxSize}
+	}
+	return resp, nil
+}
+
+// limitedBody fails once more than remaining bytes are read, instead of
+// silently truncating like io.LimitReader
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrResponseTooLarge
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n, ErrResponseTooLarge
+	}
+	return n, err
+}
+
+// blockedAddrError is returned by the dialer for private destinations
+type blockedAddrError struct{ addr string }
+
+func (e *blockedAddrError) Error() string {
+	return ErrURLRejected.Error() + ": " + e.addr + " is a private address"
+}
+
+func (e *blockedAddrError) Unwrap() error { return ErrURLRejected }
+
+// blockedIP is swapped in tests, which serve from loopback
+var blockedIP = isBlockedIP
+
+var (
+	safeTransportOnce sync.Once
+	safeTransportInst *http.Transport
+)
+
+// safeTransport refuses connections to private addresses. The check runs on
+// the resolved address right before connecting, which also defeats DNS
+// rebinding. Proxies are disabled since they would hide the destination.
+func safeTransport() *http.Transport {
+	safeTransportOnce.Do(func() {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+					return &blockedAddrError{addr: host}
+				}
+				return nil
+			},
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = nil
+		t.DialContext = dialer.DialContext
+		safeTransportInst = t
+	})
+	return safeTransportInst
+}
+
+// Ranges not covered by the net.IP predicates
+var blockedNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",     // "This" network
+		"100.64.0.0/10", // Carrier-grade NAT
+		"192.0.0.0/24",  // IETF protocol assignments
+		"198.18.0.0/15", // Benchmarking
+		"240.0.0.0/4",   // Reserved, including broadcast
+	} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// isBlockedIP reports loopback, private, link-local and other non-public addresses
+func isBlockedIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostAllowed matches a host against domains, including their subdomains
+func hostAllowed(host string, domains []string) bool {
+	for _, d := range domains {
+		d = strings.TrimSuffix(strings.ToLower(d), ".")
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL drops credentials, query (signatures) and fragment for logging
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"playplus_platform/internal/config"
+	"playplus_platform/internal/repository"
+)
+
+// ErrStorageQuotaExceeded is returned when an upload would take a user or team over its cap
+var ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+
+// UsageSummary totals storage usage against the cap that applies (0 = unlimited)
+type UsageSummary struct {
+	Bytes    int64
+	Objects  int64
+	Quota    int64
+	ByPrefix []repository.StorageUsage
+}
+
+// TeamUsageSummary is the usage of the team a user belongs to
+type TeamUsageSummary struct {
+	UsageSummary
+	ID   int64
+	Name string
+}
+
+// Remaining returns the bytes left under the quota, or -1 when unlimited
+func (u *UsageSummary) Remaining() int64 {
+	if u.Quota <= 0 {
+		return -1
+	}
+	if u.Bytes >= u.Quota {
+		return 0
+	}
+	return u.Quota - u.Bytes
+}
+
+// GetStorageUsage returns a user's usage and, when they belong to one, their team's
+func GetStorageUsage(ctx context.Context, userID int64) (*UsageSummary, *TeamUsageSummary, error) {
+	quotas, err := repository.GetStorageQuotas(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg := config.Get()
+
+	usage, err := repository.GetUserStorageUsage(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	user := summarizeUsage(usage, cfg.StorageQuotaUser)
+	if quotas.UserQuota.Valid {
+		user.Quota = quotas.UserQuota.Int64
+	}
+
+	if !quotas.TeamID.Valid {
+		return user, nil, nil
+	}
+	usage, err = repository.GetTeamStorageUsage(ctx, quotas.TeamID.Int64)
+	if err != nil {
+		return nil, nil, err
+	}
+	team := &TeamUsageSummary{
+		UsageSummary: *summarizeUsage(usage, cfg.StorageQuotaTeam),
+		ID:           quotas.TeamID.Int64,
+		Name:         quotas.TeamName.String,
+	}
+	if quotas.TeamQuota.Valid {
+		team.Quota = quotas.TeamQuota.Int64
+	}
+	return user, team, nil
+}
+
+func summarizeUsage(usage []repository.StorageUsage, quota int64) *UsageSummary {
+	s := &UsageSummary{Quota: quota, ByPrefix: usage}
+	for _, u := range usage {
+		s.Bytes += u.Bytes
+		s.Objects += u.Objects
+	}
+	return s
+}
+
+// CheckStorageQuota rejects an upload of size bytes that would exceed the
+// user's or their team's cap. Accounting failures are logged and let through.
+func CheckStorageQuota(ctx context.Context, userID, size int64) error {
+	if !repository.IsDBAvailable() {
+		return nil
+	}
+	cfg := config.Get()
+	if cfg.StorageQuotaUser <= 0 && cfg.StorageQuotaTeam <= 0 {
+		// Per-user/team overrides may still apply; only skip when none are set
+		quotas, err := repository.GetStorageQuotas(ctx, userID)
+		if err != nil || (!quotas.UserQuota.Valid && !quotas.TeamQuota.Valid) {
+			return nil
+		}
+	}
+
+	user, team, err := GetStorageUsage(ctx, userID)
+	if err != nil {
+		log.Printf("[WARN] Failed to check storage quota for user %d: %v", userID, err)
+		return nil
+	}
+	if user.Quota > 0 && user.Bytes+size > user.Quota {
+		return fmt.Errorf("%w: %s used of %s", ErrStorageQuotaExceeded, FormatBytes(user.Bytes), FormatBytes(user.Quota))
+	}
+	if team != nil && team.Quota > 0 && team.Bytes+size > team.Quota {
+		return fmt.Errorf("%w: team %s has used %s of %s", ErrStorageQuotaExceeded, team.Name, FormatBytes(team.Bytes), FormatBytes(team.Quota))
+	}
+	return nil
+}
+
+// FormatBytes renders a byte count for messages, e.g. "1.5 GiB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
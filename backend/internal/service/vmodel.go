@@ -123,12 +123,14 @@ type VModelSwapTaskResult struct {
 	Status    string `json:"status"` // queuing, processing, completed, failed
 	ResultURL string `json:"result_url,omitempty"`
 	Error     string `json:"error,omitempty"`
+	TaskCost  int    `json:"task_cost,omitempty"` // Credits charged, only reported on creation
 }
 
 // VModelDetectTaskResult is the result of a detect task creation
 type VModelDetectTaskResult struct {
-	TaskID string `json:"task_id"`
-	Status string `json:"status"` // queuing, processing, completed, failed
+	TaskID   string `json:"task_id"`
+	Status   string `json:"status"`              // queuing, processing, completed, failed
+	TaskCost int    `json:"task_cost,omitempty"` // Credits charged, only reported on creation
 }
 
 // VModelDetectStatusResult is the result of checking detect task status
@@ -185,23 +187,11 @@ func (c *VModelClient) doRequest(ctx context.Context, method, endpoint string, b
 			}
 		}
 
-		// Create request with fresh body reader
-		var reqBody io.Reader
-		if jsonBody != nil {
-			reqBody = bytes.NewReader(jsonBody)
-		}
-
-		req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
-		if err != nil {
-			return nil, fmt.Errorf("create request: %w", err)
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer "+c.cfg.VModelAPIToken)
-
-		resp, err := c.httpClient.Do(req)
+		start := time.Now()
+		statusCode, respBody, err := c.send(ctx, method, url, jsonBody)
+		recordProviderCall(method, endpoint, attempt+1, jsonBody, statusCode, respBody, err, time.Since(start))
 		if err != nil {
-			lastErr = fmt.Errorf("request failed: %w", err)
+			lastErr = err
 			// Only short-circuit if caller's context is done
 			if ctx.Err() != nil {
 				return nil, lastErr
@@ -212,19 +202,9 @@ func (c *VModelClient) doRequest(ctx context.Context, method, endpoint string, b
 			return nil, lastErr
 		}
 
-		respBody, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			lastErr = fmt.Errorf("read response: %w", err)
-			if retryable {
-				continue // Retry
-			}
-			return nil, lastErr
-		}
-
 		// Check for retryable HTTP status codes (5xx, 429)
-		if resp.StatusCode >= 500 || resp.StatusCode == 429 {
-			lastErr = fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(respBody))
+		if statusCode >= 500 || statusCode == 429 {
+			lastErr = fmt.Errorf("HTTP %d: %s", statusCode, string(respBody))
 			if retryable {
 				continue // Retry
 			}
@@ -248,6 +228,35 @@ func (c *VModelClient) doRequest(ctx context.Context, method, endpoint string, b
 	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
+// send makes a single request attempt and reads the whole response
+func (c *VModelClient) send(ctx context.Context, method, url string, jsonBody []byte) (int, []byte, error) {
+	// Create request with fresh body reader
+	var reqBody io.Reader
+	if jsonBody != nil {
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return 0, nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.cfg.VModelAPIToken)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("request failed: %w", err)
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("read response: %w", err)
+	}
+	return resp.StatusCode, respBody, nil
+}
+
 // DetectFaces detects faces in an image/video URL
 func (c *VModelClient) DetectFaces(ctx context.Context, mediaURL string) (*VModelDetectResult, error) {
 	reqBody := vmodelCreateTaskRequest{
@@ -339,8 +348,9 @@ func (c *VModelClient) CreateDetectTask(ctx context.Context, mediaURL string) (*
 	}
 
 	return &VModelDetectTaskResult{
-		TaskID: createResult.TaskID,
-		Status: "queuing",
+		TaskID:   createResult.TaskID,
+		Status:   "queuing",
+		TaskCost: createResult.TaskCost,
 	}, nil
 }
 
@@ -438,8 +448,9 @@ func (c *VModelClient) CreateSwapTask(ctx context.Context, detectID string, face
 	}
 
 	return &VModelSwapTaskResult{
-		TaskID: createResult.TaskID,
-		Status: "queuing",
+		TaskID:   createResult.TaskID,
+		Status:   "queuing",
+		TaskCost: createResult.TaskCost,
 	}, nil
 }
 
@@ -0,0 +1,34 @@
+-- 存储 key 化: 数据库只保存对象 key 和存储后端, URL 在读取时按需生成 (公开 / 直连 / 签名)
+-- 运行: psql $DATABASE_URL -f migrations/002_storage_keys.sql
+
+-- 媒体文件: key 取代 URL
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS storage_key TEXT;
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS thumbnail_key TEXT;
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS storage_backend VARCHAR(16); -- s3, local
+
+CREATE INDEX IF NOT EXISTS idx_media_files_storage_key ON media_files(storage_key);
+
+-- 换脸任务: 记录输入输出的 key
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS detect_id VARCHAR(64);
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS target_video_key TEXT;
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS source_face_keys TEXT[];
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS result_key TEXT;
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS storage_backend VARCHAR(16);
+
+CREATE INDEX IF NOT EXISTS idx_swap_tasks_result_key ON swap_tasks(result_key);
+
+-- 回填: 从已有 URL 列中提取 key
+-- key 均以 GenerateKey 的前缀开头, 与 CDN 是否按桶划分域名无关
+UPDATE media_files
+SET storage_key = substring(storage_url FROM '((?:videos|images|faces|frames|results)/[^?#]+)'),
+    storage_backend = CASE WHEN storage_url LIKE '/uploads/%' THEN 'local' ELSE 's3' END
+WHERE storage_key IS NULL AND storage_url IS NOT NULL;
+
+UPDATE media_files
+SET thumbnail_key = substring(thumbnail_url FROM '((?:videos|images|faces|frames|results)/[^?#]+)')
+WHERE thumbnail_key IS NULL AND thumbnail_url IS NOT NULL;
+
+UPDATE swap_tasks
+SET result_key = substring(result_url FROM '((?:videos|images|faces|frames|results)/[^?#]+)'),
+    storage_backend = CASE WHEN result_url LIKE '/uploads/%' THEN 'local' ELSE 's3' END
+WHERE result_key IS NULL AND result_url IS NOT NULL;
@@ -0,0 +1,20 @@
+-- 媒体库: 所有上传 (视频/图片/人脸/帧) 均记录到 media_files
+-- 运行: psql $DATABASE_URL -f migrations/003_media_library.sql
+
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS category VARCHAR(20) DEFAULT 'media'; -- media, face, frame
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS content_type VARCHAR(100);
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS file_hash VARCHAR(64); -- SHA-256 hex
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS width INTEGER;
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS height INTEGER;
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS duration DOUBLE PRECISION; -- 秒, 仅视频
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS idx_media_files_user_created ON media_files(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_media_files_tags ON media_files USING GIN(tags);
+CREATE INDEX IF NOT EXISTS idx_media_files_file_hash ON media_files(file_hash);
+
+DROP TRIGGER IF EXISTS media_files_updated_at ON media_files;
+CREATE TRIGGER media_files_updated_at
+    BEFORE UPDATE ON media_files
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at();
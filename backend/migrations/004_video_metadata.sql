@@ -0,0 +1,6 @@
+-- 视频元数据: 上传时从容器头解析 (MP4/MOV/WebM/AVI)
+-- 运行: psql $DATABASE_URL -f migrations/004_video_metadata.sql
+
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS frame_rate DOUBLE PRECISION;
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS video_codec VARCHAR(32); -- avc1, hvc1, V_VP9 ...
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS rotation SMALLINT; -- 顺时针角度: 0, 90, 180, 270
@@ -0,0 +1,6 @@
+-- 人脸图标准化: 保留原图, 另存旋转/缩放/去元数据后的 JPEG 供换脸使用
+-- 运行: psql $DATABASE_URL -f migrations/005_face_normalization.sql
+
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS normalized_key TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_media_files_normalized_key ON media_files(normalized_key);
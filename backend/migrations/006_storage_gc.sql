@@ -0,0 +1,24 @@
+-- 孤立对象回收: 记录每次 GC 的扫描结果和回收空间
+-- 运行: psql $DATABASE_URL -f migrations/006_storage_gc.sql
+
+CREATE TABLE IF NOT EXISTS gc_runs (
+    id SERIAL PRIMARY KEY,
+    mode VARCHAR(16) NOT NULL, -- quarantine, delete
+    dry_run BOOLEAN DEFAULT FALSE,
+    scanned_objects BIGINT DEFAULT 0,
+    scanned_bytes BIGINT DEFAULT 0,
+    orphaned_objects BIGINT DEFAULT 0, -- 超过宽限期且无引用的对象
+    orphaned_bytes BIGINT DEFAULT 0,
+    quarantined_objects BIGINT DEFAULT 0,
+    quarantined_bytes BIGINT DEFAULT 0,
+    deleted_objects BIGINT DEFAULT 0, -- 直接删除 + 隔离期满清除
+    reclaimed_bytes BIGINT DEFAULT 0,
+    expired_media INTEGER DEFAULT 0, -- 过期的帧 / 人脸库记录
+    failures INTEGER DEFAULT 0,
+    prefixes JSONB, -- 按前缀统计
+    error_message TEXT,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_gc_runs_started_at ON gc_runs(started_at);
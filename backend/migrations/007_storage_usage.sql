@@ -0,0 +1,20 @@
+-- 存储用量统计与配额: 记录各变体和结果的大小, 按用户 / 团队汇总
+-- 运行: psql $DATABASE_URL -f migrations/007_storage_usage.sql
+
+-- 团队
+CREATE TABLE IF NOT EXISTS teams (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) UNIQUE NOT NULL,
+    storage_quota BIGINT, -- 字节, NULL 使用 STORAGE_QUOTA_TEAM
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS team_id INTEGER REFERENCES teams(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS storage_quota BIGINT; -- 字节, NULL 使用 STORAGE_QUOTA_USER
+
+CREATE INDEX IF NOT EXISTS idx_users_team_id ON users(team_id);
+
+-- 媒体变体与换脸结果的大小 (原文件大小已在 file_size 中)
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS normalized_size BIGINT;
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS thumbnail_size BIGINT;
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS result_size BIGINT; -- 此前转存的结果为 NULL, 按 0 计
@@ -0,0 +1,19 @@
+-- 结果分享链接: 免登录访问换脸结果, 支持过期、密码、次数上限和撤销
+-- 运行: psql $DATABASE_URL -f migrations/008_share_links.sql
+
+CREATE TABLE IF NOT EXISTS share_links (
+    id SERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) UNIQUE NOT NULL, -- 只保存 token 的 SHA-256, 原文仅在创建时返回
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    task_id VARCHAR(64) NOT NULL REFERENCES swap_tasks(task_id) ON DELETE CASCADE,
+    password_hash TEXT, -- bcrypt, NULL 表示无密码
+    max_downloads INTEGER, -- 查看与下载合计次数上限, NULL 表示不限
+    view_count INTEGER DEFAULT 0,
+    download_count INTEGER DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    last_accessed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_links_task_id ON share_links(task_id);
@@ -0,0 +1,6 @@
+-- 换脸任务批次: 创建任务时可指定 batch_id, 用于按批次导出结果
+-- 运行: psql $DATABASE_URL -f migrations/009_swap_batches.sql
+
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS batch_id VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS idx_swap_tasks_user_batch ON swap_tasks(user_id, batch_id);
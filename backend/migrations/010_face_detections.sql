@@ -0,0 +1,20 @@
+-- 人脸检测记录: 检测结果落库, 换脸前校验 detect_id 归属和 face_id
+-- 运行: psql $DATABASE_URL -f migrations/010_face_detections.sql
+
+CREATE TABLE IF NOT EXISTS face_detections (
+    id SERIAL PRIMARY KEY,
+    task_id VARCHAR(64) UNIQUE NOT NULL, -- VModel 检测任务 ID
+    detect_id VARCHAR(64), -- 检测完成后返回, 创建换脸任务时使用
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    media_id VARCHAR(64), -- 检测的媒体, 外部 URL 时为空
+    media_key VARCHAR(512),
+    status VARCHAR(20) NOT NULL DEFAULT 'queuing', -- queuing, processing, completed, failed
+    faces JSONB, -- [{"face_id": 0, "thumbnail": "https://..."}]
+    error_message TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_face_detections_detect_id ON face_detections(detect_id);
+CREATE INDEX IF NOT EXISTS idx_face_detections_user_id ON face_detections(user_id);
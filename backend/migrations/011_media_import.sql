@@ -0,0 +1,8 @@
+-- 远程 URL 导入: 媒体记录在下载完成前即创建, 以 status 表示导入进度
+-- 运行: psql $DATABASE_URL -f migrations/011_media_import.sql
+
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'ready'; -- importing, ready, failed
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS source_url TEXT; -- 导入来源, 不含查询参数
+ALTER TABLE media_files ADD COLUMN IF NOT EXISTS error_message TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_media_files_importing ON media_files(status) WHERE status = 'importing';
@@ -0,0 +1,17 @@
+-- 积分台账: 记录每个换脸任务消耗的 VModel 积分, 用于预估时的额度判断和用量统计
+-- 运行: psql $DATABASE_URL -f migrations/012_credits.sql
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS credit_quota DECIMAL(12,2); -- 每月积分额度, NULL 使用 CREDIT_QUOTA_USER
+
+CREATE TABLE IF NOT EXISTS credit_ledger (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    task_id VARCHAR(64), -- 换脸任务 ID
+    kind VARCHAR(32) NOT NULL DEFAULT 'swap',
+    credits DECIMAL(12,2) NOT NULL,
+    estimated BOOLEAN DEFAULT FALSE, -- VModel 未返回实际消耗时记录的是预估值
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_ledger_user_created ON credit_ledger(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_credit_ledger_task_id ON credit_ledger(task_id);
@@ -0,0 +1,10 @@
+-- 换脸任务审批: 预估积分超过上限的任务先以 pending_approval 状态保存, 管理员批准后再提交 VModel
+-- 运行: psql $DATABASE_URL -f migrations/013_task_approvals.sql
+
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS face_enhance BOOLEAN DEFAULT FALSE;
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS approval_id VARCHAR(64) UNIQUE; -- 待审批时的任务 ID, 提交后 task_id 换成 VModel 的 ID
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS approval_reason TEXT;
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS reviewed_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_swap_tasks_pending_approval ON swap_tasks(created_at) WHERE status = 'pending_approval';
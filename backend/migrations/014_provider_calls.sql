@@ -0,0 +1,30 @@
+-- 任务运维: 记录调用 VModel 的请求 / 响应和耗时, 并持久化结果转存状态, 供管理员排查任务
+-- 运行: psql $DATABASE_URL -f migrations/014_provider_calls.sql
+
+CREATE TABLE IF NOT EXISTS provider_calls (
+    id BIGSERIAL PRIMARY KEY,
+    provider VARCHAR(32) NOT NULL DEFAULT 'vmodel',
+    task_id VARCHAR(64), -- 从请求路径或响应中解析, 创建失败时为空
+    version VARCHAR(128), -- VModel 模型版本, 区分检测 / 换脸
+    method VARCHAR(8) NOT NULL,
+    endpoint TEXT NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 1,
+    request JSONB, -- URL 中的签名已去除
+    status_code INTEGER,
+    response TEXT, -- 截断到 64KB
+    error TEXT,
+    duration_ms INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_provider_calls_task_id ON provider_calls(task_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_provider_calls_created_at ON provider_calls(created_at);
+
+-- 结果转存状态 (此前只在内存中)
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS transfer_status VARCHAR(20); -- pending, completed, failed
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS transfer_error TEXT;
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS transfer_started_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE swap_tasks ADD COLUMN IF NOT EXISTS transfer_finished_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_swap_tasks_created_at ON swap_tasks(created_at);
+CREATE INDEX IF NOT EXISTS idx_face_detections_created_at ON face_detections(created_at);
@@ -0,0 +1,7 @@
+-- 用户角色: admin 可访问管理接口, member 可正常使用, viewer 只读
+-- 运行: psql $DATABASE_URL -f migrations/015_user_roles.sql
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'member'
+    CHECK (role IN ('admin', 'member', 'viewer'));
+
+CREATE INDEX IF NOT EXISTS idx_users_admin ON users(id) WHERE role = 'admin';
@@ -0,0 +1,12 @@
+-- 密码账号: 用户可设置密码登录, 管理员可直接创建账号, 忘记密码时通过邮箱验证码重置
+-- 运行: psql $DATABASE_URL -f migrations/016_password_accounts.sql
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255); -- bcrypt, 为空时只能验证码登录
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS created_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+-- 验证码用途: login 登录, password_reset 重置密码
+ALTER TABLE verification_codes ADD COLUMN IF NOT EXISTS purpose VARCHAR(20) NOT NULL DEFAULT 'login';
+
+CREATE INDEX IF NOT EXISTS idx_verification_codes_email_purpose ON verification_codes(email, purpose);
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users(lower(email));
@@ -0,0 +1,19 @@
+-- 邀请: 管理员邀请允许域名之外的邮箱 (如合作方、外包), 指定角色和有效期
+-- 运行: psql $DATABASE_URL -f migrations/017_invitations.sql
+
+CREATE TABLE IF NOT EXISTS invitations (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL DEFAULT 'member' CHECK (role IN ('admin', 'member', 'viewer')),
+    invited_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    accepted_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- 每个邮箱同时只有一个未处理的邀请
+CREATE UNIQUE INDEX IF NOT EXISTS idx_invitations_open_email ON invitations(lower(email))
+    WHERE accepted_at IS NULL AND revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_invitations_created_at ON invitations(created_at);
@@ -0,0 +1,18 @@
+-- 验证码防暴力破解: 每个验证码的错误次数, 超过上限后锁定; 锁定、限流等事件记入审计表
+-- 运行: psql $DATABASE_URL -f migrations/018_auth_protection.sql
+
+ALTER TABLE verification_codes ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE verification_codes ADD COLUMN IF NOT EXISTS locked_at TIMESTAMP WITH TIME ZONE;
+
+-- 认证审计
+CREATE TABLE IF NOT EXISTS auth_events (
+    id BIGSERIAL PRIMARY KEY,
+    event VARCHAR(50) NOT NULL, -- code_locked, ip_throttled
+    email VARCHAR(255),
+    ip VARCHAR(64),
+    detail TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_events_created_at ON auth_events(created_at);
+CREATE INDEX IF NOT EXISTS idx_auth_events_email ON auth_events(lower(email));
@@ -0,0 +1,5 @@
+-- 人脸检测按媒体查找: 删除媒体时级联删除其检测记录, GC 判断媒体是否仍在检测中
+-- 运行: psql $DATABASE_URL -f migrations/019_face_detection_media.sql
+
+CREATE INDEX IF NOT EXISTS idx_face_detections_media_id ON face_detections(media_id);
+CREATE INDEX IF NOT EXISTS idx_face_detections_media_key ON face_detections(media_key);